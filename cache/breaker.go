@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// errCacheUnavailable is returned while a Breaker skips its cache.
+var errCacheUnavailable = errors.New("cache unavailable after a recent failure")
+
+// Breaker wraps a remote cache so that an unreachable server costs one timeout per
+// cooldown instead of one per lookup: after a failure, lookups and writes fail at once
+// until the cooldown has passed, and the next call tries the server again. Deletes are
+// always attempted, since a skipped invalidation would leave stale entries behind once
+// the server is back.
+type Breaker struct {
+	Cache
+	cooldown  time.Duration
+	openUntil atomic.Int64 // unix nanoseconds
+}
+
+func NewBreaker(c Cache, cooldown time.Duration) *Breaker {
+	return &Breaker{Cache: c, cooldown: cooldown}
+}
+
+func (b *Breaker) open() bool {
+	return time.Now().UnixNano() < b.openUntil.Load()
+}
+
+// record opens the breaker if err is the cache's fault rather than the caller's.
+func (b *Breaker) record(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() == nil {
+		if !b.open() {
+			log.Printf("Cache: Skipping the cache for %s after an error: %v", b.cooldown, err)
+		}
+		b.openUntil.Store(time.Now().Add(b.cooldown).UnixNano())
+	}
+	return err
+}
+
+func (b *Breaker) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if b.open() {
+		return nil, false, errCacheUnavailable
+	}
+	value, ok, err := b.Cache.Get(ctx, key)
+	return value, ok, b.record(ctx, err)
+}
+
+func (b *Breaker) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	if b.open() {
+		return nil, errCacheUnavailable
+	}
+	values, err := b.Cache.GetMany(ctx, keys)
+	return values, b.record(ctx, err)
+}
+
+func (b *Breaker) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if b.open() {
+		return errCacheUnavailable
+	}
+	return b.record(ctx, b.Cache.Set(ctx, key, value, ttl))
+}
+
+func (b *Breaker) SetMany(ctx context.Context, values map[string][]byte, ttl time.Duration) error {
+	if b.open() {
+		return errCacheUnavailable
+	}
+	return b.record(ctx, b.Cache.SetMany(ctx, values, ttl))
+}
+
+func (b *Breaker) Delete(ctx context.Context, keys ...string) error {
+	return b.record(ctx, b.Cache.Delete(ctx, keys...))
+}
@@ -0,0 +1,65 @@
+// Package cache provides the byte-oriented caches the repositories read through: an
+// in-process LRU and a Redis client, selected by configuration. Remote caches are
+// wrapped in a Breaker, so that an outage does not slow every request down.
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Cache stores opaque values under string keys, each with its own time to live.
+// Implementations must be safe for concurrent use. Values passed to Set and returned by
+// Get may be shared and must not be modified.
+type Cache interface {
+	// Get returns the value of key and whether it was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// GetMany returns the values of the keys that were found.
+	GetMany(ctx context.Context, keys []string) (map[string][]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	SetMany(ctx context.Context, values map[string][]byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	Close() error
+}
+
+// Cache drivers accepted by New.
+const (
+	DriverMemory = "memory"
+	DriverRedis  = "redis"
+	DriverNone   = "none"
+)
+
+// Config selects and configures a Cache implementation.
+type Config struct {
+	Driver string // one of the Driver* constants; defaults to DriverMemory
+
+	MemoryEntries int // maximum number of entries, used by DriverMemory
+
+	RedisAddr     string // host:port, used by DriverRedis
+	RedisPassword string // optional, used by DriverRedis
+	RedisDB       int    // database number, used by DriverRedis
+
+	FailureCooldown time.Duration // how long a remote cache is skipped after a failure; defaults to 5s
+}
+
+// New builds the Cache selected by cfg.Driver.
+func New(cfg Config) (Cache, error) {
+	switch cfg.Driver {
+	case "", DriverMemory:
+		return NewLRU(cfg.MemoryEntries), nil
+	case DriverRedis:
+		if cfg.RedisAddr == "" {
+			return nil, fmt.Errorf("redis cache requires an address")
+		}
+		cooldown := cfg.FailureCooldown
+		if cooldown <= 0 {
+			cooldown = 5 * time.Second
+		}
+		return NewBreaker(NewRedis(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB), cooldown), nil
+	case DriverNone:
+		return NoopCache{}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache driver: %s", cfg.Driver)
+	}
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// NoopCache stores nothing; every lookup misses. It turns caching off.
+type NoopCache struct{}
+
+func (NoopCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (NoopCache) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	return map[string][]byte{}, nil
+}
+
+func (NoopCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return nil
+}
+
+func (NoopCache) SetMany(ctx context.Context, values map[string][]byte, ttl time.Duration) error {
+	return nil
+}
+
+func (NoopCache) Delete(ctx context.Context, keys ...string) error {
+	return nil
+}
+
+func (NoopCache) Close() error {
+	return nil
+}
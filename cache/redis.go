@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisDialTimeout bounds connecting to the server.
+	redisDialTimeout = time.Second
+	// redisTimeout bounds a round trip. Misses are cheaper than waiting, so it is short.
+	redisTimeout = 200 * time.Millisecond
+)
+
+// Redis is a cache backed by a Redis server. Failed commands and connections are not
+// retried: the caller falls back to the database instead.
+type Redis struct {
+	client *redis.Client
+}
+
+func NewRedis(addr, password string, db int) *Redis {
+	return &Redis{client: redis.NewClient(&redis.Options{
+		Addr:          addr,
+		Password:      password,
+		DB:            db,
+		DialTimeout:   redisDialTimeout,
+		DialerRetries: 1,
+		ReadTimeout:   redisTimeout,
+		WriteTimeout:  redisTimeout,
+		MaxRetries:    -1,
+	})}
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *Redis) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	replies, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, reply := range replies {
+		if value, ok := reply.(string); ok {
+			values[keys[i]] = []byte(value)
+		}
+	}
+	return values, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+// SetMany pipelines one SET per value, as MSET cannot set expiries.
+func (c *Redis) SetMany(ctx context.Context, values map[string][]byte, ttl time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range values {
+			pipe.Set(ctx, key, value, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.client.Del(ctx, keys...).Err()
+}
+
+func (c *Redis) Close() error {
+	return c.client.Close()
+}
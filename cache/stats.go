@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats counts the lookups of one cached data set. Errors are lookups that failed and
+// fell back to the database.
+type Stats struct {
+	hits, misses, errors atomic.Int64
+}
+
+func (s *Stats) Hit(n int)  { s.hits.Add(int64(n)) }
+func (s *Stats) Miss(n int) { s.misses.Add(int64(n)) }
+func (s *Stats) Error()     { s.errors.Add(1) }
+
+// StatsSnapshot is the state of a Stats at one point in time.
+type StatsSnapshot struct {
+	Name    string  `json:"name"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	Errors  int64   `json:"errors"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// Metrics holds the Stats of every cached data set by name.
+type Metrics struct {
+	mu    sync.Mutex
+	stats map[string]*Stats
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{stats: make(map[string]*Stats)}
+}
+
+// For returns the Stats of the named data set, creating them on first use.
+func (m *Metrics) For(name string) *Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.stats[name]
+	if !ok {
+		s = &Stats{}
+		m.stats[name] = s
+	}
+	return s
+}
+
+// Snapshot returns the current counts of every data set, by name.
+func (m *Metrics) Snapshot() []StatsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshots := make([]StatsSnapshot, 0, len(m.stats))
+	for name, s := range m.stats {
+		snapshot := StatsSnapshot{Name: name, Hits: s.hits.Load(), Misses: s.misses.Load(), Errors: s.errors.Load()}
+		if lookups := snapshot.Hits + snapshot.Misses; lookups > 0 {
+			snapshot.HitRate = float64(snapshot.Hits) / float64(lookups)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+	return snapshots
+}
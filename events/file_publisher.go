@@ -0,0 +1,56 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+)
+
+var errPublisherClosed = errors.New("event publisher is closed")
+
+// FilePublisher appends events to a newline-delimited JSON file, one encoded
+// event per line. Useful for local development without a broker.
+type FilePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FilePublisher{file: file}, nil
+}
+
+func (p *FilePublisher) Publish(ctx context.Context, events ...Event) error {
+	var buf []byte
+	for _, e := range events {
+		value, err := e.Encode()
+		if err != nil {
+			return err
+		}
+		buf = append(buf, value...)
+		buf = append(buf, '\n')
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.file == nil {
+		return errPublisherClosed
+	}
+	_, err := p.file.Write(buf)
+	return err
+}
+
+func (p *FilePublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.file == nil {
+		return nil
+	}
+	err := p.file.Close()
+	p.file = nil
+	return err
+}
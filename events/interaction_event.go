@@ -0,0 +1,90 @@
+// Package events defines the payloads this service exchanges with the rest of
+// the platform over Kafka. Consumers (e.g. the Spark recommender) can import
+// this package to decode messages instead of re-querying the database.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// InteractionEventSchemaVersion is bumped whenever a field of InteractionEvent
+// changes meaning or is removed. Adding optional fields does not bump it.
+const InteractionEventSchemaVersion = 1
+
+// InteractionEvent is the envelope written to the `interactions` topic every
+// time a user interacts with a track. Messages are keyed by user ID so all
+// events of a single user land on the same partition, in order.
+type InteractionEvent struct {
+	EventID       string    `json:"event_id"`
+	SchemaVersion int       `json:"schema_version"`
+	Type          string    `json:"type"`
+	UserID        int       `json:"user_id"`
+	TrackID       string    `json:"track_id"`
+	Weight        float64   `json:"weight"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// NewInteractionEvent builds an event with a fresh event ID and the current schema version.
+func NewInteractionEvent(userID int, trackID string, interactionType string, weight float64, timestamp time.Time) (*InteractionEvent, error) {
+	eventID, err := newEventID()
+	if err != nil {
+		return nil, err
+	}
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return &InteractionEvent{
+		EventID:       eventID,
+		SchemaVersion: InteractionEventSchemaVersion,
+		Type:          interactionType,
+		UserID:        userID,
+		TrackID:       trackID,
+		Weight:        weight,
+		Timestamp:     timestamp.UTC(),
+	}, nil
+}
+
+// Key returns the partition key of the event (the user ID).
+func (e *InteractionEvent) Key() []byte {
+	return []byte(strconv.Itoa(e.UserID))
+}
+
+// Encode returns the JSON wire representation of the event.
+func (e *InteractionEvent) Encode() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// DecodeInteractionEvent parses a message value produced by Encode.
+func DecodeInteractionEvent(data []byte) (*InteractionEvent, error) {
+	var e InteractionEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// newEventID returns a random RFC 4122 version 4 UUID.
+func newEventID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf), nil
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// MessageHandler processes the value of a single consumed message. Errors are retried
+// unless wrapped with Permanent.
+type MessageHandler func(ctx context.Context, value []byte) error
+
+// permanentError marks a message that can never be processed.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as caused by the message itself, such as a payload that does not
+// decode, so that retrying it is pointless. The consumer skips such messages.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+const (
+	// consumerMinBackoff and consumerMaxBackoff bound the wait between two attempts to
+	// fetch or handle a message; the wait doubles after every failure.
+	consumerMinBackoff = time.Second
+	consumerMaxBackoff = time.Minute
+)
+
+// KafkaConsumer reads a topic as part of a consumer group and hands every
+// message to a MessageHandler.
+type KafkaConsumer struct {
+	Reader *kafka.Reader
+}
+
+func NewKafkaConsumer(kafkaURL string, topic string, groupID string) *KafkaConsumer {
+	return &KafkaConsumer{
+		Reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: []string{kafkaURL},
+			Topic:   topic,
+			GroupID: groupID,
+		}),
+	}
+}
+
+// Run consumes messages until ctx is cancelled. A message's offset is committed once
+// the handler succeeds, or fails with a Permanent error, which is logged and skipped so
+// that one bad payload cannot block the partition. Other errors, such as an unavailable
+// database, are retried on the same message with backoff until it succeeds, so no
+// message is lost; the partition waits meanwhile, so handlers must mark every error
+// that retrying cannot fix as Permanent. Failures to fetch messages are retried the
+// same way.
+func (c *KafkaConsumer) Run(ctx context.Context, handle MessageHandler) error {
+	topic := c.Reader.Config().Topic
+	backoff := consumerMinBackoff
+	for {
+		msg, err := c.Reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("Consumer: Failed to fetch from %s, retrying in %s: %v", topic, backoff, err)
+			if !sleep(ctx, backoff) {
+				return nil
+			}
+			backoff = min(2*backoff, consumerMaxBackoff)
+			continue
+		}
+		backoff = consumerMinBackoff
+
+		for {
+			err := handle(ctx, msg.Value)
+			if err == nil {
+				break
+			}
+			if IsPermanent(err) {
+				log.Printf("Consumer: Skipping message from %s (partition %d, offset %d): %v", topic, msg.Partition, msg.Offset, err)
+				break
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("Consumer: Failed to handle message from %s (partition %d, offset %d), retrying in %s: %v", topic, msg.Partition, msg.Offset, backoff, err)
+			if !sleep(ctx, backoff) {
+				return nil
+			}
+			backoff = min(2*backoff, consumerMaxBackoff)
+		}
+		backoff = consumerMinBackoff
+
+		if err := c.Reader.CommitMessages(ctx, msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			// The message is delivered again after a restart; handlers must be idempotent.
+			log.Printf("Consumer: Failed to commit offset %d on %s: %v", msg.Offset, topic, err)
+		}
+	}
+}
+
+// sleep waits for d and reports whether ctx is still live.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (c *KafkaConsumer) Close() error {
+	return c.Reader.Close()
+}
@@ -0,0 +1,38 @@
+package events
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher writes events to a single Kafka topic.
+type KafkaPublisher struct {
+	Writer *kafka.Writer
+}
+
+func NewKafkaPublisher(kafkaURL string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{
+		Writer: &kafka.Writer{
+			Addr:     kafka.TCP(kafkaURL),
+			Topic:    topic,
+			Balancer: &kafka.Hash{}, // events are keyed by user ID; keep each user's events ordered on one partition
+		},
+	}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, events ...Event) error {
+	msgs := make([]kafka.Message, len(events))
+	for i, e := range events {
+		value, err := e.Encode()
+		if err != nil {
+			return err
+		}
+		msgs[i] = kafka.Message{Key: e.Key(), Value: value}
+	}
+	return p.Writer.WriteMessages(ctx, msgs...)
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.Writer.Close()
+}
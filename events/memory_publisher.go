@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// MemoryPublisher delivers events to an in-process channel. It is meant for
+// tests and for wiring consumers inside the same binary. It never blocks the caller:
+// events that do not fit in the buffer are dropped, so a reader that falls behind, or
+// is missing, cannot stall interaction requests.
+type MemoryPublisher struct {
+	events chan Event
+	mu     sync.RWMutex
+	closed bool
+
+	dropped atomic.Int64
+}
+
+func NewMemoryPublisher(buffer int) *MemoryPublisher {
+	if buffer <= 0 {
+		buffer = 1024
+	}
+	return &MemoryPublisher{events: make(chan Event, buffer)}
+}
+
+// Events returns the channel published events are delivered on. It is closed by Close.
+func (p *MemoryPublisher) Events() <-chan Event {
+	return p.events
+}
+
+// Publish delivers the events that fit in the buffer and reports how many were dropped.
+func (p *MemoryPublisher) Publish(ctx context.Context, events ...Event) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return errPublisherClosed
+	}
+	dropped := 0
+	for _, e := range events {
+		select {
+		case p.events <- e:
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		p.dropped.Add(int64(dropped))
+		return fmt.Errorf("memory publisher buffer is full, dropped %d events", dropped)
+	}
+	return nil
+}
+
+// Dropped returns how many events were dropped because the buffer was full.
+func (p *MemoryPublisher) Dropped() int64 {
+	return p.dropped.Load()
+}
+
+func (p *MemoryPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.events)
+	}
+	return nil
+}
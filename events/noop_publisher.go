@@ -0,0 +1,10 @@
+package events
+
+import "context"
+
+// NoopPublisher discards every event.
+type NoopPublisher struct{}
+
+func (NoopPublisher) Publish(ctx context.Context, events ...Event) error { return nil }
+
+func (NoopPublisher) Close() error { return nil }
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"fmt"
+)
+
+// Event is anything that can be published: it knows its partition key and its wire encoding.
+type Event interface {
+	Key() []byte
+	Encode() ([]byte, error)
+}
+
+// EventPublisher delivers events to a sink. Implementations must be safe for concurrent use.
+type EventPublisher interface {
+	Publish(ctx context.Context, events ...Event) error
+	Close() error
+}
+
+// Publisher drivers accepted by NewPublisher.
+const (
+	DriverKafka  = "kafka"
+	DriverMemory = "memory"
+	DriverFile   = "file"
+	DriverNoop   = "noop"
+)
+
+// PublisherConfig selects and configures an EventPublisher implementation.
+type PublisherConfig struct {
+	Driver string // one of the Driver* constants; defaults to DriverKafka
+
+	KafkaURL   string // broker address, used by DriverKafka
+	KafkaTopic string // topic name, used by DriverKafka
+
+	FilePath string // output file, used by DriverFile
+
+	MemoryBuffer int // channel capacity, used by DriverMemory
+}
+
+// NewPublisher builds the EventPublisher selected by cfg.Driver.
+func NewPublisher(cfg PublisherConfig) (EventPublisher, error) {
+	switch cfg.Driver {
+	case "", DriverKafka:
+		if cfg.KafkaURL == "" || cfg.KafkaTopic == "" {
+			return nil, fmt.Errorf("kafka publisher requires a broker URL and a topic")
+		}
+		return NewKafkaPublisher(cfg.KafkaURL, cfg.KafkaTopic), nil
+	case DriverMemory:
+		return NewMemoryPublisher(cfg.MemoryBuffer), nil
+	case DriverFile:
+		if cfg.FilePath == "" {
+			return nil, fmt.Errorf("file publisher requires a file path")
+		}
+		return NewFilePublisher(cfg.FilePath)
+	case DriverNoop:
+		return NoopPublisher{}, nil
+	default:
+		return nil, fmt.Errorf("unknown event publisher driver: %s", cfg.Driver)
+	}
+}
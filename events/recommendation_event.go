@@ -0,0 +1,50 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// RecommendationEventSchemaVersion is the current version of RecommendationEvent.
+const RecommendationEventSchemaVersion = 1
+
+// RecommendationEvent is read from the `recommendations` topic. It carries the
+// ranked tracks a model produced for one user; the service writes them into
+// that user's recommendation playlist.
+type RecommendationEvent struct {
+	SchemaVersion int       `json:"schema_version"`
+	UserID        int       `json:"user_id"`
+	TrackIDs      []string  `json:"track_ids"` // best first
+	ModelVersion  string    `json:"model_version"`
+	GeneratedAt   time.Time `json:"generated_at"`
+}
+
+// Key returns the partition key of the event (the user ID).
+func (e *RecommendationEvent) Key() []byte {
+	return []byte(strconv.Itoa(e.UserID))
+}
+
+// Encode returns the JSON wire representation of the event.
+func (e *RecommendationEvent) Encode() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// DecodeRecommendationEvent parses and validates a message value produced by Encode.
+func DecodeRecommendationEvent(data []byte) (*RecommendationEvent, error) {
+	var e RecommendationEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	if e.UserID <= 0 {
+		return nil, errors.New("recommendation event is missing user_id")
+	}
+	if e.ModelVersion == "" {
+		return nil, errors.New("recommendation event is missing model_version")
+	}
+	if len(e.TrackIDs) == 0 {
+		return nil, errors.New("recommendation event has no track_ids")
+	}
+	return &e, nil
+}
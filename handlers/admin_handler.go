@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kiasoh/basic-spotify-backend/cache"
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+// AdminHandler serves the /admin routes: user management, interaction inspection,
+// catalog maintenance, recommendation model rebuilds and cache statistics.
+type AdminHandler struct {
+	UserService          *services.UserService
+	InteractionService   *services.InteractionService
+	ArtistService        *services.ArtistService
+	CollaborativeService *services.CollaborativeService
+	CacheMetrics         *cache.Metrics
+}
+
+func NewAdminHandler(userService *services.UserService, interactionService *services.InteractionService, artistService *services.ArtistService, collaborativeService *services.CollaborativeService, cacheMetrics *cache.Metrics) *AdminHandler {
+	return &AdminHandler{UserService: userService, InteractionService: interactionService, ArtistService: artistService, CollaborativeService: collaborativeService, CacheMetrics: cacheMetrics}
+}
+
+type updateRoleRequest struct {
+	Role models.Role `json:"role"` // "user", "curator" or "admin"
+}
+
+type rebuildStatsResponse struct {
+	TracksUpdated int64 `json:"tracks_updated"`
+}
+
+type backfillStatesResponse struct {
+	StatesAdded int64 `json:"states_added"`
+}
+
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handler: Admin listing users")
+	users, err := h.UserService.ListUsers(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list users", http.StatusInternalServerError)
+		return
+	}
+	if users == nil {
+		users = []models.User{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(users)
+}
+
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(chi.URLParam(r, "userID"))
+	if userID == 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Handler: Admin getting user %d", userID)
+	user, err := h.UserService.GetUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *AdminHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(chi.URLParam(r, "userID"))
+	if userID == 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req updateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	log.Printf("Handler: Admin setting role of user %d to '%s'", userID, req.Role)
+	user, err := h.UserService.UpdateUserRole(r.Context(), userID, req.Role)
+	if err != nil {
+		switch err.Error() {
+		case "invalid role":
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case "user not found":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to update role", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *AdminHandler) GetInteractionsByUser(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(chi.URLParam(r, "userID"))
+	if userID == 0 {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	page := parsePageRequest(r, 50, 100)
+
+	log.Printf("Handler: Admin getting interactions of user %d", userID)
+	interactions, err := h.InteractionService.GetInteractionsByUser(r.Context(), userID, page)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to get interactions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(interactions)
+}
+
+func (h *AdminHandler) RebuildTrackStats(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handler: Admin rebuilding track stats")
+	count, err := h.InteractionService.RebuildTrackStats(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to rebuild track stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rebuildStatsResponse{TracksUpdated: count})
+}
+
+// BackfillTrackStates derives the like/dislike state of tracks from interactions that
+// predate track states. Existing states are kept, so it is safe to run again.
+func (h *AdminHandler) BackfillTrackStates(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handler: Admin backfilling track states")
+	count, err := h.InteractionService.BackfillTrackStates(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to backfill track states", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(backfillStatesResponse{StatesAdded: count})
+}
+
+// BackfillArtists derives the artists and albums tables from the catalog. It only
+// adds what is missing, so it is safe to run again after importing more tracks.
+func (h *AdminHandler) BackfillArtists(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handler: Admin backfilling artists and albums")
+	result, err := h.ArtistService.Backfill(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to backfill artists", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// RebuildNeighbors recomputes the collaborative filtering model now instead of waiting
+// for the next scheduled rebuild.
+func (h *AdminHandler) RebuildNeighbors(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handler: Admin rebuilding track neighbors")
+	result, err := h.CollaborativeService.Rebuild(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to rebuild track neighbors", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetCacheStats reports the hits, misses and errors of every cached data set since startup.
+func (h *AdminHandler) GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handler: Admin getting cache stats")
+	stats := h.CacheMetrics.Snapshot()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
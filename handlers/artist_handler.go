@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+type ArtistHandler struct {
+	Service            *services.ArtistService
+	InteractionService *services.InteractionService
+}
+
+func NewArtistHandler(service *services.ArtistService, interactionService *services.InteractionService) *ArtistHandler {
+	return &ArtistHandler{Service: service, InteractionService: interactionService}
+}
+
+func (h *ArtistHandler) GetArtist(w http.ResponseWriter, r *http.Request) {
+	artistID, _ := strconv.Atoi(chi.URLParam(r, "artistID"))
+	if artistID == 0 {
+		http.Error(w, "Invalid artist ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Handler: Getting artist %d", artistID)
+	artist, err := h.Service.GetArtist(r.Context(), artistID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(artist)
+}
+
+func (h *ArtistHandler) ListArtistTracks(w http.ResponseWriter, r *http.Request) {
+	artistID, _ := strconv.Atoi(chi.URLParam(r, "artistID"))
+	if artistID == 0 {
+		http.Error(w, "Invalid artist ID", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 20 // Default limit
+	}
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0 // Default offset
+	}
+
+	log.Printf("Handler: Listing tracks of artist %d", artistID)
+	tracks, err := h.Service.ListArtistTracks(r.Context(), artistID, limit, offset)
+	if err != nil {
+		if err.Error() == "artist not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to list artist tracks", http.StatusInternalServerError)
+		return
+	}
+
+	trackResponses := buildTrackResponses(r, h.InteractionService, h.Service, tracks)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(trackResponses)
+}
+
+func (h *ArtistHandler) GetAlbum(w http.ResponseWriter, r *http.Request) {
+	albumID, _ := strconv.Atoi(chi.URLParam(r, "albumID"))
+	if albumID == 0 {
+		http.Error(w, "Invalid album ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Handler: Getting album %d", albumID)
+	album, err := h.Service.GetAlbum(r.Context(), albumID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(album)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+// CatalogHandler serves the curator endpoints that maintain the track catalog.
+type CatalogHandler struct {
+	Service *services.CatalogService
+}
+
+func NewCatalogHandler(service *services.CatalogService) *CatalogHandler {
+	return &CatalogHandler{Service: service}
+}
+
+// writeCatalogError maps service errors to HTTP status codes.
+func writeCatalogError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, models.ErrInvalidTrack):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err.Error() == "track not found":
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err.Error() == "track already exists" || err.Error() == "track is deleted":
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+func (h *CatalogHandler) CreateTrack(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var track models.SpotifyTrack
+	if err := json.NewDecoder(r.Body).Decode(&track); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	log.Printf("Handler: User %d creating track %s", userID, track.TrackID)
+	created, err := h.Service.CreateTrack(r.Context(), userID, &track)
+	if err != nil {
+		writeCatalogError(w, err, "Failed to create track")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (h *CatalogHandler) UpdateTrack(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	trackID := chi.URLParam(r, "trackID")
+	if trackID == "" {
+		http.Error(w, "Invalid track ID", http.StatusBadRequest)
+		return
+	}
+
+	var track models.SpotifyTrack
+	if err := json.NewDecoder(r.Body).Decode(&track); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	log.Printf("Handler: User %d updating track %s", userID, trackID)
+	updated, err := h.Service.UpdateTrack(r.Context(), userID, trackID, &track)
+	if err != nil {
+		writeCatalogError(w, err, "Failed to update track")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (h *CatalogHandler) DeleteTrack(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	trackID := chi.URLParam(r, "trackID")
+	log.Printf("Handler: User %d deleting track %s", userID, trackID)
+	if err := h.Service.DeleteTrack(r.Context(), userID, trackID); err != nil {
+		writeCatalogError(w, err, "Failed to delete track")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CatalogHandler) RestoreTrack(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	trackID := chi.URLParam(r, "trackID")
+	log.Printf("Handler: User %d restoring track %s", userID, trackID)
+	if err := h.Service.RestoreTrack(r.Context(), userID, trackID); err != nil {
+		writeCatalogError(w, err, "Failed to restore track")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CatalogHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	trackID := chi.URLParam(r, "trackID")
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 50 // Default limit
+	}
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0 // Default offset
+	}
+
+	log.Printf("Handler: Getting audit log of track %s", trackID)
+	entries, err := h.Service.ListAuditLog(r.Context(), trackID, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to get audit log", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []models.TrackAuditEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entries)
+}
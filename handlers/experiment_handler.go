@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+type ExperimentHandler struct {
+	Service *services.ExperimentService
+}
+
+func NewExperimentHandler(service *services.ExperimentService) *ExperimentHandler {
+	return &ExperimentHandler{Service: service}
+}
+
+func (h *ExperimentHandler) ListExperiments(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handler: Admin listing experiments")
+	experiments := h.Service.ListExperiments()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(experiments)
+}
+
+func (h *ExperimentHandler) GetExperimentSummary(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "experiment")
+
+	log.Printf("Handler: Admin summarizing experiment '%s'", name)
+	summary, err := h.Service.Summary(r.Context(), name)
+	if err != nil {
+		if err.Error() == "experiment not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to summarize experiment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(summary)
+}
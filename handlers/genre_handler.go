@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+type GenreHandler struct {
+	Service            *services.GenreService
+	InteractionService *services.InteractionService
+	ArtistService      *services.ArtistService
+}
+
+func NewGenreHandler(service *services.GenreService, interactionService *services.InteractionService, artistService *services.ArtistService) *GenreHandler {
+	return &GenreHandler{Service: service, InteractionService: interactionService, ArtistService: artistService}
+}
+
+func (h *GenreHandler) ListGenres(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handler: Listing genres")
+	genres, err := h.Service.ListGenres(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list genres", http.StatusInternalServerError)
+		return
+	}
+	if genres == nil {
+		genres = []models.Genre{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(genres)
+}
+
+func (h *GenreHandler) ListGenreTracks(w http.ResponseWriter, r *http.Request) {
+	genre := chi.URLParam(r, "genre")
+	sortBy := r.URL.Query().Get("sort_by")
+	order := r.URL.Query().Get("order")
+	page := parsePageRequest(r, 20, 0)
+	// Subgenres are included unless explicitly turned off.
+	includeSubgenres := r.URL.Query().Get("include_subgenres") != "false"
+	filter, err := parseTrackFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Handler: Handling list tracks of genre '%s' with limit %d, offset %d, and sort by %s", genre, page.Limit, page.Offset, sortBy)
+
+	tracks, err := h.Service.ListTracks(r.Context(), genre, includeSubgenres, filter, page, sortBy, order)
+	if err != nil {
+		switch {
+		case err.Error() == "genre not found":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.HasPrefix(err.Error(), "invalid sort field"), errors.Is(err, models.ErrInvalidFilter), errors.Is(err, models.ErrInvalidCursor):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to list genre tracks", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	trackResponses := buildTrackResponsePage(r, h.InteractionService, h.ArtistService, tracks)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(trackResponses)
+}
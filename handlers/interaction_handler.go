@@ -5,24 +5,32 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/kiasoh/basic-spotify-backend/middleware"
+	"github.com/kiasoh/basic-spotify-backend/models"
 	"github.com/kiasoh/basic-spotify-backend/services"
 )
 
 type InteractionHandler struct {
-	Service *services.InteractionService
+	Service       *services.InteractionService
+	ArtistService *services.ArtistService
 }
 
-func NewInteractionHandler(service *services.InteractionService) *InteractionHandler {
-	return &InteractionHandler{Service: service}
+func NewInteractionHandler(service *services.InteractionService, artistService *services.ArtistService) *InteractionHandler {
+	return &InteractionHandler{Service: service, ArtistService: artistService}
 }
 
 type createInteractionRequest struct {
 	Type string `json:"type"` // e.g., "like", "play"
 }
 
+type trackStateResponse struct {
+	TrackID          string                       `json:"track_id"`
+	InteractionState models.TrackInteractionState `json:"interaction_state"`
+}
+
 // NOTE: This helper is duplicated from playlist_handler.go. It could be moved to a shared package.
 func getUserIDFromContext(r *http.Request) (int, error) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
@@ -56,7 +64,14 @@ func (h *InteractionHandler) CreateInteraction(w http.ResponseWriter, r *http.Re
 
 	err = h.Service.CreateInteraction(r.Context(), userID, trackID, req.Type)
 	if err != nil {
-		http.Error(w, "Failed to create interaction", http.StatusInternalServerError)
+		switch err.Error() {
+		case "invalid interaction type":
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case "track not found":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to create interaction", http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -70,9 +85,15 @@ func (h *InteractionHandler) GetInteractionsForTrack(w http.ResponseWriter, r *h
 		return
 	}
 
+	page := parsePageRequest(r, 50, 100)
+
 	log.Printf("Handler: Getting interactions for track %s", trackID)
-	interactions, err := h.Service.GetInteractionsForTrack(r.Context(), trackID)
+	interactions, err := h.Service.GetInteractionsForTrack(r.Context(), trackID, page)
 	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to get interactions", http.StatusInternalServerError)
 		return
 	}
@@ -81,3 +102,110 @@ func (h *InteractionHandler) GetInteractionsForTrack(w http.ResponseWriter, r *h
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(interactions)
 }
+
+func (h *InteractionHandler) GetTrackStats(w http.ResponseWriter, r *http.Request) {
+	trackID := chi.URLParam(r, "trackID")
+	if trackID == "" {
+		http.Error(w, "Invalid track ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Handler: Getting stats for track %s", trackID)
+	stats, err := h.Service.GetTrackStats(r.Context(), trackID)
+	if err != nil {
+		if err.Error() == "track not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get track stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// setTrackState serves the idempotent like/dislike endpoints; set picks the service call.
+func (h *InteractionHandler) setTrackState(w http.ResponseWriter, r *http.Request, set func(userID int, trackID string) (models.TrackInteractionState, error)) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	trackID := chi.URLParam(r, "trackID")
+	if trackID == "" {
+		http.Error(w, "Invalid track ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Handler: User %d sending %s %s", userID, r.Method, r.URL.Path)
+	state, err := set(userID, trackID)
+	if err != nil {
+		if err.Error() == "track not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to update track state", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(trackStateResponse{TrackID: trackID, InteractionState: state})
+}
+
+func (h *InteractionHandler) LikeTrack(w http.ResponseWriter, r *http.Request) {
+	h.setTrackState(w, r, func(userID int, trackID string) (models.TrackInteractionState, error) {
+		return h.Service.SetLiked(r.Context(), userID, trackID, true)
+	})
+}
+
+func (h *InteractionHandler) UnlikeTrack(w http.ResponseWriter, r *http.Request) {
+	h.setTrackState(w, r, func(userID int, trackID string) (models.TrackInteractionState, error) {
+		return h.Service.SetLiked(r.Context(), userID, trackID, false)
+	})
+}
+
+func (h *InteractionHandler) DislikeTrack(w http.ResponseWriter, r *http.Request) {
+	h.setTrackState(w, r, func(userID int, trackID string) (models.TrackInteractionState, error) {
+		return h.Service.SetDisliked(r.Context(), userID, trackID, true)
+	})
+}
+
+func (h *InteractionHandler) UndislikeTrack(w http.ResponseWriter, r *http.Request) {
+	h.setTrackState(w, r, func(userID int, trackID string) (models.TrackInteractionState, error) {
+		return h.Service.SetDisliked(r.Context(), userID, trackID, false)
+	})
+}
+
+func (h *InteractionHandler) ListLikedTracks(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 20 // Default limit
+	}
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0 // Default offset
+	}
+
+	log.Printf("Handler: User %d listing liked tracks", userID)
+	tracks, err := h.Service.ListLikedTracks(r.Context(), userID, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to list liked tracks", http.StatusInternalServerError)
+		return
+	}
+
+	trackResponses := buildTrackResponses(r, h.Service, h.ArtistService, tracks)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(trackResponses)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+type MoodHandler struct {
+	Service            *services.MoodService
+	InteractionService *services.InteractionService
+	ArtistService      *services.ArtistService
+}
+
+func NewMoodHandler(service *services.MoodService, interactionService *services.InteractionService, artistService *services.ArtistService) *MoodHandler {
+	return &MoodHandler{Service: service, InteractionService: interactionService, ArtistService: artistService}
+}
+
+type generateMoodPlaylistRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+	Limit       int     `json:"limit"`
+}
+
+func (h *MoodHandler) ListMoods(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handler: Listing moods")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.Service.ListMoods())
+}
+
+func (h *MoodHandler) ListMoodTracks(w http.ResponseWriter, r *http.Request) {
+	mood := chi.URLParam(r, "mood")
+	sortBy := r.URL.Query().Get("sort_by")
+	order := r.URL.Query().Get("order")
+	page := parsePageRequest(r, 20, 0)
+	filter, err := parseTrackFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Handler: Handling list tracks of mood '%s' with limit %d, offset %d, and sort by %s", mood, page.Limit, page.Offset, sortBy)
+
+	tracks, err := h.Service.ListTracks(r.Context(), mood, filter, page, sortBy, order)
+	if err != nil {
+		switch {
+		case err.Error() == "mood not found":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case strings.HasPrefix(err.Error(), "invalid sort field"), errors.Is(err, models.ErrInvalidFilter), errors.Is(err, models.ErrInvalidCursor):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to list mood tracks", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	trackResponses := buildTrackResponsePage(r, h.InteractionService, h.ArtistService, tracks)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(trackResponses)
+}
+
+func (h *MoodHandler) GeneratePlaylist(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	mood := chi.URLParam(r, "mood")
+
+	var req generateMoodPlaylistRequest
+	// Every field is optional, so an empty body is fine.
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if req.Limit <= 0 || req.Limit > services.MaxMoodPlaylistTracks {
+		req.Limit = 30 // Default playlist length
+	}
+
+	log.Printf("Handler: User %d generating a '%s' playlist", userID, mood)
+	playlist, tracks, err := h.Service.GeneratePlaylist(r.Context(), userID, mood, req.Name, req.Description, req.Limit)
+	if err != nil {
+		switch err.Error() {
+		case "mood not found", "user not found", "no tracks match this mood":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case "similarity index is not ready":
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			http.Error(w, "Failed to generate playlist", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	generated := models.GeneratedPlaylist{
+		Playlist: playlist,
+		Tracks:   buildTrackResponses(r, h.InteractionService, h.ArtistService, tracks),
+	}
+	for _, track := range tracks {
+		generated.DurationMs += track.DurationMs
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(generated)
+}
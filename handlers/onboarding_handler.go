@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+type OnboardingHandler struct {
+	Service            *services.OnboardingService
+	InteractionService *services.InteractionService
+	ArtistService      *services.ArtistService
+}
+
+func NewOnboardingHandler(service *services.OnboardingService, interactionService *services.InteractionService, artistService *services.ArtistService) *OnboardingHandler {
+	return &OnboardingHandler{Service: service, InteractionService: interactionService, ArtistService: artistService}
+}
+
+func (h *OnboardingHandler) ListGenres(w http.ResponseWriter, r *http.Request) {
+	log.Println("Handler: Listing onboarding genres")
+	genres, err := h.Service.ListGenres(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list genres", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(genres)
+}
+
+func (h *OnboardingHandler) SampleTracks(w http.ResponseWriter, r *http.Request) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > services.MaxOnboardingSamples {
+		limit = 20 // Default number of sample tracks
+	}
+	var genres []string
+	for _, genre := range strings.Split(r.URL.Query().Get("genres"), ",") {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			genres = append(genres, genre)
+		}
+	}
+	if len(genres) > models.MaxOnboardingGenres {
+		http.Error(w, "Too many genres", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Handler: Sampling %d onboarding tracks from genres %v", limit, genres)
+	tracks, err := h.Service.SampleTracks(r.Context(), genres, limit)
+	if err != nil {
+		if err.Error() == "genre not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to sample tracks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(buildTrackResponses(r, h.InteractionService, h.ArtistService, tracks))
+}
+
+func (h *OnboardingHandler) Complete(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req models.OnboardingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	log.Printf("Handler: User %d completing onboarding", userID)
+	result, err := h.Service.Complete(r.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidOnboarding):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err.Error() == "user not found":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err.Error() == "user already onboarded":
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err.Error() == "similarity index is not ready":
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			http.Error(w, "Failed to complete onboarding", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
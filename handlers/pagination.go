@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+// parsePageRequest reads limit, offset, cursor and with_total from the query string.
+// A missing or invalid limit becomes defaultLimit; maxLimit <= 0 leaves it uncapped.
+func parsePageRequest(r *http.Request, defaultLimit int, maxLimit int) models.PageRequest {
+	query := r.URL.Query()
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || (maxLimit > 0 && limit > maxLimit) {
+		limit = defaultLimit
+	}
+	offset, err := strconv.Atoi(query.Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0 // Default offset
+	}
+	withTotal, _ := strconv.ParseBool(query.Get("with_total"))
+
+	return models.PageRequest{
+		Limit:     limit,
+		Offset:    offset,
+		Cursor:    query.Get("cursor"),
+		WithTotal: withTotal,
+	}
+}
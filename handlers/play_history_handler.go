@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+type PlayHistoryHandler struct {
+	Service *services.PlayHistoryService
+}
+
+func NewPlayHistoryHandler(service *services.PlayHistoryService) *PlayHistoryHandler {
+	return &PlayHistoryHandler{Service: service}
+}
+
+type startPlaySessionRequest struct {
+	TrackID     string                 `json:"track_id"`
+	ContextType models.PlayContextType `json:"context_type"` // e.g., "playlist", "search"
+	ContextID   *string                `json:"context_id"`   // e.g., the playlist ID
+}
+
+type playPositionRequest struct {
+	PositionMs int64 `json:"position_ms"`
+}
+
+// writePlayHistoryError maps service errors to HTTP status codes.
+func writePlayHistoryError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case err.Error() == "play session not found" || err.Error() == "track not found":
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case strings.HasPrefix(err.Error(), "forbidden:"):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case err.Error() == "play session already ended":
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err.Error() == "invalid context type":
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+func (h *PlayHistoryHandler) StartSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req startPlaySessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TrackID == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	log.Printf("Handler: User %d starting play session for track %s", userID, req.TrackID)
+	session, err := h.Service.StartSession(r.Context(), userID, req.TrackID, req.ContextType, req.ContextID)
+	if err != nil {
+		writePlayHistoryError(w, err, "Failed to start play session")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+func (h *PlayHistoryHandler) RecordProgress(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	sessionID, _ := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if sessionID == 0 {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	var req playPositionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	err = h.Service.RecordProgress(r.Context(), userID, sessionID, req.PositionMs)
+	if err != nil {
+		writePlayHistoryError(w, err, "Failed to record progress")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *PlayHistoryHandler) EndSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	sessionID, _ := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if sessionID == 0 {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	var req playPositionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	log.Printf("Handler: User %d ending play session %d", userID, sessionID)
+	session, err := h.Service.EndSession(r.Context(), userID, sessionID, req.PositionMs)
+	if err != nil {
+		writePlayHistoryError(w, err, "Failed to end play session")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(session)
+}
+
+func (h *PlayHistoryHandler) ListHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 20 // Default limit
+	}
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0 // Default offset
+	}
+
+	log.Printf("Handler: User %d listing play history", userID)
+	history, err := h.Service.ListHistory(r.Context(), userID, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to get play history", http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []models.PlayHistoryEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(history)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+type PlaylistGeneratorHandler struct {
+	Service            *services.PlaylistGeneratorService
+	InteractionService *services.InteractionService
+	ArtistService      *services.ArtistService
+}
+
+func NewPlaylistGeneratorHandler(service *services.PlaylistGeneratorService, interactionService *services.InteractionService, artistService *services.ArtistService) *PlaylistGeneratorHandler {
+	return &PlaylistGeneratorHandler{Service: service, InteractionService: interactionService, ArtistService: artistService}
+}
+
+func (h *PlaylistGeneratorHandler) GeneratePlaylist(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req models.GeneratePlaylistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	log.Printf("Handler: User %d generating a playlist (save: %t)", userID, req.Save)
+	playlist, tracks, err := h.Service.Generate(r.Context(), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrInvalidGenerateRequest):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err.Error() == "no tracks match the request":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err.Error() == "similarity index is not ready":
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			http.Error(w, "Failed to generate playlist", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	generated := models.GeneratedPlaylist{
+		Playlist: playlist,
+		Tracks:   buildTrackResponses(r, h.InteractionService, h.ArtistService, tracks),
+	}
+	for _, track := range tracks {
+		generated.DurationMs += track.DurationMs
+	}
+
+	status := http.StatusOK
+	if playlist != nil {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(generated)
+}
@@ -2,13 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"log"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/kiasoh/basic-spotify-backend/middleware" // Added
-	"github.com/kiasoh/basic-spotify-backend/models"     // Added
+	"github.com/kiasoh/basic-spotify-backend/models"
 	"github.com/kiasoh/basic-spotify-backend/services"
 )
 
@@ -133,6 +135,10 @@ func (h *PlaylistHandler) AddTrackToPlaylist(w http.ResponseWriter, r *http.Requ
 			http.Error(w, err.Error(), http.StatusForbidden)
 			return
 		}
+		if err.Error() == "track not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Failed to add track to playlist", http.StatusInternalServerError)
 		return
 	}
@@ -174,44 +180,140 @@ func (h *PlaylistHandler) GetTracksInPlaylist(w http.ResponseWriter, r *http.Req
 	}
 
 	log.Printf("Handler: Getting tracks for playlist %d", playlistID)
-	tracks, err := h.Service.GetTracksInPlaylist(r.Context(), playlistID)
+	// The version is read before the tracks, so a concurrent change can only pair newer
+	// tracks with an older ETag, never older tracks with a newer one.
+	playlist, err := h.Service.GetPlaylist(r.Context(), playlistID)
 	if err != nil {
+		if err.Error() == "playlist not found" {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get playlist", http.StatusInternalServerError)
+		return
+	}
+
+	page, err := h.Service.ListTracksInPlaylist(r.Context(), playlistID, parsePageRequest(r, 20, 0))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to get tracks in playlist", http.StatusInternalServerError)
 		return
 	}
 
-	// Prepare response with interaction states if user is authenticated
-	trackResponses := make([]models.SpotifyTrackResponse, len(tracks))
-	userID, _ := r.Context().Value(middleware.UserIDKey).(int) // Get userID, 0 if not present
+	// Prepare response with artists and, if user is authenticated, interaction states
+	trackResponses := buildTrackResponsePage(r, h.Service.InteractionService, h.Service.ArtistService, page)
 
-	if userID != 0 { // User is authenticated, fetch interaction states
-		trackIDs := make([]string, len(tracks))
-		for i, track := range tracks {
-			trackIDs[i] = track.TrackID
-		}
+	trackIDs := make([]string, len(page.Items))
+	for i, track := range page.Items {
+		trackIDs[i] = track.TrackID
+	}
+	reasons, err := h.Service.GetTrackReasons(r.Context(), playlistID, trackIDs)
+	if err != nil {
+		log.Printf("Handler: Error getting track reasons for playlist %d: %v", playlistID, err)
+		// Continue without reasons if there's an error
+	}
+	for i := range trackResponses.Items {
+		trackResponses.Items[i].Reason = reasons[trackResponses.Items[i].TrackID]
+	}
 
-		interactionStates, err := h.Service.InteractionService.GetTrackInteractionStates(r.Context(), userID, trackIDs)
-		if err != nil {
-			log.Printf("Handler: Error getting interaction states for user %d: %v", userID, err)
-			// Continue without interaction states, as per requirement
-		}
+	w.Header().Set("ETag", playlistETag(playlist, trackResponses.Items))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(trackResponses)
+}
 
-		for i, track := range tracks {
-			trackResponses[i].SpotifyTrack = track
-			if state, ok := interactionStates[track.TrackID]; ok {
-				trackResponses[i].InteractionState = state
-			} else {
-				trackResponses[i].InteractionState = models.TrackStateNeutral // Default to neutral
-			}
-		}
-	} else { // User is not authenticated, just populate SpotifyTrack
-		for i, track := range tracks {
-			trackResponses[i].SpotifyTrack = track
-			// InteractionState will be omitted due to omitempty tag
+// playlistETag is a weak ETag for a page of a playlist's tracks. It changes with the
+// playlist's version, i.e. when tracks are added, removed or reordered, and with the
+// interaction states shown to the caller, but not when track details are edited.
+func playlistETag(playlist *models.Playlist, tracks []models.SpotifyTrackResponse) string {
+	h := fnv.New64a()
+	for _, track := range tracks {
+		h.Write([]byte(track.InteractionState))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf(`W/"%d-%d-%x"`, playlist.ID, playlist.Version, h.Sum64())
+}
+
+type applySequenceRequest struct {
+	TrackIDs []string `json:"track_ids"`
+}
+
+func (h *PlaylistHandler) ProposeSequence(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	playlistID, _ := strconv.Atoi(chi.URLParam(r, "playlistID"))
+	if playlistID == 0 {
+		http.Error(w, "Invalid playlist ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Handler: User %d proposing a sequence for playlist %d", userID, playlistID)
+	proposal, err := h.Service.ProposeSequence(r.Context(), userID, playlistID)
+	if err != nil {
+		switch err.Error() {
+		case "playlist not found":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case "forbidden: you do not own this playlist", "forbidden: this playlist is not modifiable":
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case "playlist is too long to sequence":
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to sequence playlist", http.StatusInternalServerError)
 		}
+		return
+	}
+
+	tracks := make([]models.SpotifyTrack, len(proposal.Tracks))
+	for i, track := range proposal.Tracks {
+		tracks[i] = track.SpotifyTrack
 	}
+	proposal.Tracks = buildTrackResponses(r, h.Service.InteractionService, h.Service.ArtistService, tracks)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(trackResponses)
+	json.NewEncoder(w).Encode(proposal)
+}
+
+func (h *PlaylistHandler) ApplySequence(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	playlistID, _ := strconv.Atoi(chi.URLParam(r, "playlistID"))
+	if playlistID == 0 {
+		http.Error(w, "Invalid playlist ID", http.StatusBadRequest)
+		return
+	}
+
+	var req applySequenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	log.Printf("Handler: User %d applying a sequence to playlist %d", userID, playlistID)
+	err = h.Service.ApplySequence(r.Context(), userID, playlistID, req.TrackIDs)
+	if err != nil {
+		switch err.Error() {
+		case "playlist not found":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case "forbidden: you do not own this playlist", "forbidden: this playlist is not modifiable":
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case "playlist changed":
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Failed to apply sequence", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+type RecommendationHandler struct {
+	Service            *services.RecommendationService
+	InteractionService *services.InteractionService
+	ArtistService      *services.ArtistService
+}
+
+func NewRecommendationHandler(service *services.RecommendationService, interactionService *services.InteractionService, artistService *services.ArtistService) *RecommendationHandler {
+	return &RecommendationHandler{Service: service, InteractionService: interactionService, ArtistService: artistService}
+}
+
+func (h *RecommendationHandler) ListRecommendations(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	strategy := r.URL.Query().Get("strategy") // empty: chosen by the service
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > services.MaxRecommendations {
+		limit = 20 // Default number of recommendations
+	}
+
+	log.Printf("Handler: User %d listing %d recommendations with strategy '%s'", userID, limit, strategy)
+	list, err := h.Service.Recommend(r.Context(), userID, strategy, limit)
+	if err != nil {
+		switch err.Error() {
+		case "invalid strategy":
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case "user not found":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case "similarity index is not ready":
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			http.Error(w, "Failed to get recommendations", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	tracks := make([]models.SpotifyTrack, len(list.Recommendations))
+	for i, rec := range list.Recommendations {
+		tracks[i] = rec.Track.SpotifyTrack
+	}
+	for i, trackResponse := range buildTrackResponses(r, h.InteractionService, h.ArtistService, tracks) {
+		list.Recommendations[i].Track = trackResponse
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(list)
+}
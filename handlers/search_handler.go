@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+type SearchHandler struct {
+	Service            *services.SearchService
+	SuggestService     *services.SuggestService
+	InteractionService *services.InteractionService
+	ArtistService      *services.ArtistService
+}
+
+func NewSearchHandler(service *services.SearchService, suggestService *services.SuggestService, interactionService *services.InteractionService, artistService *services.ArtistService) *SearchHandler {
+	return &SearchHandler{Service: service, SuggestService: suggestService, InteractionService: interactionService, ArtistService: artistService}
+}
+
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 50 {
+		limit = 10 // Default limit per result group
+	}
+
+	log.Printf("Handler: Handling search request for query '%s' with limit %d", query, limit)
+	results, err := h.Service.Search(r.Context(), query, limit)
+	if err != nil {
+		if err.Error() == "query is required" || err.Error() == "query is too long" {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to search", http.StatusInternalServerError)
+		return
+	}
+
+	// Enrich the track hits like any other track listing.
+	tracks := make([]models.SpotifyTrack, len(results.Tracks))
+	for i, hit := range results.Tracks {
+		tracks[i] = hit.Track.SpotifyTrack
+	}
+	for i, trackResponse := range buildTrackResponses(r, h.InteractionService, h.ArtistService, tracks) {
+		results.Tracks[i].Track = trackResponse
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(results)
+}
+
+type suggestResponse struct {
+	Query       string              `json:"query"`
+	Suggestions []models.Suggestion `json:"suggestions"`
+}
+
+func (h *SearchHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > services.MaxSuggestions {
+		limit = 8 // Default number of suggestions
+	}
+
+	suggestions, err := h.SuggestService.Suggest(r.Context(), query, limit)
+	if err != nil {
+		if err.Error() == "query is required" || err.Error() == "query is too long" {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to get suggestions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// Suggestions are the same for every user; let clients reuse them while typing.
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(suggestResponse{Query: query, Suggestions: suggestions})
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+type SimilarityHandler struct {
+	Service            *services.SimilarityService
+	InteractionService *services.InteractionService
+	ArtistService      *services.ArtistService
+}
+
+func NewSimilarityHandler(service *services.SimilarityService, interactionService *services.InteractionService, artistService *services.ArtistService) *SimilarityHandler {
+	return &SimilarityHandler{Service: service, InteractionService: interactionService, ArtistService: artistService}
+}
+
+func (h *SimilarityHandler) ListSimilarTracks(w http.ResponseWriter, r *http.Request) {
+	trackID := chi.URLParam(r, "trackID")
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > services.MaxSimilarTracks {
+		limit = 10 // Default number of similar tracks
+	}
+	opts := models.SimilarityOptions{
+		Limit:         limit,
+		SameGenre:     r.URL.Query().Get("same_genre") == "true",
+		ExcludeArtist: r.URL.Query().Get("exclude_artist") == "true",
+	}
+
+	log.Printf("Handler: Handling similar tracks request for track %s with limit %d", trackID, limit)
+	similar, err := h.Service.SimilarTracks(r.Context(), trackID, opts)
+	if err != nil {
+		switch err.Error() {
+		case "track not found":
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case "similarity index is not ready":
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			http.Error(w, "Failed to get similar tracks", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	tracks := make([]models.SpotifyTrack, len(similar))
+	for i, match := range similar {
+		tracks[i] = match.Track.SpotifyTrack
+	}
+	for i, trackResponse := range buildTrackResponses(r, h.InteractionService, h.ArtistService, tracks) {
+		similar[i].Track = trackResponse
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(similar)
+}
@@ -2,14 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/kiasoh/basic-spotify-backend/middleware" // Added
-	"github.com/kiasoh/basic-spotify-backend/models"     // Added
+	"github.com/kiasoh/basic-spotify-backend/models" // Added
 	"github.com/kiasoh/basic-spotify-backend/services"
 )
 
@@ -21,41 +20,6 @@ func NewSpotifyTrackHandler(service *services.SpotifyTrackService) *SpotifyTrack
 	return &SpotifyTrackHandler{Service: service}
 }
 
-// enrichTracksWithInteractionState processes a slice of SpotifyTrack and returns SpotifyTrackResponse
-// with interaction states if a user ID is present in the request context.
-func (h *SpotifyTrackHandler) enrichTracksWithInteractionState(r *http.Request, tracks []models.SpotifyTrack) ([]models.SpotifyTrackResponse, error) {
-	trackResponses := make([]models.SpotifyTrackResponse, len(tracks))
-	userID, _ := r.Context().Value(middleware.UserIDKey).(int) // Get userID, 0 if not present
-
-	if userID != 0 { // User is authenticated, fetch interaction states
-		trackIDs := make([]string, len(tracks))
-		for i, track := range tracks {
-			trackIDs[i] = track.TrackID
-		}
-
-		interactionStates, err := h.Service.InteractionService.GetTrackInteractionStates(r.Context(), userID, trackIDs)
-		if err != nil {
-			log.Printf("Handler: Error getting interaction states for user %d: %v", userID, err)
-			// Continue without interaction states if there's an error
-		}
-
-		for i, track := range tracks {
-			trackResponses[i].SpotifyTrack = track
-			if state, ok := interactionStates[track.TrackID]; ok {
-				trackResponses[i].InteractionState = state
-			} else {
-				trackResponses[i].InteractionState = models.TrackStateNeutral // Default to neutral
-			}
-		}
-	} else { // User is not authenticated, just populate SpotifyTrack
-		for i, track := range tracks {
-			trackResponses[i].SpotifyTrack = track
-			// InteractionState will be omitted due to omitempty tag
-		}
-	}
-	return trackResponses, nil
-}
-
 func (h *SpotifyTrackHandler) GetByTrackID(w http.ResponseWriter, r *http.Request) {
 	trackID := chi.URLParam(r, "trackID")
 
@@ -67,26 +31,8 @@ func (h *SpotifyTrackHandler) GetByTrackID(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Prepare response with interaction states if user is authenticated
-	var trackResponse models.SpotifyTrackResponse
-	trackResponse.SpotifyTrack = *track // Dereference the pointer
-
-	userID, _ := r.Context().Value(middleware.UserIDKey).(int) // Get userID, 0 if not present
-
-	if userID != 0 { // User is authenticated, fetch interaction state
-		interactionStates, err := h.Service.InteractionService.GetTrackInteractionStates(r.Context(), userID, []string{trackID})
-		if err != nil {
-			log.Printf("Handler: Error getting interaction state for user %d and track %s: %v", userID, trackID, err)
-			// Continue without interaction state if there's an error
-		}
-
-		if state, ok := interactionStates[trackID]; ok {
-			trackResponse.InteractionState = state
-		} else {
-			trackResponse.InteractionState = models.TrackStateNeutral // Default to neutral
-		}
-	}
-	// If user is not authenticated, InteractionState will be omitted due to omitempty tag
+	// Prepare response with artists and, if user is authenticated, interaction state
+	trackResponse := buildTrackResponses(r, h.Service.InteractionService, h.Service.ArtistService, []models.SpotifyTrack{*track})[0]
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -94,27 +40,22 @@ func (h *SpotifyTrackHandler) GetByTrackID(w http.ResponseWriter, r *http.Reques
 }
 
 func (h *SpotifyTrackHandler) ListTracks(w http.ResponseWriter, r *http.Request) {
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
 	sortBy := r.URL.Query().Get("sort_by") // New parameter
 	order := r.URL.Query().Get("order")
+	page := parsePageRequest(r, 20, 0)
 
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 20 // Default limit
-	}
-
-	offset, err := strconv.Atoi(offsetStr)
-	if err != nil || offset < 0 {
-		offset = 0 // Default offset
+	filter, err := parseTrackFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	log.Printf("Handler: Handling list tracks request with limit %d, offset %d, and sort by %s", limit, offset, sortBy)
+	log.Printf("Handler: Handling list tracks request with limit %d, offset %d, and sort by %s", page.Limit, page.Offset, sortBy)
 
-	tracks, err := h.Service.List(r.Context(), limit, offset, sortBy, order)
+	tracks, err := h.Service.List(r.Context(), filter, page, sortBy, order)
 	if err != nil {
-		// Handle invalid sort field error
-		if err.Error() == fmt.Sprintf("invalid sort field: %s", sortBy) {
+		// Handle invalid sort field, filter and cursor errors
+		if err.Error() == fmt.Sprintf("invalid sort field: %s", sortBy) || errors.Is(err, models.ErrInvalidFilter) || errors.Is(err, models.ErrInvalidCursor) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
@@ -122,12 +63,7 @@ func (h *SpotifyTrackHandler) ListTracks(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	trackResponses, err := h.enrichTracksWithInteractionState(r, tracks)
-	if err != nil {
-		log.Printf("Handler: Error enriching tracks with interaction state in ListTracks: %v", err)
-		http.Error(w, "Failed to process tracks", http.StatusInternalServerError)
-		return
-	}
+	trackResponses := buildTrackResponsePage(r, h.Service.InteractionService, h.Service.ArtistService, tracks)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -137,30 +73,19 @@ func (h *SpotifyTrackHandler) ListTracks(w http.ResponseWriter, r *http.Request)
 func (h *SpotifyTrackHandler) SearchTracks(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	searchField := r.URL.Query().Get("field")
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
+	page := parsePageRequest(r, 20, 0)
 
 	if query == "" || searchField == "" {
 		http.Error(w, "Query (q) and search field (field) are required", http.StatusBadRequest)
 		return
 	}
 
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 20 // Default limit
-	}
-
-	offset, err := strconv.Atoi(offsetStr)
-	if err != nil || offset < 0 {
-		offset = 0 // Default offset
-	}
-
-	log.Printf("Handler: Handling search tracks request for query '%s' in field '%s' with limit %d and offset %d", query, searchField, limit, offset)
+	log.Printf("Handler: Handling search tracks request for query '%s' in field '%s' with limit %d and offset %d", query, searchField, page.Limit, page.Offset)
 
-	tracks, err := h.Service.Search(r.Context(), query, searchField, limit, offset)
+	tracks, err := h.Service.Search(r.Context(), query, searchField, page)
 	if err != nil {
-		// Specific error for invalid field from repo/service
-		if err.Error() == fmt.Sprintf("invalid search field: %s", searchField) {
+		// Specific error for invalid field or cursor from repo/service
+		if err.Error() == fmt.Sprintf("invalid search field: %s", searchField) || errors.Is(err, models.ErrInvalidCursor) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
@@ -168,12 +93,7 @@ func (h *SpotifyTrackHandler) SearchTracks(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	trackResponses, err := h.enrichTracksWithInteractionState(r, tracks)
-	if err != nil {
-		log.Printf("Handler: Error enriching tracks with interaction state in SearchTracks: %v", err)
-		http.Error(w, "Failed to process tracks", http.StatusInternalServerError)
-		return
-	}
+	trackResponses := buildTrackResponsePage(r, h.Service.InteractionService, h.Service.ArtistService, tracks)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+// parseTrackFilter reads track filters from the query string: <field>_min / <field>_max
+// for every models.TrackRangeFields entry, and explicit, key, mode, time_signature and
+// track_genre (comma separated) for equality.
+func parseTrackFilter(r *http.Request) (models.TrackFilter, error) {
+	query := r.URL.Query()
+	filter := models.TrackFilter{Ranges: make(map[string]models.Range)}
+
+	for _, field := range models.TrackRangeFields {
+		var rng models.Range
+		for suffix, bound := range map[string]**float64{"_min": &rng.Min, "_max": &rng.Max} {
+			raw := query.Get(field + suffix)
+			if raw == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+				return filter, fmt.Errorf("%w: %s%s must be a finite number", models.ErrInvalidFilter, field, suffix)
+			}
+			*bound = &value
+		}
+		if rng.Min != nil || rng.Max != nil {
+			filter.Ranges[field] = rng
+		}
+	}
+
+	if raw := query.Get("explicit"); raw != "" {
+		explicit, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("%w: explicit must be true or false", models.ErrInvalidFilter)
+		}
+		filter.Explicit = &explicit
+	}
+
+	for name, target := range map[string]**int64{"key": &filter.Key, "mode": &filter.Mode, "time_signature": &filter.TimeSignature} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("%w: %s must be an integer", models.ErrInvalidFilter, name)
+		}
+		*target = &value
+	}
+
+	if raw := query.Get("track_genre"); raw != "" {
+		for _, genre := range strings.Split(raw, ",") {
+			if genre = strings.TrimSpace(genre); genre != "" {
+				filter.Genres = append(filter.Genres, genre)
+			}
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/kiasoh/basic-spotify-backend/middleware"
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/services"
+)
+
+// buildTrackResponses turns tracks into API responses: it embeds the structured artists
+// and album of each track and, if a user ID is present in the request context, the
+// user's interaction state. Enrichment failures are logged and the plain tracks returned.
+func buildTrackResponses(r *http.Request, interactionService *services.InteractionService, artistService *services.ArtistService, tracks []models.SpotifyTrack) []models.SpotifyTrackResponse {
+	trackResponses := make([]models.SpotifyTrackResponse, len(tracks))
+	for i, track := range tracks {
+		trackResponses[i].SpotifyTrack = track
+	}
+	if len(tracks) == 0 {
+		return trackResponses
+	}
+
+	if err := artistService.AttachArtists(r.Context(), trackResponses); err != nil {
+		log.Printf("Handler: Error attaching artists to tracks: %v", err)
+	}
+
+	userID, _ := r.Context().Value(middleware.UserIDKey).(int) // Get userID, 0 if not present
+	if userID == 0 {
+		// InteractionState will be omitted due to omitempty tag
+		return trackResponses
+	}
+
+	trackIDs := make([]string, len(tracks))
+	for i, track := range tracks {
+		trackIDs[i] = track.TrackID
+	}
+	interactionStates, err := interactionService.GetTrackInteractionStates(r.Context(), userID, trackIDs)
+	if err != nil {
+		log.Printf("Handler: Error getting interaction states for user %d: %v", userID, err)
+		// Continue without interaction states if there's an error
+	}
+	for i := range trackResponses {
+		if state, ok := interactionStates[trackResponses[i].TrackID]; ok {
+			trackResponses[i].InteractionState = state
+		} else {
+			trackResponses[i].InteractionState = models.TrackStateNeutral // Default to neutral
+		}
+	}
+	return trackResponses
+}
+
+// buildTrackResponsePage is buildTrackResponses for a page of tracks.
+func buildTrackResponsePage(r *http.Request, interactionService *services.InteractionService, artistService *services.ArtistService, page *models.Page[models.SpotifyTrack]) models.Page[models.SpotifyTrackResponse] {
+	return models.Page[models.SpotifyTrackResponse]{
+		Items:      buildTrackResponses(r, interactionService, artistService, page.Items),
+		NextCursor: page.NextCursor,
+		Total:      page.Total,
+	}
+}
@@ -54,7 +54,7 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	user.Password.Plaintext = nil
 
 	// Generate and set the JWT token
-	token, err := h.authService.GenerateJWT(user.ID)
+	token, err := h.authService.GenerateJWT(user.ID, user.Role)
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
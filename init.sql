@@ -1,9 +1,12 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 CREATE TABLE IF NOT EXISTS "users" (
     "id" serial PRIMARY KEY,
     "username" varchar(255) UNIQUE NOT NULL,
     "password" varchar(255) NOT NULL,
     "avg_interest" jsonb NOT NULL DEFAULT '[]',
     "recomm_plylist_id" INTEGER,
+    "role" varchar(32) NOT NULL DEFAULT 'user' CHECK ("role" IN ('user', 'curator', 'admin')),
     "created_at" Timestamp WITH TIME ZONE NOT NULL DEFAULT now()
 );
 
@@ -13,6 +16,7 @@ CREATE TABLE IF NOT EXISTS "playlists" (
     "description" varchar(255),
     "owner_id" INTEGER NOT NULL REFERENCES "users"("id"),
     "modifyable" boolean NOT NULL DEFAULT true,
+    "version" INTEGER NOT NULL DEFAULT 1, -- bumped whenever tracks are added or removed; the playlist's weak ETag
     "created_at" Timestamp WITH TIME ZONE NOT NULL DEFAULT now()
 );
 
@@ -36,21 +40,140 @@ CREATE TABLE "spotify_tracks" (
     "valence" DOUBLE PRECISION,
     "tempo" DOUBLE PRECISION,
     "time_signature" BIGINT,
-    "track_genre" TEXT
+    "track_genre" TEXT,
+    "deleted_at" Timestamp WITH TIME ZONE
 );
 
 CREATE TABLE IF NOT EXISTS "songs_playlists" (
     "playlist_id" INTEGER NOT NULL REFERENCES "playlists"("id"),
     "track_id" TEXT NOT NULL REFERENCES "spotify_tracks"("track_id"),
+    "position" INTEGER NOT NULL DEFAULT 0,
+    "reason" TEXT, -- why the recommender picked the track; NULL for tracks added by hand
     "created_at" Timestamp WITH TIME ZONE NOT NULL DEFAULT now()
 );
 
 CREATE TABLE IF NOT EXISTS "interactions" (
+    "id" bigserial PRIMARY KEY,
     "user_id" INTEGER NOT NULL REFERENCES "users"("id"),
     "track_id" TEXT NOT NULL REFERENCES "spotify_tracks"("track_id"),
     "type" varchar(255) NOT NULL,
     "created_at" Timestamp WITH TIME ZONE NOT NULL DEFAULT now()
 );
 
+CREATE TABLE IF NOT EXISTS "user_track_state" (
+    "user_id" INTEGER NOT NULL REFERENCES "users"("id"),
+    "track_id" TEXT NOT NULL REFERENCES "spotify_tracks"("track_id"),
+    "state" varchar(16) NOT NULL DEFAULT 'neutral',
+    "updated_at" Timestamp WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY ("user_id", "track_id")
+);
+
+CREATE TABLE IF NOT EXISTS "track_stats" (
+    "track_id" TEXT PRIMARY KEY REFERENCES "spotify_tracks"("track_id"),
+    "plays" BIGINT NOT NULL DEFAULT 0,
+    "likes" BIGINT NOT NULL DEFAULT 0,
+    "dislikes" BIGINT NOT NULL DEFAULT 0,
+    "skips" BIGINT NOT NULL DEFAULT 0,
+    "playlist_adds" BIGINT NOT NULL DEFAULT 0,
+    "updated_at" Timestamp WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS "track_audit_log" (
+    "id" bigserial PRIMARY KEY,
+    "track_id" TEXT NOT NULL REFERENCES "spotify_tracks"("track_id"),
+    "actor_id" INTEGER NOT NULL REFERENCES "users"("id"),
+    "action" varchar(32) NOT NULL,
+    "changes" jsonb,
+    "created_at" Timestamp WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS "recommendation_runs" (
+    "id" serial PRIMARY KEY,
+    "user_id" INTEGER NOT NULL REFERENCES "users"("id"),
+    "playlist_id" INTEGER NOT NULL REFERENCES "playlists"("id"),
+    "model_version" varchar(255) NOT NULL,
+    "track_count" INTEGER NOT NULL,
+    "created_at" Timestamp WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS "play_sessions" (
+    "id" bigserial PRIMARY KEY,
+    "user_id" INTEGER NOT NULL REFERENCES "users"("id"),
+    "track_id" TEXT NOT NULL REFERENCES "spotify_tracks"("track_id"),
+    "context_type" varchar(32) NOT NULL DEFAULT 'unknown',
+    "context_id" varchar(255),
+    "position_ms" BIGINT NOT NULL DEFAULT 0,
+    "track_duration_ms" BIGINT NOT NULL DEFAULT 0,
+    "completion_ratio" DOUBLE PRECISION,
+    "started_at" Timestamp WITH TIME ZONE NOT NULL DEFAULT now(),
+    "updated_at" Timestamp WITH TIME ZONE NOT NULL DEFAULT now(),
+    "ended_at" Timestamp WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS "artists" (
+    "id" serial PRIMARY KEY,
+    "name" TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS "track_artists" (
+    "track_id" TEXT NOT NULL REFERENCES "spotify_tracks"("track_id"),
+    "artist_id" INTEGER NOT NULL REFERENCES "artists"("id"),
+    "position" INTEGER NOT NULL,
+    PRIMARY KEY ("track_id", "artist_id")
+);
+
+CREATE TABLE IF NOT EXISTS "albums" (
+    "id" serial PRIMARY KEY,
+    "name" TEXT NOT NULL,
+    "artist_id" INTEGER NOT NULL REFERENCES "artists"("id"),
+    UNIQUE ("name", "artist_id")
+);
+
+CREATE TABLE IF NOT EXISTS "album_tracks" (
+    "album_id" INTEGER NOT NULL REFERENCES "albums"("id"),
+    "track_id" TEXT PRIMARY KEY REFERENCES "spotify_tracks"("track_id")
+);
+
+-- Item-item collaborative filtering model, rebuilt from the interactions table.
+CREATE TABLE IF NOT EXISTS "track_neighbors" (
+    "track_id" TEXT NOT NULL REFERENCES "spotify_tracks"("track_id"),
+    "neighbor_id" TEXT NOT NULL REFERENCES "spotify_tracks"("track_id"),
+    "score" DOUBLE PRECISION NOT NULL,
+    "support" INTEGER NOT NULL,
+    PRIMARY KEY ("track_id", "neighbor_id")
+);
+
+-- First exposure of every user to their variant of an A/B experiment.
+CREATE TABLE IF NOT EXISTS "experiment_exposures" (
+    "experiment" TEXT NOT NULL,
+    "variant" TEXT NOT NULL,
+    "user_id" INTEGER NOT NULL REFERENCES "users"("id"),
+    "exposed_at" Timestamp WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY ("experiment", "user_id")
+);
+
+CREATE INDEX idx_songs_playlists_playlist_position ON songs_playlists (playlist_id, position, track_id);
+CREATE INDEX idx_recommendation_runs_user ON recommendation_runs (user_id, created_at DESC);
+CREATE INDEX idx_play_sessions_user_started ON play_sessions (user_id, started_at DESC);
+CREATE INDEX idx_track_audit_log_track ON track_audit_log (track_id, created_at DESC);
+CREATE INDEX idx_interactions_track_created ON interactions (track_id, created_at DESC, id DESC);
+CREATE INDEX idx_interactions_user_created ON interactions (user_id, created_at DESC, id DESC);
+CREATE INDEX idx_user_track_state_user_state ON user_track_state (user_id, state, updated_at DESC);
+-- Filtered track listings (GET /tracks). Every listing skips deleted tracks and sorts by
+-- popularity by default; the planner combines the per-feature indexes with bitmap scans.
+CREATE INDEX idx_spotify_tracks_genre_popularity ON spotify_tracks (track_genre, popularity DESC, track_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_spotify_tracks_popularity ON spotify_tracks (popularity DESC, track_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_spotify_tracks_energy ON spotify_tracks (energy) WHERE deleted_at IS NULL;
+CREATE INDEX idx_spotify_tracks_danceability ON spotify_tracks (danceability) WHERE deleted_at IS NULL;
+CREATE INDEX idx_spotify_tracks_valence ON spotify_tracks (valence) WHERE deleted_at IS NULL;
+CREATE INDEX idx_spotify_tracks_tempo ON spotify_tracks (tempo) WHERE deleted_at IS NULL;
+CREATE INDEX idx_spotify_tracks_duration ON spotify_tracks (duration_ms) WHERE deleted_at IS NULL;
+CREATE INDEX idx_spotify_tracks_key_mode ON spotify_tracks (key, mode) WHERE deleted_at IS NULL;
+CREATE INDEX idx_track_artists_artist ON track_artists (artist_id, track_id);
+CREATE INDEX idx_album_tracks_album ON album_tracks (album_id);
+
 CREATE INDEX idx_track_name_trgm ON spotify_tracks USING gin (track_name gin_trgm_ops);
 CREATE INDEX idx_artists_trgm ON spotify_tracks USING gin (artists gin_trgm_ops);
+CREATE INDEX idx_album_name_trgm ON spotify_tracks USING gin (album_name gin_trgm_ops);
+CREATE INDEX idx_artist_name_trgm ON artists USING gin (name gin_trgm_ops);
+CREATE INDEX idx_album_title_trgm ON albums USING gin (name gin_trgm_ops);
@@ -5,21 +5,29 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/segmentio/kafka-go"
 
+	"github.com/kiasoh/basic-spotify-backend/cache"
+	"github.com/kiasoh/basic-spotify-backend/events"
 	"github.com/kiasoh/basic-spotify-backend/handlers"
 	"github.com/kiasoh/basic-spotify-backend/middleware"
+	"github.com/kiasoh/basic-spotify-backend/models"
 	"github.com/kiasoh/basic-spotify-backend/repository"
 	"github.com/kiasoh/basic-spotify-backend/services"
 )
 
+// ConnectSQL connects to the database at DATABASE_URL, or to the docker-compose
+// database if it is unset.
 func ConnectSQL() *pgxpool.Pool {
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s",
-		"niflheim", "niflguard", "postgres_ds", "5432", "dsdb")
+	dsn := getEnv("DATABASE_URL", fmt.Sprintf("postgres://%s:%s@%s:%s/%s",
+		"niflheim", "niflguard", "postgres_ds", "5432", "dsdb"))
 
 	poolconfig, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
@@ -38,32 +46,177 @@ func ConnectSQL() *pgxpool.Pool {
 	return pool
 }
 
-func InitKafka() *kafka.Writer {
-	// TODO: Use environment variables for Kafka URL
-	kafkaURL := "194.147.142.26:9094"
-	writer := &kafka.Writer{
-		Addr:     kafka.TCP(kafkaURL),
-		Topic:    "interactions",
-		Balancer: &kafka.LeastBytes{},
+// getEnv returns the value of the environment variable key, or fallback if it is unset or empty.
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
 	}
-	log.Println("Kafka writer initialized for topic 'interactions'")
-	return writer
+	return fallback
 }
 
+// InitEventPublisher builds the interaction event sink selected by EVENT_PUBLISHER
+// (kafka, file, memory or noop). Kafka is the default.
+func InitEventPublisher() events.EventPublisher {
+	cfg := events.PublisherConfig{
+		Driver:     getEnv("EVENT_PUBLISHER", events.DriverKafka),
+		KafkaURL:   getEnv("KAFKA_URL", "194.147.142.26:9094"),
+		KafkaTopic: getEnv("KAFKA_INTERACTIONS_TOPIC", "interactions"),
+		FilePath:   getEnv("EVENTS_FILE_PATH", "interactions.ndjson"),
+	}
+	publisher, err := events.NewPublisher(cfg)
+	if err != nil {
+		log.Fatalf("Unable to initialize event publisher: %v", err)
+	}
+	log.Printf("Event publisher initialized with driver '%s'", cfg.Driver)
+	return publisher
+}
+
+// InitRecommendationConsumer subscribes to the topic the recommender writes its results to.
+// It returns nil when RECOMMENDATIONS_CONSUMER is set to anything other than kafka.
+func InitRecommendationConsumer() *events.KafkaConsumer {
+	if getEnv("RECOMMENDATIONS_CONSUMER", events.DriverKafka) != events.DriverKafka {
+		log.Println("Recommendation consumer disabled")
+		return nil
+	}
+	topic := getEnv("KAFKA_RECOMMENDATIONS_TOPIC", "recommendations")
+	consumer := events.NewKafkaConsumer(
+		getEnv("KAFKA_URL", "194.147.142.26:9094"),
+		topic,
+		getEnv("KAFKA_CONSUMER_GROUP", "basic-spotify-backend"),
+	)
+	log.Printf("Kafka consumer initialized for topic '%s'", topic)
+	return consumer
+}
+
+// InitGenreTaxonomy loads the genre hierarchy from GENRE_TAXONOMY_PATH, a JSON object
+// mapping each genre to its parent, or falls back to the built-in one.
+func InitGenreTaxonomy() models.GenreTaxonomy {
+	path := getEnv("GENRE_TAXONOMY_PATH", "")
+	taxonomy, err := services.LoadGenreTaxonomy(path)
+	if err != nil {
+		log.Fatalf("Unable to load genre taxonomy: %v", err)
+	}
+	log.Printf("Genre taxonomy loaded with %d subgenres", len(taxonomy))
+	return taxonomy
+}
+
+// InitMoods loads the moods from MOODS_PATH, a JSON array of moods, or falls back to
+// the built-in ones.
+func InitMoods() []models.Mood {
+	path := getEnv("MOODS_PATH", "")
+	moods, err := services.LoadMoods(path)
+	if err != nil {
+		log.Fatalf("Unable to load moods: %v", err)
+	}
+	log.Printf("Loaded %d moods", len(moods))
+	return moods
+}
+
+// InitNeighborRebuildInterval reads how often the collaborative filtering model is
+// rebuilt from NEIGHBOR_REBUILD_INTERVAL (a Go duration such as "1h"), defaulting to an hour.
+func InitNeighborRebuildInterval() time.Duration {
+	raw := getEnv("NEIGHBOR_REBUILD_INTERVAL", "1h")
+	interval, err := time.ParseDuration(raw)
+	if err != nil || interval <= 0 {
+		log.Fatalf("Invalid NEIGHBOR_REBUILD_INTERVAL %q", raw)
+	}
+	return interval
+}
+
+// InitExperiments loads the A/B experiments from EXPERIMENTS_PATH, a JSON array of
+// experiments. Without it no experiments run.
+func InitExperiments() []models.Experiment {
+	path := getEnv("EXPERIMENTS_PATH", "")
+	experiments, err := services.LoadExperiments(path)
+	if err != nil {
+		log.Fatalf("Unable to load experiments: %v", err)
+	}
+	log.Printf("Loaded %d experiments", len(experiments))
+	return experiments
+}
+
+// InitCache builds the cache selected by CACHE_DRIVER: "memory" (the default, an
+// in-process LRU of CACHE_MEMORY_ENTRIES entries), "redis" (at REDIS_ADDR) or "none".
+func InitCache() cache.Cache {
+	entries, err := strconv.Atoi(getEnv("CACHE_MEMORY_ENTRIES", "10000"))
+	if err != nil {
+		log.Fatalf("Invalid CACHE_MEMORY_ENTRIES: %v", err)
+	}
+	db, err := strconv.Atoi(getEnv("REDIS_DB", "0"))
+	if err != nil {
+		log.Fatalf("Invalid REDIS_DB: %v", err)
+	}
+	c, err := cache.New(cache.Config{
+		Driver:        getEnv("CACHE_DRIVER", cache.DriverMemory),
+		MemoryEntries: entries,
+		RedisAddr:     getEnv("REDIS_ADDR", ""),
+		RedisPassword: getEnv("REDIS_PASSWORD", ""),
+		RedisDB:       db,
+	})
+	if err != nil {
+		log.Fatalf("Unable to create cache: %v", err)
+	}
+	return c
+}
+
+// InitCacheTTL reads how long entries live in the cache from the environment variable
+// key (a Go duration such as "10m"), defaulting to fallback.
+func InitCacheTTL(key, fallback string) time.Duration {
+	raw := getEnv(key, fallback)
+	ttl, err := time.ParseDuration(raw)
+	if err != nil || ttl <= 0 {
+		log.Fatalf("Invalid %s %q", key, raw)
+	}
+	return ttl
+}
+
+// InitAdmin makes ADMIN_USERNAME an administrator, registering it with ADMIN_PASSWORD
+// if it does not exist yet. Without ADMIN_USERNAME nothing happens.
+func InitAdmin(ctx context.Context, userService *services.UserService) {
+	username := os.Getenv("ADMIN_USERNAME")
+	if username == "" {
+		return
+	}
+	if _, err := userService.EnsureAdmin(ctx, username, os.Getenv("ADMIN_PASSWORD")); err != nil {
+		log.Fatalf("Failed to set up admin user %q: %v", username, err)
+	}
+	log.Printf("User %q is an administrator", username)
+}
+
+// trackInteractionsDeprecated and trackInteractionsSunset bound the life of the
+// deprecated GET /tracks/{trackID}/interactions, now GET /admin/tracks/{trackID}/interactions.
+var (
+	trackInteractionsDeprecated = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	trackInteractionsSunset     = time.Date(2027, time.January, 19, 0, 0, 0, 0, time.UTC)
+)
+
 func InitRoutes(
 	userHandler *handlers.UserHandler,
 	authHandler *handlers.AuthHandler,
 	trackHandler *handlers.SpotifyTrackHandler,
 	playlistHandler *handlers.PlaylistHandler,
 	interactionHandler *handlers.InteractionHandler,
+	playHistoryHandler *handlers.PlayHistoryHandler,
+	adminHandler *handlers.AdminHandler,
+	catalogHandler *handlers.CatalogHandler,
+	artistHandler *handlers.ArtistHandler,
+	genreHandler *handlers.GenreHandler,
+	searchHandler *handlers.SearchHandler,
+	similarityHandler *handlers.SimilarityHandler,
+	moodHandler *handlers.MoodHandler,
+	playlistGeneratorHandler *handlers.PlaylistGeneratorHandler,
+	onboardingHandler *handlers.OnboardingHandler,
+	recommendationHandler *handlers.RecommendationHandler,
+	experimentHandler *handlers.ExperimentHandler,
+	roles middleware.RoleSource,
 ) http.Handler {
 	mux := chi.NewRouter()
 
 	mux.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag", "Deprecation", "Sunset"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -71,14 +224,28 @@ func InitRoutes(
 	// Public routes (fully unauthenticated, e.g., for basic registration/login)
 	mux.Post("/register", userHandler.Register)
 	mux.Post("/login", authHandler.Login)
+	mux.Get("/search/suggest", searchHandler.Suggest)
 
 	// Routes that use OptionalAuth middleware to conditionally enrich data
 	mux.Group(func(r chi.Router) {
 		r.Use(middleware.OptionalAuth)
+		r.Use(middleware.ETag)
 		r.Get("/tracks/{trackID}", trackHandler.GetByTrackID) // Moved here
+		r.Get("/tracks/{trackID}/stats", interactionHandler.GetTrackStats)
+		r.Get("/tracks/{trackID}/similar", similarityHandler.ListSimilarTracks)
 		r.Get("/tracks", trackHandler.ListTracks)
 		r.Get("/tracks/search", trackHandler.SearchTracks)
+		r.Get("/search", searchHandler.Search)
 		r.Get("/playlists/{playlistID}/tracks", playlistHandler.GetTracksInPlaylist)
+		r.Get("/artists/{artistID}", artistHandler.GetArtist)
+		r.Get("/artists/{artistID}/tracks", artistHandler.ListArtistTracks)
+		r.Get("/albums/{albumID}", artistHandler.GetAlbum)
+		r.Get("/genres", genreHandler.ListGenres)
+		r.Get("/genres/{genre}/tracks", genreHandler.ListGenreTracks)
+		r.Get("/moods", moodHandler.ListMoods)
+		r.Get("/moods/{mood}/tracks", moodHandler.ListMoodTracks)
+		r.Get("/onboarding/genres", onboardingHandler.ListGenres)
+		r.Get("/onboarding/tracks", onboardingHandler.SampleTracks)
 	})
 
 	// Protected routes
@@ -87,15 +254,75 @@ func InitRoutes(
 
 		// Interaction routes
 		r.Post("/tracks/{trackID}/interact", interactionHandler.CreateInteraction)
-		r.Get("/tracks/{trackID}/interactions", interactionHandler.GetInteractionsForTrack)
+		r.Put("/tracks/{trackID}/like", interactionHandler.LikeTrack)
+		r.Delete("/tracks/{trackID}/like", interactionHandler.UnlikeTrack)
+		r.Put("/tracks/{trackID}/dislike", interactionHandler.DislikeTrack)
+		r.Delete("/tracks/{trackID}/dislike", interactionHandler.UndislikeTrack)
+		r.Get("/me/likes", interactionHandler.ListLikedTracks)
+		// Superseded by the admin route, kept for existing clients until its sunset
+		r.With(middleware.Deprecated(trackInteractionsDeprecated, trackInteractionsSunset, func(r *http.Request) string {
+			return "/admin/tracks/" + url.PathEscape(chi.URLParam(r, "trackID")) + "/interactions"
+		})).Get("/tracks/{trackID}/interactions", interactionHandler.GetInteractionsForTrack)
+		r.Post("/onboarding", onboardingHandler.Complete)
+		r.Get("/me/recommendations", recommendationHandler.ListRecommendations)
 
 		// Playlist routes
-		r.Get("/playlists", playlistHandler.ListUserPlaylists)
+		r.With(middleware.ETag).Get("/playlists", playlistHandler.ListUserPlaylists)
 		r.Post("/playlists", playlistHandler.CreatePlaylist)
+		r.Post("/playlists/generate", playlistGeneratorHandler.GeneratePlaylist)
 		r.Put("/playlists/{playlistID}", playlistHandler.UpdatePlaylistDetails)
 		// playlistHandler.GetTracksInPlaylist moved to optional auth group
 		r.Post("/playlists/{playlistID}/tracks/{trackID}", playlistHandler.AddTrackToPlaylist)
 		r.Delete("/playlists/{playlistID}/tracks/{trackID}", playlistHandler.RemoveTrackFromPlaylist)
+		r.Post("/playlists/{playlistID}/sequence", playlistHandler.ProposeSequence)
+		r.Post("/playlists/{playlistID}/sequence/apply", playlistHandler.ApplySequence)
+		r.Post("/moods/{mood}/playlist", moodHandler.GeneratePlaylist)
+
+		// Play history routes
+		r.Get("/me/history", playHistoryHandler.ListHistory)
+		r.Post("/me/history/sessions", playHistoryHandler.StartSession)
+		r.Post("/me/history/sessions/{sessionID}/progress", playHistoryHandler.RecordProgress)
+		r.Post("/me/history/sessions/{sessionID}/end", playHistoryHandler.EndSession)
+	})
+
+	// Curator routes
+	mux.Route("/catalog", func(r chi.Router) {
+		r.Use(middleware.Auth)
+		r.Use(middleware.RequireRole(roles, models.RoleCurator))
+
+		r.Post("/tracks", catalogHandler.CreateTrack)
+		r.Put("/tracks/{trackID}", catalogHandler.UpdateTrack)
+		r.Delete("/tracks/{trackID}", catalogHandler.DeleteTrack)
+		r.Post("/tracks/{trackID}/restore", catalogHandler.RestoreTrack)
+		r.Get("/tracks/{trackID}/audit", catalogHandler.GetAuditLog)
+	})
+
+	// Admin routes
+	mux.Route("/admin", func(r chi.Router) {
+		r.Use(middleware.Auth)
+		r.Use(middleware.RequireRole(roles, models.RoleAdmin))
+
+		// User management
+		r.Get("/users", adminHandler.ListUsers)
+		r.Get("/users/{userID}", adminHandler.GetUser)
+		r.Put("/users/{userID}/role", adminHandler.UpdateUserRole)
+
+		// Interaction inspection
+		r.Get("/users/{userID}/interactions", adminHandler.GetInteractionsByUser)
+		r.Get("/tracks/{trackID}/interactions", interactionHandler.GetInteractionsForTrack)
+
+		// Catalog maintenance
+		r.Post("/track-states/backfill", adminHandler.BackfillTrackStates)
+		r.Post("/track-stats/rebuild", adminHandler.RebuildTrackStats)
+		r.Post("/catalog/backfill-artists", adminHandler.BackfillArtists)
+		r.Post("/recommendations/rebuild-neighbors", adminHandler.RebuildNeighbors)
+
+		// Caching
+		r.Get("/cache/stats", adminHandler.GetCacheStats)
+
+		// Experiments
+		r.Get("/experiments", experimentHandler.ListExperiments)
+		r.Get("/experiments/{experiment}/summary", experimentHandler.GetExperimentSummary)
 	})
 
 	return mux
@@ -106,9 +333,12 @@ func main() {
 	db := ConnectSQL()
 	defer db.Close()
 
-	// Initialize Kafka Writer
-	kafkaWriter := InitKafka()
-	defer kafkaWriter.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Initialize event publisher
+	publisher := InitEventPublisher()
+	defer publisher.Close()
 
 	// --- Initialize Layers ---
 
@@ -117,23 +347,82 @@ func main() {
 	trackRepo := repository.NewSpotifyTrackRepository(db)
 	playlistRepo := repository.NewPlaylistRepository(db)
 	interactionRepo := repository.NewInteractionRepository(db)
+	recommendationRepo := repository.NewRecommendationRepository(db)
+	playHistoryRepo := repository.NewPlayHistoryRepository(db)
+	artistRepo := repository.NewArtistRepository(db)
+	searchRepo := repository.NewSearchRepository(db)
+	trackNeighborRepo := repository.NewTrackNeighborRepository(db)
+	experimentRepo := repository.NewExperimentRepository(db)
+
+	// Caches
+	appCache := InitCache()
+	defer appCache.Close()
+	cacheMetrics := cache.NewMetrics()
+	cachedTrackRepo := repository.NewCachedSpotifyTrackRepository(trackRepo, appCache, InitCacheTTL("CACHE_TRACK_TTL", "10m"), cacheMetrics.For("tracks"))
+	trackRepo = cachedTrackRepo
+	cachedInteractionRepo := repository.NewCachedInteractionRepository(interactionRepo, appCache, InitCacheTTL("CACHE_TRACK_STATE_TTL", "5m"), cacheMetrics.For("track_states"))
+	interactionRepo = cachedInteractionRepo
 
 	// Services
-	interactionService := services.NewInteractionService(interactionRepo, kafkaWriter, trackRepo, userRepo)
+	experimentService := services.NewExperimentService(experimentRepo, InitExperiments())
+	interactionService := services.NewInteractionService(db, interactionRepo, publisher, trackRepo, userRepo, experimentService, cachedInteractionRepo)
 	userService := services.NewUserService(db, userRepo, playlistRepo)
 	authService := services.NewAuthService(userRepo)
-	trackService := services.NewSpotifyTrackService(trackRepo, interactionService)
-	playlistService := services.NewPlaylistService(playlistRepo, interactionService)
+	artistService := services.NewArtistService(artistRepo)
+	genreService := services.NewGenreService(trackRepo, InitGenreTaxonomy())
+	searchService := services.NewSearchService(searchRepo)
+	suggestService := services.NewSuggestService(searchRepo)
+	similarityService := services.NewSimilarityService(trackRepo)
+	collaborativeService := services.NewCollaborativeService(trackNeighborRepo)
+	trackService := services.NewSpotifyTrackService(trackRepo, interactionService, artistService)
+	playlistService := services.NewPlaylistService(playlistRepo, interactionService, artistService)
+	playlistGeneratorService := services.NewPlaylistGeneratorService(trackRepo, similarityService, genreService, playlistService)
+	moodService := services.NewMoodService(trackRepo, userRepo, similarityService, playlistService, InitMoods())
+	recommendationService := services.NewRecommendationService(db, recommendationRepo, userRepo, trackRepo, playlistRepo, genreService, similarityService, collaborativeService, experimentService)
+	onboardingService := services.NewOnboardingService(db, userRepo, interactionRepo, trackRepo, artistService, genreService, similarityService, recommendationService)
+	playHistoryService := services.NewPlayHistoryService(db, playHistoryRepo, trackRepo, interactionService)
+	catalogService := services.NewCatalogService(db, trackRepo, artistRepo, suggestService, similarityService, genreService, cachedTrackRepo)
+
+	// Make sure there is an administrator
+	InitAdmin(ctx, userService)
+
+	// Background jobs
+	go suggestService.Run(ctx)
+	go genreService.Run(ctx)
+	go similarityService.Run(ctx)
+	go collaborativeService.Run(ctx, InitNeighborRebuildInterval())
+
+	// Consumers
+	if consumer := InitRecommendationConsumer(); consumer != nil {
+		defer consumer.Close()
+		go func() {
+			if err := consumer.Run(ctx, recommendationService.HandleRecommendationMessage); err != nil {
+				log.Printf("Recommendation consumer stopped: %v", err)
+			}
+		}()
+	}
 
 	// Handlers
 	userHandler := handlers.NewUserHandler(userService, authService)
 	authHandler := handlers.NewAuthHandler(authService)
 	trackHandler := handlers.NewSpotifyTrackHandler(trackService)
 	playlistHandler := handlers.NewPlaylistHandler(playlistService)
-	interactionHandler := handlers.NewInteractionHandler(interactionService)
+	interactionHandler := handlers.NewInteractionHandler(interactionService, artistService)
+	playHistoryHandler := handlers.NewPlayHistoryHandler(playHistoryService)
+	adminHandler := handlers.NewAdminHandler(userService, interactionService, artistService, collaborativeService, cacheMetrics)
+	catalogHandler := handlers.NewCatalogHandler(catalogService)
+	artistHandler := handlers.NewArtistHandler(artistService, interactionService)
+	genreHandler := handlers.NewGenreHandler(genreService, interactionService, artistService)
+	searchHandler := handlers.NewSearchHandler(searchService, suggestService, interactionService, artistService)
+	similarityHandler := handlers.NewSimilarityHandler(similarityService, interactionService, artistService)
+	moodHandler := handlers.NewMoodHandler(moodService, interactionService, artistService)
+	playlistGeneratorHandler := handlers.NewPlaylistGeneratorHandler(playlistGeneratorService, interactionService, artistService)
+	onboardingHandler := handlers.NewOnboardingHandler(onboardingService, interactionService, artistService)
+	recommendationHandler := handlers.NewRecommendationHandler(recommendationService, interactionService, artistService)
+	experimentHandler := handlers.NewExperimentHandler(experimentService)
 
 	// Initialize routes
-	router := InitRoutes(userHandler, authHandler, trackHandler, playlistHandler, interactionHandler)
+	router := InitRoutes(userHandler, authHandler, trackHandler, playlistHandler, interactionHandler, playHistoryHandler, adminHandler, catalogHandler, artistHandler, genreHandler, searchHandler, similarityHandler, moodHandler, playlistGeneratorHandler, onboardingHandler, recommendationHandler, experimentHandler, userService)
 
 	server := &http.Server{
 		Addr:    ":8081",
@@ -14,7 +14,10 @@ import (
 
 type contextKey string
 
-const UserIDKey contextKey = "userID"
+const (
+	UserIDKey contextKey = "userID"
+	RoleKey   contextKey = "role"
+)
 
 func Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -51,8 +54,9 @@ func Auth(next http.Handler) http.Handler {
 			// Extract user ID from the 'sub' claim
 			if userIDFloat, ok := claims["sub"].(float64); ok {
 				userID := int(userIDFloat)
-				// Add user ID to the request context
+				// Add user ID and role to the request context
 				ctx := context.WithValue(r.Context(), UserIDKey, userID)
+				ctx = context.WithValue(ctx, RoleKey, roleFromClaims(claims))
 				log.Printf("Authenticated user with ID: %d", userID)
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Deprecated marks every response of a route that is going away with the Deprecation
+// (RFC 9745) and Sunset (RFC 8594) headers and, if successor is not nil, a Link to the
+// route replacing it. The route keeps working until it is removed.
+func Deprecated(since, sunset time.Time, successor func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			log.Printf("Deprecated: %s %s called, it is removed after %s", r.Method, r.URL.Path, sunset.Format(time.DateOnly))
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", since.Unix()))
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			if successor != nil {
+				w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor(r)))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
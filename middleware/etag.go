@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// ETag makes GET responses cacheable by revalidation. A successful response keeps the
+// ETag its handler set, or gets a strong one hashed from its body, and must be
+// revalidated before every reuse; responses to requests with a token are private. A
+// request whose If-None-Match matches the ETag gets 304 Not Modified without a body.
+// Responses are buffered, so only use it on routes whose bodies are bounded.
+func ETag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf := &bufferedResponse{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+		if buf.status != http.StatusOK {
+			w.WriteHeader(buf.status)
+			w.Write(buf.body.Bytes())
+			return
+		}
+
+		header := w.Header()
+		etag := header.Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(buf.body.Bytes())
+			etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+			header.Set("ETag", etag)
+		}
+		if header.Get("Cache-Control") == "" {
+			if r.Header.Get("Authorization") != "" {
+				header.Set("Cache-Control", "private, no-cache")
+			} else {
+				header.Set("Cache-Control", "public, no-cache")
+			}
+		}
+
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.body.Bytes())
+	})
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. As RFC 9110 requires
+// for If-None-Match, weak and strong tags with the same value match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for candidate := range strings.SplitSeq(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponse holds back the status and body of a response until ETag has
+// decided what to send. Headers go straight to the underlying writer.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status, b.wroteHeader = status, true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
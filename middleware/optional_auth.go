@@ -19,6 +19,10 @@ var jwtSecret = []byte("supersecretkey")
 // without setting the userID and without returning an error (i.e., it doesn't block unauthenticated requests).
 func OptionalAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response depends on who asks (e.g. interaction states), so caches must
+		// not serve one user's response to another, or to anonymous clients.
+		w.Header().Add("Vary", "Authorization")
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			// No Authorization header, proceed without user ID
@@ -54,8 +58,9 @@ func OptionalAuth(next http.Handler) http.Handler {
 			// Extract user ID from the 'sub' claim
 			if userIDFloat, ok := claims["sub"].(float64); ok {
 				userID := int(userIDFloat)
-				// Add user ID to the request context
+				// Add user ID and role to the request context
 				ctx := context.WithValue(r.Context(), UserIDKey, userID)
+				ctx = context.WithValue(ctx, RoleKey, roleFromClaims(claims))
 				log.Printf("OptionalAuth: Authenticated user with ID: %d", userID)
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
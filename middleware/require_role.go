@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+// RoleSource looks up a user's current role. It fails with "user not found" for users
+// that no longer exist.
+type RoleSource interface {
+	CurrentRole(ctx context.Context, userID int) (models.Role, error)
+}
+
+// RequireRole only lets users through whose current role includes role. It must run
+// after Auth. The role is looked up in roles rather than taken from the token, so that
+// demoted or deleted users lose access at once instead of when their token expires.
+func RequireRole(roles RoleSource, role models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := r.Context().Value(UserIDKey).(int)
+			userRole, err := roles.CurrentRole(r.Context(), userID)
+			if err != nil {
+				if err.Error() == "user not found" {
+					log.Printf("RequireRole: User %d no longer exists", userID)
+					http.Error(w, "Forbidden", http.StatusForbidden)
+					return
+				}
+				log.Printf("RequireRole: Error getting role of user %d: %v", userID, err)
+				http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+				return
+			}
+			if !userRole.Includes(role) {
+				log.Printf("RequireRole: User %d with role '%s' lacks role '%s'", userID, userRole, role)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			ctx := context.WithValue(r.Context(), RoleKey, userRole)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// roleFromClaims reads the 'role' claim, treating tokens issued before roles existed as plain users.
+func roleFromClaims(claims map[string]any) models.Role {
+	if role, ok := claims["role"].(string); ok && models.Role(role).IsValid() {
+		return models.Role(role)
+	}
+	return models.RoleUser
+}
@@ -0,0 +1,37 @@
+package models
+
+// Artist is a performer parsed out of the `;`-separated spotify_tracks.artists column.
+type Artist struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// ArtistDetail is an artist together with an overview of their catalog.
+type ArtistDetail struct {
+	Artist
+	TrackCount int     `json:"track_count"`
+	Albums     []Album `json:"albums"`
+}
+
+// Album is keyed by its name and primary (first listed) artist, since the
+// dataset only carries free-text album names.
+type Album struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	ArtistID int    `json:"artist_id"`
+}
+
+// AlbumDetail is an album with its artist and tracks.
+type AlbumDetail struct {
+	Album
+	Artist Artist         `json:"artist"`
+	Tracks []SpotifyTrack `json:"tracks"`
+}
+
+// ArtistBackfillResult reports how many rows a backfill created.
+type ArtistBackfillResult struct {
+	Artists      int64 `json:"artists"`
+	TrackArtists int64 `json:"track_artists"`
+	Albums       int64 `json:"albums"`
+	AlbumTracks  int64 `json:"album_tracks"`
+}
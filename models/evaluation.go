@@ -0,0 +1,89 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// StrategyPopular recommends the tracks with the most positive interactions. It only
+// exists in offline evaluation, as a baseline for the real strategies.
+const StrategyPopular = "popular"
+
+// EvaluationConfig describes an offline evaluation run.
+type EvaluationConfig struct {
+	// TrainFraction is the share of interactions, oldest first, that models are trained
+	// on; the rest are held out. Ignored when SplitAt is set.
+	TrainFraction float64 `json:"train_fraction"`
+	// SplitAt, if set, holds out the interactions from this time on.
+	SplitAt *time.Time `json:"split_at,omitempty"`
+	// K is the length of every recommendation list.
+	K        int                 `json:"k"`
+	Variants []EvaluationVariant `json:"variants"`
+}
+
+// EvaluationVariant is one recommender configuration to evaluate. TasteModel defaults to
+// the one the service uses.
+type EvaluationVariant struct {
+	Name       string      `json:"name"`
+	Strategy   string      `json:"strategy"`
+	TasteModel *TasteModel `json:"taste_model,omitempty"`
+}
+
+// Validate checks the run's parameters and that every variant is named uniquely and uses
+// a known strategy.
+func (c *EvaluationConfig) Validate() error {
+	if c.SplitAt == nil && (c.TrainFraction <= 0 || c.TrainFraction >= 1) {
+		return fmt.Errorf("train_fraction must be between 0 and 1")
+	}
+	if c.K <= 0 {
+		return fmt.Errorf("k must be positive")
+	}
+	if len(c.Variants) == 0 {
+		return fmt.Errorf("no variants to evaluate")
+	}
+	names := make(map[string]bool, len(c.Variants))
+	for _, v := range c.Variants {
+		if v.Name == "" || names[v.Name] {
+			return fmt.Errorf("variant names must be set and unique")
+		}
+		names[v.Name] = true
+		switch v.Strategy {
+		case StrategyCF, StrategyContent, StrategyHybrid, StrategyPopular:
+		default:
+			return fmt.Errorf("variant %s: unknown strategy %q", v.Name, v.Strategy)
+		}
+		if v.TasteModel != nil {
+			if err := v.TasteModel.Validate(); err != nil {
+				return fmt.Errorf("variant %s: %w", v.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// EvaluationReport is the outcome of an offline evaluation run. It only depends on the
+// configuration and the data, so a rerun on the same data gives the same report.
+type EvaluationReport struct {
+	Config            EvaluationConfig    `json:"config"`
+	SplitAt           time.Time           `json:"split_at"`
+	TrainInteractions int                 `json:"train_interactions"`
+	TestInteractions  int                 `json:"test_interactions"`
+	CatalogTracks     int                 `json:"catalog_tracks"`
+	Variants          []EvaluationMetrics `json:"variants"`
+}
+
+// EvaluationMetrics are the metrics of one variant. Precision, recall and NDCG are at K
+// and averaged over the evaluated users: those with interactions before the split and a
+// newly liked track after it. Coverage is the share of the catalog recommended to
+// anyone; diversity is the mean distance in normalized audio-feature space between two
+// tracks of the same list, from 0 to 1.
+type EvaluationMetrics struct {
+	Name      string  `json:"name"`
+	Strategy  string  `json:"strategy"`
+	Users     int     `json:"users"`
+	Precision float64 `json:"precision_at_k"`
+	Recall    float64 `json:"recall_at_k"`
+	NDCG      float64 `json:"ndcg_at_k"`
+	Coverage  float64 `json:"coverage"`
+	Diversity float64 `json:"diversity"`
+}
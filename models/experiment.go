@@ -0,0 +1,103 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Experiment is an A/B test: every user is assigned one of its variants, for good, by a
+// hash of the experiment's name and the user's ID.
+type Experiment struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Variants    []ExperimentVariant `json:"variants"`
+}
+
+// ExperimentVariant is one arm of an experiment. Weight is its share of the users,
+// relative to the other variants. A variant changes what it sets: the default
+// recommendation strategy of GET /me/recommendations and/or the taste model that
+// interactions update avg_interest with. A variant setting neither is a control group.
+type ExperimentVariant struct {
+	Name                   string      `json:"name"`
+	Weight                 int         `json:"weight"`
+	RecommendationStrategy string      `json:"recommendation_strategy,omitempty"`
+	TasteModel             *TasteModel `json:"taste_model,omitempty"`
+}
+
+// Validate checks that the experiment is named and has at least two uniquely named
+// variants with positive weights and valid overrides.
+func (e *Experiment) Validate() error {
+	if e.Name == "" {
+		return fmt.Errorf("experiment has no name")
+	}
+	if len(e.Variants) < 2 {
+		return fmt.Errorf("experiment %s needs at least two variants", e.Name)
+	}
+	names := make(map[string]bool, len(e.Variants))
+	for _, v := range e.Variants {
+		if v.Name == "" || names[v.Name] {
+			return fmt.Errorf("experiment %s: variant names must be set and unique", e.Name)
+		}
+		names[v.Name] = true
+		if v.Weight <= 0 {
+			return fmt.Errorf("experiment %s: variant %s needs a positive weight", e.Name, v.Name)
+		}
+		switch v.RecommendationStrategy {
+		case "", StrategyCF, StrategyContent, StrategyHybrid:
+		default:
+			return fmt.Errorf("experiment %s: variant %s has unknown strategy %q", e.Name, v.Name, v.RecommendationStrategy)
+		}
+		if v.TasteModel != nil {
+			if err := v.TasteModel.Validate(); err != nil {
+				return fmt.Errorf("experiment %s: variant %s: %w", e.Name, v.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Varies returns what the experiment's variants change, as the JSON names of the
+// overrides: "recommendation_strategy" and/or "taste_model".
+func (e *Experiment) Varies() []string {
+	var strategy, tasteModel bool
+	for _, v := range e.Variants {
+		strategy = strategy || v.RecommendationStrategy != ""
+		tasteModel = tasteModel || v.TasteModel != nil
+	}
+	var varies []string
+	if strategy {
+		varies = append(varies, "recommendation_strategy")
+	}
+	if tasteModel {
+		varies = append(varies, "taste_model")
+	}
+	return varies
+}
+
+// ExperimentExposure records when a user was first exposed to their variant of an
+// experiment, i.e. when it first changed something for them.
+type ExperimentExposure struct {
+	Experiment string    `json:"experiment"`
+	Variant    string    `json:"variant"`
+	UserID     int       `json:"user_id"`
+	ExposedAt  time.Time `json:"exposed_at"`
+}
+
+// ExperimentSummary compares the variants of an experiment.
+type ExperimentSummary struct {
+	Experiment string           `json:"experiment"`
+	Variants   []VariantSummary `json:"variants"`
+}
+
+// VariantSummary counts the interactions of a variant's users from their first exposure
+// on. LikeRate and SkipRate are likes and skips per track heard (plays plus skips).
+type VariantSummary struct {
+	Variant  string  `json:"variant"`
+	Users    int64   `json:"users"`
+	Plays    int64   `json:"plays"`
+	Skips    int64   `json:"skips"`
+	Likes    int64   `json:"likes"`
+	Dislikes int64   `json:"dislikes"`
+	LikeRate float64 `json:"like_rate"`
+	SkipRate float64 `json:"skip_rate"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Genre is a node of the genre hierarchy. TrackCount counts the tracks tagged with
+// the genre itself, TotalTrackCount also includes the tracks of all its subgenres.
+type Genre struct {
+	Name            string   `json:"name"`
+	Parent          string   `json:"parent,omitempty"`
+	Children        []string `json:"children,omitempty"`
+	TrackCount      int      `json:"track_count"`
+	TotalTrackCount int      `json:"total_track_count"`
+}
+
+// GenreTaxonomy maps a genre to its parent genre, e.g. "deep-house" -> "house" and
+// "house" -> "electronic". Genres without an entry are top-level genres.
+type GenreTaxonomy map[string]string
+
+// Validate checks that the taxonomy is a forest: no genre is its own ancestor.
+func (t GenreTaxonomy) Validate() error {
+	for genre := range t {
+		seen := map[string]bool{genre: true}
+		for parent, ok := t[genre]; ok; parent, ok = t[parent] {
+			if seen[parent] {
+				return fmt.Errorf("genre taxonomy has a cycle through %q", genre)
+			}
+			seen[parent] = true
+		}
+	}
+	return nil
+}
+
+// Root returns the top-level genre genre belongs to (genre itself if it has no parent).
+func (t GenreTaxonomy) Root(genre string) string {
+	for {
+		parent, ok := t[genre]
+		if !ok {
+			return genre
+		}
+		genre = parent
+	}
+}
+
+// Children returns the direct subgenres of genre, sorted by name.
+func (t GenreTaxonomy) Children(genre string) []string {
+	var children []string
+	for child, parent := range t {
+		if parent == genre {
+			children = append(children, child)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+// Descendants returns genre followed by all of its subgenres at any depth.
+func (t GenreTaxonomy) Descendants(genre string) []string {
+	genres := []string{genre}
+	for i := 0; i < len(genres); i++ {
+		genres = append(genres, t.Children(genres[i])...)
+	}
+	return genres
+}
@@ -3,6 +3,7 @@ package models
 import "time"
 
 type Interaction struct {
+	ID        int64     `json:"id"`
 	UserID    int       `json:"user_id"`
 	TrackID   string    `json:"track_id"`
 	Type      string    `json:"type"`
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+)
+
+// AudioFeatures are the audio features of a track in feature vector order, the order
+// used by a user's avg_interest.
+var AudioFeatures = []string{
+	"danceability", "energy", "loudness", "speechiness", "acousticness",
+	"instrumentalness", "liveness", "valence", "tempo",
+}
+
+// Mood is a named region of audio-feature space, such as "workout" or "chill". A track
+// is in the mood if each of its features is within the mood's range for it.
+type Mood struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Ranges      map[string]Range `json:"ranges"` // keyed by an AudioFeatures entry
+}
+
+// Validate checks that the mood is named and bounds at least one audio feature.
+func (m *Mood) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("mood has no name")
+	}
+	if len(m.Ranges) == 0 {
+		return fmt.Errorf("mood %s has no feature ranges", m.Name)
+	}
+	for feature, r := range m.Ranges {
+		if !slices.Contains(AudioFeatures, feature) {
+			return fmt.Errorf("mood %s: %s is not an audio feature", m.Name, feature)
+		}
+		if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			return fmt.Errorf("mood %s: %s range is empty", m.Name, feature)
+		}
+	}
+	return nil
+}
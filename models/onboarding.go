@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidOnboarding is wrapped by every error caused by a bad onboarding request.
+var ErrInvalidOnboarding = errors.New("invalid onboarding")
+
+const (
+	MaxOnboardingGenres  = 10
+	MaxOnboardingArtists = 20
+	MaxOnboardingTracks  = 50
+)
+
+// OnboardingRequest holds what a new user picked in the onboarding questionnaire.
+type OnboardingRequest struct {
+	Genres    []string `json:"genres"`
+	ArtistIDs []int    `json:"artist_ids"`
+	TrackIDs  []string `json:"track_ids"`
+}
+
+// Validate checks that something was picked and not too much of it.
+func (r *OnboardingRequest) Validate() error {
+	if len(r.Genres) == 0 && len(r.ArtistIDs) == 0 && len(r.TrackIDs) == 0 {
+		return fmt.Errorf("%w: pick at least one genre, artist or track", ErrInvalidOnboarding)
+	}
+	if len(r.Genres) > MaxOnboardingGenres || len(r.ArtistIDs) > MaxOnboardingArtists || len(r.TrackIDs) > MaxOnboardingTracks {
+		return fmt.Errorf("%w: at most %d genres, %d artists and %d tracks can be picked", ErrInvalidOnboarding, MaxOnboardingGenres, MaxOnboardingArtists, MaxOnboardingTracks)
+	}
+	return nil
+}
+
+// OnboardingResult is the taste seeded from an onboarding questionnaire.
+type OnboardingResult struct {
+	AvgInterest           FloatVector `json:"avg_interest"`
+	SeedTrackCount        int         `json:"seed_track_count"`
+	RecommPlaylistID      int         `json:"recomm_playlist_id"`
+	RecommendedTrackCount int         `json:"recommended_track_count"`
+}
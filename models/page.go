@@ -0,0 +1,23 @@
+package models
+
+import "errors"
+
+// ErrInvalidCursor is returned when a pagination cursor is malformed or was issued
+// for a different listing or sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageRequest selects a page of a listing. Cursor, when set, takes precedence over
+// Offset; Offset is kept for clients that have not moved to cursors yet.
+type PageRequest struct {
+	Limit     int
+	Offset    int
+	Cursor    string
+	WithTotal bool // also count every matching item
+}
+
+// Page is the envelope list endpoints respond with. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
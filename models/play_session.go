@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// PlayContextType describes where a track was started from.
+type PlayContextType string
+
+const (
+	PlayContextPlaylist       PlayContextType = "playlist"
+	PlayContextSearch         PlayContextType = "search"
+	PlayContextTrack          PlayContextType = "track"
+	PlayContextRecommendation PlayContextType = "recommendation"
+	PlayContextUnknown        PlayContextType = "unknown"
+)
+
+// IsValid reports whether c is one of the known context types.
+func (c PlayContextType) IsValid() bool {
+	switch c {
+	case PlayContextPlaylist, PlayContextSearch, PlayContextTrack, PlayContextRecommendation, PlayContextUnknown:
+		return true
+	}
+	return false
+}
+
+// PlaySession is one listen of a track, from start to end. PositionMs is the
+// furthest playback position reported so far.
+type PlaySession struct {
+	ID              int64           `json:"id"`
+	UserID          int             `json:"user_id"`
+	TrackID         string          `json:"track_id"`
+	ContextType     PlayContextType `json:"context_type"`
+	ContextID       *string         `json:"context_id,omitempty"`
+	PositionMs      int64           `json:"position_ms"`
+	TrackDurationMs int64           `json:"track_duration_ms"`
+	CompletionRatio *float64        `json:"completion_ratio,omitempty"` // set once the session has ended
+	StartedAt       time.Time       `json:"started_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	EndedAt         *time.Time      `json:"ended_at,omitempty"`
+}
+
+// PlayHistoryEntry is a play session together with the track that was played.
+type PlayHistoryEntry struct {
+	PlaySession
+	Track SpotifyTrack `json:"track"`
+}
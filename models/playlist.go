@@ -8,5 +8,14 @@ type Playlist struct {
 	Description *string   `json:"description,omitempty"`
 	OwnerID     int       `json:"owner_id"`
 	Modifyable  bool      `json:"modifyable"`
+	Version     int       `json:"version"` // changes whenever tracks are added, removed or reordered
 	CreatedAt   time.Time `json:"created_at"`
 }
+
+// GeneratedPlaylist is a generated track list and, if it was saved, the playlist
+// holding it.
+type GeneratedPlaylist struct {
+	Playlist   *Playlist              `json:"playlist,omitempty"`
+	Tracks     []SpotifyTrackResponse `json:"tracks"`
+	DurationMs int64                  `json:"duration_ms"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrInvalidGenerateRequest is wrapped by every error caused by a bad playlist
+// generation request.
+var ErrInvalidGenerateRequest = errors.New("invalid generate request")
+
+const (
+	// MaxGenerateSeeds is the most seed tracks and seed genres a request may give, each.
+	MaxGenerateSeeds = 5
+	// DefaultGeneratedDurationMs is the length of a generated playlist if none is asked for.
+	DefaultGeneratedDurationMs = 30 * 60 * 1000
+	// MaxGeneratedDurationMs is the longest playlist that can be generated.
+	MaxGeneratedDurationMs = 4 * 60 * 60 * 1000
+)
+
+// GeneratePlaylistRequest describes a playlist to generate: tracks like the seed tracks,
+// from the seed genres (and their subgenres), close to the target feature values, adding
+// up to about DurationMs.
+type GeneratePlaylistRequest struct {
+	SeedTracks  []string           `json:"seed_tracks"`
+	SeedGenres  []string           `json:"seed_genres"`
+	Targets     map[string]float64 `json:"targets"` // keyed by an AudioFeatures entry
+	DurationMs  int64              `json:"duration_ms"`
+	Save        bool               `json:"save"`
+	Name        string             `json:"name"`
+	Description *string            `json:"description"`
+}
+
+// Validate checks that the request has at least one seed or target and stays within limits.
+func (r *GeneratePlaylistRequest) Validate() error {
+	if len(r.SeedTracks) == 0 && len(r.SeedGenres) == 0 && len(r.Targets) == 0 {
+		return fmt.Errorf("%w: at least one seed track, seed genre or target is required", ErrInvalidGenerateRequest)
+	}
+	if len(r.SeedTracks) > MaxGenerateSeeds || len(r.SeedGenres) > MaxGenerateSeeds {
+		return fmt.Errorf("%w: at most %d seed tracks and %d seed genres are allowed", ErrInvalidGenerateRequest, MaxGenerateSeeds, MaxGenerateSeeds)
+	}
+	for feature := range r.Targets {
+		if !slices.Contains(AudioFeatures, feature) {
+			return fmt.Errorf("%w: %s is not an audio feature", ErrInvalidGenerateRequest, feature)
+		}
+	}
+	if r.DurationMs < 0 || r.DurationMs > MaxGeneratedDurationMs {
+		return fmt.Errorf("%w: duration_ms must be between 0 and %d", ErrInvalidGenerateRequest, MaxGeneratedDurationMs)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package models
+
+// Transition scores how well one track mixes into the next, from 0 (clash) to 1
+// (seamless). Keys are in Camelot notation (e.g. "8A"), empty when unknown.
+type Transition struct {
+	FromTrackID string  `json:"from_track_id"`
+	ToTrackID   string  `json:"to_track_id"`
+	FromKey     string  `json:"from_key"`
+	ToKey       string  `json:"to_key"`
+	KeyScore    float64 `json:"key_score"`
+	TempoScore  float64 `json:"tempo_score"`
+	Score       float64 `json:"score"`
+}
+
+// SequenceProposal is a suggested DJ-style order for the tracks of a playlist. Scores
+// are the mean transition score of the proposed and of the current order.
+type SequenceProposal struct {
+	PlaylistID   int                    `json:"playlist_id"`
+	TrackIDs     []string               `json:"track_ids"`
+	Tracks       []SpotifyTrackResponse `json:"tracks"`
+	Transitions  []Transition           `json:"transitions"`
+	Score        float64                `json:"score"`
+	CurrentScore float64                `json:"current_score"`
+}
@@ -0,0 +1,47 @@
+package models
+
+// Recommendation strategies for GET /me/recommendations.
+const (
+	StrategyCF      = "cf"      // tracks co-liked with the user's tracks by other users
+	StrategyContent = "content" // tracks close to the user's taste in audio-feature space
+	StrategyHybrid  = "hybrid"  // both, blended
+)
+
+// Recommendation is a track recommended to a user. Source is the strategy that found it,
+// or "both" for hybrid recommendations found by both; Reason explains the pick to the user.
+type Recommendation struct {
+	Track  SpotifyTrackResponse `json:"track"`
+	Score  float64              `json:"score"`
+	Source string               `json:"source"`
+	Reason string               `json:"reason,omitempty"`
+}
+
+type RecommendationList struct {
+	Strategy        string           `json:"strategy"`
+	Recommendations []Recommendation `json:"recommendations"`
+}
+
+// InteractionCount is how often a user interacted with a track in a certain way.
+type InteractionCount struct {
+	UserID          int
+	TrackID         string
+	InteractionType string
+	Count           int64
+}
+
+// TrackNeighbor is a track that users who like TrackID also like. Support is the number
+// of users who like both.
+type TrackNeighbor struct {
+	TrackID    string  `json:"track_id"`
+	NeighborID string  `json:"neighbor_id"`
+	Score      float64 `json:"score"`
+	Support    int     `json:"support"`
+}
+
+// NeighborBuildResult summarizes a rebuild of the track neighbours.
+type NeighborBuildResult struct {
+	Users      int   `json:"users"`
+	Tracks     int   `json:"tracks"`
+	Neighbors  int   `json:"neighbors"`
+	DurationMs int64 `json:"duration_ms"`
+}
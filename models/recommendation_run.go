@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RecommendationRun records which model filled a user's recommendation playlist.
+type RecommendationRun struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	PlaylistID   int       `json:"playlist_id"`
+	ModelVersion string    `json:"model_version"`
+	TrackCount   int       `json:"track_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+// Role controls which routes a user may call. Roles are ordered: every role has
+// the permissions of the roles below it (user < curator < admin).
+type Role string
+
+const (
+	RoleUser    Role = "user"
+	RoleCurator Role = "curator"
+	RoleAdmin   Role = "admin"
+)
+
+func (r Role) rank() int {
+	switch r {
+	case RoleUser:
+		return 1
+	case RoleCurator:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// IsValid reports whether r is one of the known roles.
+func (r Role) IsValid() bool {
+	return r.rank() > 0
+}
+
+// Includes reports whether a user with role r may act with the required role.
+func (r Role) Includes(required Role) bool {
+	return r.IsValid() && r.rank() >= required.rank()
+}
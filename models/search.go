@@ -0,0 +1,37 @@
+package models
+
+// HighlightSpan marks a matched part of a text as a half-open range of character
+// (not byte) offsets.
+type HighlightSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// TrackSearchHit is a track matched by a search. Highlights are keyed by field name
+// (track_name, artists, album_name).
+type TrackSearchHit struct {
+	Track      SpotifyTrackResponse       `json:"track"`
+	Score      float64                    `json:"score"`
+	Highlights map[string][]HighlightSpan `json:"highlights,omitempty"`
+}
+
+type ArtistSearchHit struct {
+	Artist     Artist          `json:"artist"`
+	Score      float64         `json:"score"`
+	Highlights []HighlightSpan `json:"highlights,omitempty"`
+}
+
+type AlbumSearchHit struct {
+	Album      Album           `json:"album"`
+	ArtistName string          `json:"artist_name"`
+	Score      float64         `json:"score"`
+	Highlights []HighlightSpan `json:"highlights,omitempty"`
+}
+
+// SearchResults groups the hits of a search by kind, best match first.
+type SearchResults struct {
+	Query   string            `json:"query"`
+	Tracks  []TrackSearchHit  `json:"tracks"`
+	Artists []ArtistSearchHit `json:"artists"`
+	Albums  []AlbumSearchHit  `json:"albums"`
+}
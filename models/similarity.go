@@ -0,0 +1,25 @@
+package models
+
+// SimilarTrack is a neighbour of a track in normalized audio-feature space. Distance is
+// the Euclidean distance between the two feature vectors; Similarity maps it onto 0..1,
+// 1 meaning identical features.
+type SimilarTrack struct {
+	Track      SpotifyTrackResponse `json:"track"`
+	Distance   float64              `json:"distance"`
+	Similarity float64              `json:"similarity"`
+}
+
+// SimilarityOptions narrows down a similarity lookup.
+type SimilarityOptions struct {
+	Limit         int
+	SameGenre     bool // only tracks of the same genre
+	ExcludeArtist bool // no tracks sharing an artist with the seed
+}
+
+// TasteMatchOptions narrows down the tracks matched to a user's taste.
+type TasteMatchOptions struct {
+	Limit           int
+	Ranges          map[string]Range // keyed by an AudioFeatures entry
+	Genres          []string         // a track matches if its genre is any of these; empty for all
+	ExcludeTrackIDs []string         // also excludes other releases of the same songs
+}
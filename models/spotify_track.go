@@ -1,5 +1,11 @@
 package models
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 type SpotifyTrack struct {
 	TrackID          string  `json:"track_id"`
 	Artists          string  `json:"artists"`
@@ -22,3 +28,57 @@ type SpotifyTrack struct {
 	TimeSignature    int64   `json:"time_signature"`
 	TrackGenre       string  `json:"track_genre"`
 }
+
+// ErrInvalidTrack is wrapped by every error returned from SpotifyTrack.Validate.
+var ErrInvalidTrack = errors.New("invalid track")
+
+// unitFeatures are the audio features Spotify reports on a 0..1 scale.
+func (t *SpotifyTrack) unitFeatures() map[string]float64 {
+	return map[string]float64{
+		"danceability":     t.Danceability,
+		"energy":           t.Energy,
+		"speechiness":      t.Speechiness,
+		"acousticness":     t.Acousticness,
+		"instrumentalness": t.Instrumentalness,
+		"liveness":         t.Liveness,
+		"valence":          t.Valence,
+	}
+}
+
+// Validate checks required fields and that every audio feature is within the range Spotify uses.
+func (t *SpotifyTrack) Validate() error {
+	switch {
+	case strings.TrimSpace(t.TrackID) == "":
+		return fmt.Errorf("%w: track_id is required", ErrInvalidTrack)
+	case strings.TrimSpace(t.TrackName) == "":
+		return fmt.Errorf("%w: track_name is required", ErrInvalidTrack)
+	case strings.TrimSpace(t.Artists) == "":
+		return fmt.Errorf("%w: artists is required", ErrInvalidTrack)
+	case strings.TrimSpace(t.TrackGenre) == "":
+		return fmt.Errorf("%w: track_genre is required", ErrInvalidTrack)
+	}
+
+	for name, value := range t.unitFeatures() {
+		if value < 0 || value > 1 {
+			return fmt.Errorf("%w: %s must be between 0 and 1", ErrInvalidTrack, name)
+		}
+	}
+
+	switch {
+	case t.Popularity < 0 || t.Popularity > 100:
+		return fmt.Errorf("%w: popularity must be between 0 and 100", ErrInvalidTrack)
+	case t.DurationMs <= 0:
+		return fmt.Errorf("%w: duration_ms must be positive", ErrInvalidTrack)
+	case t.Key < -1 || t.Key > 11:
+		return fmt.Errorf("%w: key must be between -1 (unknown) and 11", ErrInvalidTrack)
+	case t.Mode != 0 && t.Mode != 1:
+		return fmt.Errorf("%w: mode must be 0 (minor) or 1 (major)", ErrInvalidTrack)
+	case t.Loudness < -60 || t.Loudness > 5:
+		return fmt.Errorf("%w: loudness must be between -60 and 5 dB", ErrInvalidTrack)
+	case t.Tempo < 0 || t.Tempo > 300:
+		return fmt.Errorf("%w: tempo must be between 0 and 300 BPM", ErrInvalidTrack)
+	case t.TimeSignature < 0 || t.TimeSignature > 7:
+		return fmt.Errorf("%w: time_signature must be between 0 and 7", ErrInvalidTrack)
+	}
+	return nil
+}
@@ -3,4 +3,7 @@ package models
 type SpotifyTrackResponse struct {
 	SpotifyTrack
 	InteractionState TrackInteractionState `json:"interaction_state,omitempty"`
+	ArtistDetails    []Artist              `json:"artist_details,omitempty"`
+	Album            *Album                `json:"album,omitempty"`
+	Reason           string                `json:"reason,omitempty"` // why the track was recommended
 }
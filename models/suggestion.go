@@ -0,0 +1,15 @@
+package models
+
+const (
+	SuggestionTrack  = "track"
+	SuggestionArtist = "artist"
+)
+
+// Suggestion is a search box completion. For track suggestions TrackID is the most
+// popular track with that name.
+type Suggestion struct {
+	Text       string `json:"text"`
+	Type       string `json:"type"` // "track" or "artist"
+	TrackID    string `json:"track_id,omitempty"`
+	Popularity int64  `json:"popularity"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// interactionTypes are the interaction types a taste model can weigh.
+var interactionTypes = map[string]bool{
+	"like":                 true,
+	"unlike":               true,
+	"dislike":              true,
+	"undislike":            true,
+	"skip":                 true,
+	"play":                 true,
+	"add_to_playlist":      true,
+	"remove_from_playlist": true,
+}
+
+// TasteModel is how interactions move a user's avg_interest. Every interaction blends the
+// track's audio features, multiplied by the weight of its type, into the vector:
+// avg = Alpha*avg + (1-Alpha)*weight*features. A higher Alpha forgets more slowly.
+type TasteModel struct {
+	Alpha   float64            `json:"alpha"`
+	Weights map[string]float64 `json:"weights"` // keyed by interaction type
+}
+
+// UnmarshalJSON decodes a taste model, requiring alpha: a missing alpha would otherwise
+// read as 0 and make every interaction replace the taste outright.
+func (m *TasteModel) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Alpha   *float64           `json:"alpha"`
+		Weights map[string]float64 `json:"weights"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Alpha == nil {
+		return errors.New("taste_model needs an alpha")
+	}
+	m.Alpha, m.Weights = *raw.Alpha, raw.Weights
+	return nil
+}
+
+// Validate checks that Alpha is a blend factor and that some interaction type has a weight.
+// Weights for unknown types are rejected, since a misspelt type would otherwise be ignored.
+func (m *TasteModel) Validate() error {
+	if m.Alpha < 0 || m.Alpha >= 1 {
+		return fmt.Errorf("alpha must be at least 0 and less than 1")
+	}
+	if len(m.Weights) == 0 {
+		return fmt.Errorf("no interaction weights")
+	}
+	for _, interactionType := range slices.Sorted(maps.Keys(m.Weights)) {
+		if !interactionTypes[interactionType] {
+			return fmt.Errorf("weight for unknown interaction type %q", interactionType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Catalog audit actions.
+const (
+	TrackAuditCreate  = "create"
+	TrackAuditUpdate  = "update"
+	TrackAuditDelete  = "delete"
+	TrackAuditRestore = "restore"
+)
+
+// FieldChange is the before/after value of a single track field.
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// TrackAuditEntry records who changed a catalog track, and how.
+type TrackAuditEntry struct {
+	ID        int64                  `json:"id"`
+	TrackID   string                 `json:"track_id"`
+	ActorID   int                    `json:"actor_id"`
+	Action    string                 `json:"action"`
+	Changes   map[string]FieldChange `json:"changes,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
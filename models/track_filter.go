@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidFilter is wrapped by every error returned from TrackFilter.Validate.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// TrackRangeFields are the numeric track columns that can be filtered by range.
+var TrackRangeFields = []string{
+	"popularity", "duration_ms", "danceability", "energy", "loudness", "speechiness",
+	"acousticness", "instrumentalness", "liveness", "valence", "tempo",
+}
+
+// Range is an inclusive bound on a numeric field. A nil Min or Max is unbounded.
+type Range struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// Contains reports whether value lies within the range.
+func (r Range) Contains(value float64) bool {
+	return (r.Min == nil || value >= *r.Min) && (r.Max == nil || value <= *r.Max)
+}
+
+// Intersect returns the values within both ranges. The result may be empty (Min > Max).
+func (r Range) Intersect(other Range) Range {
+	if other.Min != nil && (r.Min == nil || *other.Min > *r.Min) {
+		r.Min = other.Min
+	}
+	if other.Max != nil && (r.Max == nil || *other.Max < *r.Max) {
+		r.Max = other.Max
+	}
+	return r
+}
+
+// TrackFilter narrows down a track listing. Zero values do not filter.
+type TrackFilter struct {
+	Ranges        map[string]Range // keyed by a TrackRangeFields entry
+	Explicit      *bool
+	Key           *int64
+	Mode          *int64
+	TimeSignature *int64
+	Genres        []string // a track matches if its genre is any of these
+}
+
+// Validate checks that every range is on a known field and not empty.
+func (f *TrackFilter) Validate() error {
+	for field, r := range f.Ranges {
+		if !isTrackRangeField(field) {
+			return fmt.Errorf("%w: %s cannot be filtered by range", ErrInvalidFilter, field)
+		}
+		if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			return fmt.Errorf("%w: %s_min is greater than %s_max", ErrInvalidFilter, field, field)
+		}
+	}
+	return nil
+}
+
+func isTrackRangeField(field string) bool {
+	for _, f := range TrackRangeFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// TrackStats holds aggregate interaction counters for a track. Likes and Dislikes
+// count users currently in that state; the other counters only ever grow.
+type TrackStats struct {
+	TrackID      string    `json:"track_id"`
+	Plays        int64     `json:"plays"`
+	Likes        int64     `json:"likes"`
+	Dislikes     int64     `json:"dislikes"`
+	Skips        int64     `json:"skips"`
+	PlaylistAdds int64     `json:"playlist_adds"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// IsZero reports whether the stats carry no counter changes.
+func (s TrackStats) IsZero() bool {
+	return s.Plays == 0 && s.Likes == 0 && s.Dislikes == 0 && s.Skips == 0 && s.PlaylistAdds == 0
+}
@@ -40,5 +40,6 @@ type User struct {
 	Password         Password    `json:"-"`
 	AvgInterest      FloatVector `json:"avg_interest"` // Now []float64
 	RecommPlaylistID int         `json:"recomm_playlist_id"`
+	Role             Role        `json:"role"`
 	CreatedAt        time.Time   `json:"created_at"`
 }
@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+type ArtistRepository interface {
+	GetArtist(ctx context.Context, id int) (*models.ArtistDetail, error)
+	ListArtistTracks(ctx context.Context, artistID int, limit int, offset int) ([]models.SpotifyTrack, error)
+	GetAlbum(ctx context.Context, id int) (*models.AlbumDetail, error)
+	GetArtistsForTracks(ctx context.Context, trackIDs []string) (map[string][]models.Artist, error)
+	GetAlbumsForTracks(ctx context.Context, trackIDs []string) (map[string]models.Album, error)
+	Backfill(ctx context.Context) (*models.ArtistBackfillResult, error)
+	SyncTrackInTx(ctx context.Context, tx pgx.Tx, trackID string) error
+}
+
+type artistRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewArtistRepository(db *pgxpool.Pool) ArtistRepository {
+	return &artistRepository{db: db}
+}
+
+// execer is implemented by both *pgxpool.Pool and pgx.Tx.
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// The statements below derive artists and albums from the spotify_tracks text columns.
+// $1 restricts them to one track; NULL processes the whole catalog. They are idempotent.
+const (
+	syncArtistsQuery = `
+		INSERT INTO artists (name)
+		SELECT DISTINCT btrim(a.name)
+		FROM spotify_tracks t
+		CROSS JOIN LATERAL unnest(string_to_array(t.artists, ';')) AS a(name)
+		WHERE ($1::text IS NULL OR t.track_id = $1) AND btrim(a.name) <> ''
+		ON CONFLICT (name) DO NOTHING`
+
+	syncTrackArtistsQuery = `
+		INSERT INTO track_artists (track_id, artist_id, position)
+		SELECT t.track_id, ar.id, MIN(a.ord)
+		FROM spotify_tracks t
+		CROSS JOIN LATERAL unnest(string_to_array(t.artists, ';')) WITH ORDINALITY AS a(name, ord)
+		JOIN artists ar ON ar.name = btrim(a.name)
+		WHERE ($1::text IS NULL OR t.track_id = $1)
+		GROUP BY t.track_id, ar.id
+		ON CONFLICT (track_id, artist_id) DO NOTHING`
+
+	// The primary artist of a track is the one listed first.
+	syncAlbumsQuery = `
+		INSERT INTO albums (name, artist_id)
+		SELECT DISTINCT btrim(t.album_name), primary_artist.artist_id
+		FROM spotify_tracks t
+		CROSS JOIN LATERAL (
+			SELECT artist_id FROM track_artists WHERE track_id = t.track_id ORDER BY position LIMIT 1
+		) AS primary_artist
+		WHERE ($1::text IS NULL OR t.track_id = $1) AND COALESCE(btrim(t.album_name), '') <> ''
+		ON CONFLICT (name, artist_id) DO NOTHING`
+
+	syncAlbumTracksQuery = `
+		INSERT INTO album_tracks (album_id, track_id)
+		SELECT al.id, t.track_id
+		FROM spotify_tracks t
+		CROSS JOIN LATERAL (
+			SELECT artist_id FROM track_artists WHERE track_id = t.track_id ORDER BY position LIMIT 1
+		) AS primary_artist
+		JOIN albums al ON al.name = btrim(t.album_name) AND al.artist_id = primary_artist.artist_id
+		WHERE ($1::text IS NULL OR t.track_id = $1)
+		ON CONFLICT (track_id) DO UPDATE SET album_id = EXCLUDED.album_id`
+)
+
+func syncArtistsAndAlbums(ctx context.Context, q execer, trackID *string) (*models.ArtistBackfillResult, error) {
+	result := &models.ArtistBackfillResult{}
+	steps := []struct {
+		query string
+		count *int64
+	}{
+		{syncArtistsQuery, &result.Artists},
+		{syncTrackArtistsQuery, &result.TrackArtists},
+		{syncAlbumsQuery, &result.Albums},
+		{syncAlbumTracksQuery, &result.AlbumTracks},
+	}
+	for _, step := range steps {
+		tag, err := q.Exec(ctx, step.query, trackID)
+		if err != nil {
+			return nil, err
+		}
+		*step.count = tag.RowsAffected()
+	}
+	return result, nil
+}
+
+// Backfill populates artists, albums and their join tables from the whole catalog.
+func (r *artistRepository) Backfill(ctx context.Context) (*models.ArtistBackfillResult, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := syncArtistsAndAlbums(ctx, tx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result, tx.Commit(ctx)
+}
+
+// SyncTrackInTx re-derives the artists and album of a single track after it changed.
+func (r *artistRepository) SyncTrackInTx(ctx context.Context, tx pgx.Tx, trackID string) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM track_artists WHERE track_id = $1", trackID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM album_tracks WHERE track_id = $1", trackID); err != nil {
+		return err
+	}
+	_, err := syncArtistsAndAlbums(ctx, tx, &trackID)
+	return err
+}
+
+func (r *artistRepository) GetArtist(ctx context.Context, id int) (*models.ArtistDetail, error) {
+	query := `
+		SELECT a.id, a.name, (SELECT COUNT(*) FROM track_artists ta JOIN spotify_tracks t ON t.track_id = ta.track_id WHERE ta.artist_id = a.id AND t.deleted_at IS NULL)
+		FROM artists a
+		WHERE a.id = $1`
+	artist := &models.ArtistDetail{}
+	err := r.db.QueryRow(ctx, query, id).Scan(&artist.ID, &artist.Name, &artist.TrackCount)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `SELECT id, name, artist_id FROM albums WHERE artist_id = $1 ORDER BY name`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artist.Albums = []models.Album{}
+	for rows.Next() {
+		var album models.Album
+		if err := rows.Scan(&album.ID, &album.Name, &album.ArtistID); err != nil {
+			return nil, err
+		}
+		artist.Albums = append(artist.Albums, album)
+	}
+	return artist, rows.Err()
+}
+
+func (r *artistRepository) ListArtistTracks(ctx context.Context, artistID int, limit int, offset int) ([]models.SpotifyTrack, error) {
+	query := `
+		SELECT t.track_id, t.artists, t.album_name, t.track_name, t.popularity, t.duration_ms, t.explicit, t.danceability, t.energy, t.key, t.loudness, t.mode, t.speechiness, t.acousticness, t.instrumentalness, t.liveness, t.valence, t.tempo, t.time_signature, t.track_genre
+		FROM spotify_tracks t
+		JOIN track_artists ta ON ta.track_id = t.track_id
+		WHERE ta.artist_id = $1 AND t.deleted_at IS NULL
+		ORDER BY t.popularity DESC, t.track_id
+		LIMIT $2 OFFSET $3`
+	return r.queryTracks(ctx, query, artistID, limit, offset)
+}
+
+func (r *artistRepository) GetAlbum(ctx context.Context, id int) (*models.AlbumDetail, error) {
+	query := `
+		SELECT al.id, al.name, al.artist_id, ar.id, ar.name
+		FROM albums al
+		JOIN artists ar ON ar.id = al.artist_id
+		WHERE al.id = $1`
+	album := &models.AlbumDetail{}
+	err := r.db.QueryRow(ctx, query, id).Scan(&album.ID, &album.Name, &album.ArtistID, &album.Artist.ID, &album.Artist.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	tracksQuery := `
+		SELECT t.track_id, t.artists, t.album_name, t.track_name, t.popularity, t.duration_ms, t.explicit, t.danceability, t.energy, t.key, t.loudness, t.mode, t.speechiness, t.acousticness, t.instrumentalness, t.liveness, t.valence, t.tempo, t.time_signature, t.track_genre
+		FROM spotify_tracks t
+		JOIN album_tracks alt ON alt.track_id = t.track_id
+		WHERE alt.album_id = $1 AND t.deleted_at IS NULL
+		ORDER BY t.popularity DESC, t.track_id`
+	album.Tracks, err = r.queryTracks(ctx, tracksQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	if album.Tracks == nil {
+		album.Tracks = []models.SpotifyTrack{}
+	}
+	return album, nil
+}
+
+func (r *artistRepository) queryTracks(ctx context.Context, query string, args ...any) ([]models.SpotifyTrack, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracks []models.SpotifyTrack
+	for rows.Next() {
+		var track models.SpotifyTrack
+		if err := rows.Scan(
+			&track.TrackID, &track.Artists, &track.AlbumName, &track.TrackName, &track.Popularity, &track.DurationMs, &track.Explicit, &track.Danceability, &track.Energy, &track.Key, &track.Loudness, &track.Mode, &track.Speechiness, &track.Acousticness, &track.Instrumentalness, &track.Liveness, &track.Valence, &track.Tempo, &track.TimeSignature, &track.TrackGenre,
+		); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, rows.Err()
+}
+
+// GetArtistsForTracks returns the artists of each track in billing order.
+func (r *artistRepository) GetArtistsForTracks(ctx context.Context, trackIDs []string) (map[string][]models.Artist, error) {
+	artists := make(map[string][]models.Artist)
+	if len(trackIDs) == 0 {
+		return artists, nil
+	}
+
+	query := `
+		SELECT ta.track_id, a.id, a.name
+		FROM track_artists ta
+		JOIN artists a ON a.id = ta.artist_id
+		WHERE ta.track_id = ANY($1)
+		ORDER BY ta.track_id, ta.position`
+	rows, err := r.db.Query(ctx, query, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get track artists: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var trackID string
+		var artist models.Artist
+		if err := rows.Scan(&trackID, &artist.ID, &artist.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan track artist row: %w", err)
+		}
+		artists[trackID] = append(artists[trackID], artist)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return artists, nil
+}
+
+func (r *artistRepository) GetAlbumsForTracks(ctx context.Context, trackIDs []string) (map[string]models.Album, error) {
+	albums := make(map[string]models.Album)
+	if len(trackIDs) == 0 {
+		return albums, nil
+	}
+
+	query := `
+		SELECT alt.track_id, al.id, al.name, al.artist_id
+		FROM album_tracks alt
+		JOIN albums al ON al.id = alt.album_id
+		WHERE alt.track_id = ANY($1)`
+	rows, err := r.db.Query(ctx, query, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get track albums: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var trackID string
+		var album models.Album
+		if err := rows.Scan(&trackID, &album.ID, &album.Name, &album.ArtistID); err != nil {
+			return nil, fmt.Errorf("failed to scan track album row: %w", err)
+		}
+		albums[trackID] = album
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return albums, nil
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/kiasoh/basic-spotify-backend/cache"
+)
+
+// The cached repositories version their entries by generations stored in the cache
+// under their own keys. A reader reads the generation before the database and stores
+// what it loaded under it; a writer drops the generation once its change is committed.
+// A reader that loaded the old data before the change then stores it under the old
+// generation, where no later lookup finds it, so stale data cannot outlive a change.
+
+// newCacheGeneration returns a generation that differs from every earlier one.
+func newCacheGeneration() []byte {
+	return strconv.AppendInt(nil, time.Now().UnixNano(), 36)
+}
+
+// cacheGenerations returns the current generation stored under each of keys, starting a
+// new one, which lives for ttl, where there is none.
+func cacheGenerations(ctx context.Context, c cache.Cache, ttl time.Duration, keys []string) (map[string][]byte, error) {
+	generations, err := c.GetMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	fresh := make(map[string][]byte)
+	for _, key := range keys {
+		if _, ok := generations[key]; !ok {
+			fresh[key] = newCacheGeneration()
+			generations[key] = fresh[key]
+		}
+	}
+	if err := c.SetMany(ctx, fresh, ttl); err != nil {
+		return nil, err
+	}
+	return generations, nil
+}
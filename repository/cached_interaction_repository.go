@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/kiasoh/basic-spotify-backend/cache"
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+// CachedInteractionRepository reads users' track states through a cache and passes
+// everything else to the wrapped repository. Tracks without a state are cached too, as
+// an empty value, so that repeated lookups of neutral tracks stay off the database.
+//
+// A user's entries are versioned by a generation (see cacheGenerations), which
+// TrackStateChanged drops once a change is committed. Entries expire after ttl in any
+// case.
+type CachedInteractionRepository struct {
+	InteractionRepository
+	cache cache.Cache
+	ttl   time.Duration
+	stats *cache.Stats
+}
+
+func NewCachedInteractionRepository(repo InteractionRepository, c cache.Cache, ttl time.Duration, stats *cache.Stats) *CachedInteractionRepository {
+	return &CachedInteractionRepository{InteractionRepository: repo, cache: c, ttl: ttl, stats: stats}
+}
+
+func trackStateGenerationKey(userID int) string {
+	return "track-state-gen:" + strconv.Itoa(userID)
+}
+
+func trackStateCacheKey(userID int, generation []byte, trackID string) string {
+	return "track-state:" + strconv.Itoa(userID) + ":" + string(generation) + ":" + trackID
+}
+
+// generation returns the user's current generation, starting a new one if there is none.
+func (r *CachedInteractionRepository) generation(ctx context.Context, userID int) ([]byte, error) {
+	key := trackStateGenerationKey(userID)
+	generations, err := cacheGenerations(ctx, r.cache, r.ttl, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	return generations[key], nil
+}
+
+func (r *CachedInteractionRepository) GetTrackStates(ctx context.Context, userID int, trackIDs []string) (map[string]models.TrackInteractionState, error) {
+	states := make(map[string]models.TrackInteractionState)
+	if len(trackIDs) == 0 {
+		return states, nil
+	}
+	// The generation must be read before the database, see CachedInteractionRepository.
+	generation, err := r.generation(ctx, userID)
+	if err != nil {
+		r.stats.Error()
+		return r.InteractionRepository.GetTrackStates(ctx, userID, trackIDs)
+	}
+	keys := make([]string, len(trackIDs))
+	for i, trackID := range trackIDs {
+		keys[i] = trackStateCacheKey(userID, generation, trackID)
+	}
+	values, err := r.cache.GetMany(ctx, keys)
+	if err != nil {
+		r.stats.Error()
+	}
+
+	var missing []string
+	cached := make(map[string]bool, len(trackIDs))
+	for i, trackID := range trackIDs {
+		value, ok := values[keys[i]]
+		if !ok {
+			missing = append(missing, trackID)
+			continue
+		}
+		cached[trackID] = true
+		if len(value) > 0 {
+			states[trackID] = models.TrackInteractionState(value)
+		}
+	}
+	r.stats.Hit(len(cached))
+	r.stats.Miss(len(missing))
+	if len(missing) == 0 {
+		return states, nil
+	}
+
+	loaded, err := r.InteractionRepository.GetTrackStates(ctx, userID, missing)
+	if err != nil {
+		return nil, err
+	}
+	fresh := make(map[string][]byte, len(missing))
+	for _, trackID := range missing {
+		state, ok := loaded[trackID]
+		if ok {
+			states[trackID] = state
+		}
+		fresh[trackStateCacheKey(userID, generation, trackID)] = []byte(state)
+	}
+	if err := r.cache.SetMany(ctx, fresh, r.ttl); err != nil {
+		r.stats.Error()
+	}
+	return states, nil
+}
+
+// TrackStateChanged drops the generation of the user's cached states once a change to
+// one of them is committed, which invalidates all of them. It makes the repository a
+// services.TrackStateObserver.
+func (r *CachedInteractionRepository) TrackStateChanged(userID int, trackID string) {
+	if err := r.cache.Delete(context.Background(), trackStateGenerationKey(userID)); err != nil {
+		r.stats.Error()
+	}
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/kiasoh/basic-spotify-backend/cache"
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+// CachedSpotifyTrackRepository reads tracks by ID through a cache and passes everything
+// else to the wrapped repository. Only live tracks are cached; lookups that fail are not.
+// Cache failures count as misses and fall back to the database. Each track's entry is
+// versioned by a generation (see cacheGenerations), which CatalogChanged drops once a
+// catalog change is committed. Entries expire after ttl in any case.
+type CachedSpotifyTrackRepository struct {
+	SpotifyTrackRepository
+	cache cache.Cache
+	ttl   time.Duration
+	stats *cache.Stats
+}
+
+func NewCachedSpotifyTrackRepository(repo SpotifyTrackRepository, c cache.Cache, ttl time.Duration, stats *cache.Stats) *CachedSpotifyTrackRepository {
+	return &CachedSpotifyTrackRepository{SpotifyTrackRepository: repo, cache: c, ttl: ttl, stats: stats}
+}
+
+func trackGenerationKey(trackID string) string {
+	return "track-gen:" + trackID
+}
+
+func trackCacheKey(trackID string, generation []byte) string {
+	return "track:" + trackID + ":" + string(generation)
+}
+
+// generations returns the current generation of every track, starting new ones where
+// there are none.
+func (r *CachedSpotifyTrackRepository) generations(ctx context.Context, trackIDs []string) (map[string][]byte, error) {
+	keys := make([]string, len(trackIDs))
+	for i, trackID := range trackIDs {
+		keys[i] = trackGenerationKey(trackID)
+	}
+	byKey, err := cacheGenerations(ctx, r.cache, r.ttl, keys)
+	if err != nil {
+		return nil, err
+	}
+	generations := make(map[string][]byte, len(trackIDs))
+	for _, trackID := range trackIDs {
+		generations[trackID] = byKey[trackGenerationKey(trackID)]
+	}
+	return generations, nil
+}
+
+func (r *CachedSpotifyTrackRepository) GetByTrackID(ctx context.Context, trackID string) (*models.SpotifyTrack, error) {
+	// The generation must be read before the database, see cacheGenerations.
+	generations, err := r.generations(ctx, []string{trackID})
+	if err != nil {
+		r.stats.Error()
+		return r.SpotifyTrackRepository.GetByTrackID(ctx, trackID)
+	}
+	key := trackCacheKey(trackID, generations[trackID])
+	value, found, err := r.cache.Get(ctx, key)
+	if err != nil {
+		r.stats.Error()
+	}
+	if found {
+		var track models.SpotifyTrack
+		if json.Unmarshal(value, &track) == nil {
+			r.stats.Hit(1)
+			return &track, nil
+		}
+	}
+	r.stats.Miss(1)
+
+	track, err := r.SpotifyTrackRepository.GetByTrackID(ctx, trackID)
+	if err != nil {
+		return nil, err
+	}
+	if value, err := json.Marshal(track); err == nil {
+		if err := r.cache.Set(ctx, key, value, r.ttl); err != nil {
+			r.stats.Error()
+		}
+	}
+	return track, nil
+}
+
+// GetByTrackIDs looks all tracks up in the cache at once and loads only the missing ones.
+func (r *CachedSpotifyTrackRepository) GetByTrackIDs(ctx context.Context, trackIDs []string) ([]models.SpotifyTrack, error) {
+	if len(trackIDs) == 0 {
+		return []models.SpotifyTrack{}, nil
+	}
+	// The generations must be read before the database, see cacheGenerations.
+	generations, err := r.generations(ctx, trackIDs)
+	if err != nil {
+		r.stats.Error()
+		return r.SpotifyTrackRepository.GetByTrackIDs(ctx, trackIDs)
+	}
+	keys := make([]string, len(trackIDs))
+	for i, trackID := range trackIDs {
+		keys[i] = trackCacheKey(trackID, generations[trackID])
+	}
+	values, err := r.cache.GetMany(ctx, keys)
+	if err != nil {
+		r.stats.Error()
+	}
+
+	byID := make(map[string]models.SpotifyTrack, len(trackIDs))
+	var missing []string
+	isMissing := make(map[string]bool)
+	for i, trackID := range trackIDs {
+		if _, ok := byID[trackID]; ok || isMissing[trackID] {
+			continue
+		}
+		var track models.SpotifyTrack
+		if value, ok := values[keys[i]]; ok && json.Unmarshal(value, &track) == nil {
+			byID[trackID] = track
+		} else {
+			isMissing[trackID] = true
+			missing = append(missing, trackID)
+		}
+	}
+	r.stats.Hit(len(byID))
+	r.stats.Miss(len(missing))
+
+	if len(missing) > 0 {
+		loaded, err := r.SpotifyTrackRepository.GetByTrackIDs(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		fresh := make(map[string][]byte, len(loaded))
+		for _, track := range loaded {
+			byID[track.TrackID] = track
+			if value, err := json.Marshal(track); err == nil {
+				fresh[trackCacheKey(track.TrackID, generations[track.TrackID])] = value
+			}
+		}
+		if err := r.cache.SetMany(ctx, fresh, r.ttl); err != nil {
+			r.stats.Error()
+		}
+	}
+
+	tracks := make([]models.SpotifyTrack, 0, len(trackIDs))
+	for _, trackID := range trackIDs {
+		if track, ok := byID[trackID]; ok {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks, nil
+}
+
+// CatalogChanged drops the generation of a track that was created, updated, deleted or
+// restored, which invalidates its entry. It makes the repository a
+// services.CatalogObserver.
+func (r *CachedSpotifyTrackRepository) CatalogChanged(trackID string) {
+	if err := r.cache.Delete(context.Background(), trackGenerationKey(trackID)); err != nil {
+		r.stats.Error()
+	}
+}
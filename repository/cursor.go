@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+// cursor is the keyset position of the last row of a page: the value of the sort
+// column, or Null if it had none, and a unique tie-breaker. Clients get it as an opaque
+// base64 string.
+type cursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	Null  bool   `json:"n,omitempty"`
+	ID    string `json:"id"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c) // cannot fail for a struct of strings and bools
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses raw and checks that it was issued for the same sort. An empty
+// raw cursor decodes to nil.
+func decodeCursor(raw string, sort string, order string) (*cursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidCursor, err)
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidCursor, err)
+	}
+	if c.Sort != sort || c.Order != order {
+		return nil, fmt.Errorf("%w: cursor was issued for a different sort order", models.ErrInvalidCursor)
+	}
+	return &c, nil
+}
+
+// trimPage drops the extra row fetched beyond limit to find out whether another page
+// follows, and reports whether it did.
+func trimPage[T any](items []T, limit int) ([]T, bool) {
+	if len(items) > limit {
+		return items[:limit], true
+	}
+	return items, false
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+type ExperimentRepository interface {
+	LogExposure(ctx context.Context, exposure *models.ExperimentExposure) error
+	GetVariantSummaries(ctx context.Context, experiment string) ([]models.VariantSummary, error)
+}
+
+type experimentRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewExperimentRepository(db *pgxpool.Pool) ExperimentRepository {
+	return &experimentRepository{db: db}
+}
+
+// LogExposure records a user's exposure to an experiment unless they were exposed before;
+// the first exposure, and with it the variant, is kept.
+func (r *experimentRepository) LogExposure(ctx context.Context, exposure *models.ExperimentExposure) error {
+	query := `
+		INSERT INTO experiment_exposures (experiment, variant, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (experiment, user_id) DO NOTHING`
+	_, err := r.db.Exec(ctx, query, exposure.Experiment, exposure.Variant, exposure.UserID)
+	return err
+}
+
+// GetVariantSummaries counts, per variant of an experiment, the exposed users and their
+// interactions since their exposure. Rates are left to the caller.
+func (r *experimentRepository) GetVariantSummaries(ctx context.Context, experiment string) ([]models.VariantSummary, error) {
+	query := `
+		SELECT e.variant,
+			COUNT(DISTINCT e.user_id),
+			COUNT(i.id) FILTER (WHERE i.type = 'play'),
+			COUNT(i.id) FILTER (WHERE i.type = 'skip'),
+			COUNT(i.id) FILTER (WHERE i.type = 'like'),
+			COUNT(i.id) FILTER (WHERE i.type = 'dislike')
+		FROM experiment_exposures e
+		LEFT JOIN interactions i ON i.user_id = e.user_id AND i.created_at >= e.exposed_at
+		WHERE e.experiment = $1
+		GROUP BY e.variant
+		ORDER BY e.variant`
+	rows, err := r.db.Query(ctx, query, experiment)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []models.VariantSummary
+	for rows.Next() {
+		var s models.VariantSummary
+		if err := rows.Scan(&s.Variant, &s.Users, &s.Plays, &s.Skips, &s.Likes, &s.Dislikes); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
@@ -2,17 +2,30 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kiasoh/basic-spotify-backend/models"
 )
 
 type InteractionRepository interface {
-	CreateInteraction(ctx context.Context, interaction *models.Interaction) error
-	GetInteractionsByUser(ctx context.Context, userID int) ([]models.Interaction, error)
-	GetInteractionsForTrack(ctx context.Context, trackID string) ([]models.Interaction, error)
-	GetLatestInteractionsForUserTracks(ctx context.Context, userID int, trackIDs []string) (map[string]string, error)
+	CreateInteractionInTx(ctx context.Context, tx pgx.Tx, interaction *models.Interaction) error
+	GetInteractionsByUser(ctx context.Context, userID int, page models.PageRequest) (*models.Page[models.Interaction], error)
+	HasInteractionsInTx(ctx context.Context, tx pgx.Tx, userID int) (bool, error)
+	GetInteractionsForTrack(ctx context.Context, trackID string, page models.PageRequest) (*models.Page[models.Interaction], error)
+	ListAllInteractions(ctx context.Context) ([]models.Interaction, error)
+	GetTrackStates(ctx context.Context, userID int, trackIDs []string) (map[string]models.TrackInteractionState, error)
+	UpdateTrackStateInTx(ctx context.Context, tx pgx.Tx, userID int, trackID string, state models.TrackInteractionState, allowedFrom ...models.TrackInteractionState) (models.TrackInteractionState, bool, error)
+	BackfillTrackStates(ctx context.Context) (int64, error)
+	ListTracksByState(ctx context.Context, userID int, state models.TrackInteractionState, limit int, offset int) ([]models.SpotifyTrack, error)
+	IncrementTrackStatsInTx(ctx context.Context, tx pgx.Tx, delta models.TrackStats) error
+	GetTrackStats(ctx context.Context, trackID string) (*models.TrackStats, error)
+	RebuildTrackStats(ctx context.Context) (int64, error)
 }
 
 type interactionRepository struct {
@@ -23,15 +36,34 @@ func NewInteractionRepository(db *pgxpool.Pool) InteractionRepository {
 	return &interactionRepository{db: db}
 }
 
-func (r *interactionRepository) CreateInteraction(ctx context.Context, interaction *models.Interaction) error {
-	query := `INSERT INTO interactions (user_id, track_id, type) VALUES ($1, $2, $3)`
-	_, err := r.db.Exec(ctx, query, interaction.UserID, interaction.TrackID, interaction.Type)
-	return err
+func (r *interactionRepository) CreateInteractionInTx(ctx context.Context, tx pgx.Tx, interaction *models.Interaction) error {
+	query := `INSERT INTO interactions (user_id, track_id, type) VALUES ($1, $2, $3) RETURNING id, created_at`
+	return tx.QueryRow(ctx, query, interaction.UserID, interaction.TrackID, interaction.Type).Scan(&interaction.ID, &interaction.CreatedAt)
+}
+
+func (r *interactionRepository) GetInteractionsByUser(ctx context.Context, userID int, page models.PageRequest) (*models.Page[models.Interaction], error) {
+	return r.listInteractions(ctx, "user_id", userID, page)
+}
+
+func (r *interactionRepository) HasInteractionsInTx(ctx context.Context, tx pgx.Tx, userID int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM interactions WHERE user_id = $1)`
+	var exists bool
+	err := tx.QueryRow(ctx, query, userID).Scan(&exists)
+	return exists, err
 }
 
-func (r *interactionRepository) GetInteractionsByUser(ctx context.Context, userID int) ([]models.Interaction, error) {
-	query := `SELECT user_id, track_id, type, created_at FROM interactions WHERE user_id = $1`
-	rows, err := r.db.Query(ctx, query, userID)
+func (r *interactionRepository) GetInteractionsForTrack(ctx context.Context, trackID string, page models.PageRequest) (*models.Page[models.Interaction], error) {
+	return r.listInteractions(ctx, "track_id", trackID, page)
+}
+
+// ListAllInteractions returns every interaction with a live track, oldest first.
+func (r *interactionRepository) ListAllInteractions(ctx context.Context) ([]models.Interaction, error) {
+	query := `
+		SELECT i.id, i.user_id, i.track_id, i.type, i.created_at
+		FROM interactions i
+		JOIN spotify_tracks t ON t.track_id = i.track_id AND t.deleted_at IS NULL
+		ORDER BY i.created_at, i.id`
+	rows, err := r.db.Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -39,67 +71,242 @@ func (r *interactionRepository) GetInteractionsByUser(ctx context.Context, userI
 
 	var interactions []models.Interaction
 	for rows.Next() {
-		var i models.Interaction
-		if err := rows.Scan(&i.UserID, &i.TrackID, &i.Type, &i.CreatedAt); err != nil {
+		var interaction models.Interaction
+		if err := rows.Scan(&interaction.ID, &interaction.UserID, &interaction.TrackID, &interaction.Type, &interaction.CreatedAt); err != nil {
 			return nil, err
 		}
-		interactions = append(interactions, i)
+		interactions = append(interactions, interaction)
 	}
-	return interactions, nil
+	return interactions, rows.Err()
 }
 
-func (r *interactionRepository) GetInteractionsForTrack(ctx context.Context, trackID string) ([]models.Interaction, error) {
-	query := `SELECT user_id, track_id, type, created_at FROM interactions WHERE track_id = $1`
-	rows, err := r.db.Query(ctx, query, trackID)
+// listInteractions pages through the interactions whose column equals value, newest
+// first. The id breaks ties between interactions created in the same instant.
+func (r *interactionRepository) listInteractions(ctx context.Context, column string, value any, page models.PageRequest) (*models.Page[models.Interaction], error) {
+	after, err := decodeCursor(page.Cursor, "created_at", "desc")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.Page[models.Interaction]{Items: []models.Interaction{}}
+	if page.WithTotal {
+		var total int64
+		if err := r.db.QueryRow(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM interactions WHERE %s = $1`, column), value).Scan(&total); err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	var rows pgx.Rows
+	if after != nil {
+		query := fmt.Sprintf(`SELECT id, user_id, track_id, type, created_at FROM interactions WHERE %s = $1 AND (created_at, id) < ($2::timestamptz, $3::bigint) ORDER BY created_at DESC, id DESC LIMIT $4`, column)
+		rows, err = r.db.Query(ctx, query, value, after.Value, after.ID, page.Limit+1)
+	} else {
+		query := fmt.Sprintf(`SELECT id, user_id, track_id, type, created_at FROM interactions WHERE %s = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3`, column)
+		rows, err = r.db.Query(ctx, query, value, page.Limit+1, page.Offset)
+	}
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var interactions []models.Interaction
 	for rows.Next() {
 		var i models.Interaction
-		if err := rows.Scan(&i.UserID, &i.TrackID, &i.Type, &i.CreatedAt); err != nil {
+		if err := rows.Scan(&i.ID, &i.UserID, &i.TrackID, &i.Type, &i.CreatedAt); err != nil {
 			return nil, err
 		}
-		interactions = append(interactions, i)
+		result.Items = append(result.Items, i)
 	}
-	return interactions, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var more bool
+	if result.Items, more = trimPage(result.Items, page.Limit); more {
+		last := result.Items[len(result.Items)-1]
+		result.NextCursor = cursor{Sort: "created_at", Order: "desc", Value: last.CreatedAt.Format(time.RFC3339Nano), ID: strconv.FormatInt(last.ID, 10)}.encode()
+	}
+	return result, nil
 }
 
-func (r *interactionRepository) GetLatestInteractionsForUserTracks(ctx context.Context, userID int, trackIDs []string) (map[string]string, error) {
+// GetTrackStates returns the stored like/dislike state of the given tracks. Tracks
+// without a row in user_track_state are omitted; callers treat them as neutral.
+func (r *interactionRepository) GetTrackStates(ctx context.Context, userID int, trackIDs []string) (map[string]models.TrackInteractionState, error) {
 	if len(trackIDs) == 0 {
-		return make(map[string]string), nil
+		return make(map[string]models.TrackInteractionState), nil
 	}
 
-	query := `
-		SELECT DISTINCT ON (track_id)
-			track_id, type
-		FROM
-			interactions
-		WHERE
-			user_id = $1 AND track_id = ANY($2)
-		ORDER BY
-			track_id, created_at DESC
-	`
+	query := `SELECT track_id, state FROM user_track_state WHERE user_id = $1 AND track_id = ANY($2)`
 	rows, err := r.db.Query(ctx, query, userID, trackIDs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get latest interactions: %w", err)
+		return nil, fmt.Errorf("failed to get track states: %w", err)
 	}
 	defer rows.Close()
 
-	interactionMap := make(map[string]string)
+	stateMap := make(map[string]models.TrackInteractionState)
 	for rows.Next() {
-		var trackID, interactionType string
-		if err := rows.Scan(&trackID, &interactionType); err != nil {
-			return nil, fmt.Errorf("failed to scan interaction row: %w", err)
+		var trackID string
+		var state models.TrackInteractionState
+		if err := rows.Scan(&trackID, &state); err != nil {
+			return nil, fmt.Errorf("failed to scan track state row: %w", err)
 		}
-		interactionMap[trackID] = interactionType
+		stateMap[trackID] = state
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error during rows iteration: %w", err)
 	}
 
-	return interactionMap, nil
+	return stateMap, nil
+}
+
+// UpdateTrackStateInTx moves a user's state for a track to state and returns the
+// previous state and whether anything changed. Nothing changes when the track is already
+// in state, or when allowedFrom is given and the current state is not one of them.
+// The row stays locked until the transaction ends, so concurrent requests cannot both
+// observe the old state.
+func (r *interactionRepository) UpdateTrackStateInTx(ctx context.Context, tx pgx.Tx, userID int, trackID string, state models.TrackInteractionState, allowedFrom ...models.TrackInteractionState) (models.TrackInteractionState, bool, error) {
+	// Make sure a row exists so that it can be locked.
+	_, err := tx.Exec(ctx, `INSERT INTO user_track_state (user_id, track_id, state) VALUES ($1, $2, $3) ON CONFLICT (user_id, track_id) DO NOTHING`, userID, trackID, models.TrackStateNeutral)
+	if err != nil {
+		return "", false, err
+	}
+
+	var previous models.TrackInteractionState
+	err = tx.QueryRow(ctx, `SELECT state FROM user_track_state WHERE user_id = $1 AND track_id = $2 FOR UPDATE`, userID, trackID).Scan(&previous)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", false, fmt.Errorf("track state row vanished for user %d and track %s", userID, trackID)
+		}
+		return "", false, err
+	}
+
+	if previous == state || (len(allowedFrom) > 0 && !slices.Contains(allowedFrom, previous)) {
+		return previous, false, nil
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE user_track_state SET state = $3, updated_at = now() WHERE user_id = $1 AND track_id = $2`, userID, trackID, state)
+	if err != nil {
+		return "", false, err
+	}
+	return previous, true, nil
+}
+
+// BackfillTrackStates derives user_track_state from the interactions of users whose
+// likes and dislikes predate it: each track takes the state of the user's latest like,
+// unlike, dislike or undislike of it. Existing states are kept, so it is safe to run
+// again. It returns the number of states added.
+func (r *interactionRepository) BackfillTrackStates(ctx context.Context) (int64, error) {
+	query := `
+		INSERT INTO user_track_state (user_id, track_id, state, updated_at)
+		SELECT user_id, track_id,
+			CASE type WHEN 'like' THEN 'liked' ELSE 'disliked' END,
+			created_at
+		FROM (
+			SELECT DISTINCT ON (user_id, track_id) user_id, track_id, type, created_at
+			FROM interactions
+			WHERE type IN ('like', 'unlike', 'dislike', 'undislike')
+			ORDER BY user_id, track_id, created_at DESC, id DESC
+		) latest
+		WHERE type IN ('like', 'dislike')
+		ON CONFLICT (user_id, track_id) DO NOTHING`
+	tag, err := r.db.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *interactionRepository) ListTracksByState(ctx context.Context, userID int, state models.TrackInteractionState, limit int, offset int) ([]models.SpotifyTrack, error) {
+	query := `
+		SELECT t.track_id, t.artists, t.album_name, t.track_name, t.popularity, t.duration_ms, t.explicit, t.danceability, t.energy, t.key, t.loudness, t.mode, t.speechiness, t.acousticness, t.instrumentalness, t.liveness, t.valence, t.tempo, t.time_signature, t.track_genre
+		FROM user_track_state uts
+		JOIN spotify_tracks t ON t.track_id = uts.track_id
+		WHERE uts.user_id = $1 AND uts.state = $2 AND t.deleted_at IS NULL
+		ORDER BY uts.updated_at DESC, uts.track_id
+		LIMIT $3 OFFSET $4`
+	rows, err := r.db.Query(ctx, query, userID, state, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracks []models.SpotifyTrack
+	for rows.Next() {
+		var track models.SpotifyTrack
+		if err := rows.Scan(
+			&track.TrackID, &track.Artists, &track.AlbumName, &track.TrackName, &track.Popularity, &track.DurationMs, &track.Explicit, &track.Danceability, &track.Energy, &track.Key, &track.Loudness, &track.Mode, &track.Speechiness, &track.Acousticness, &track.Instrumentalness, &track.Liveness, &track.Valence, &track.Tempo, &track.TimeSignature, &track.TrackGenre,
+		); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, rows.Err()
+}
+
+// IncrementTrackStatsInTx adds the counters of delta to the stats row of delta.TrackID.
+func (r *interactionRepository) IncrementTrackStatsInTx(ctx context.Context, tx pgx.Tx, delta models.TrackStats) error {
+	query := `
+		INSERT INTO track_stats (track_id, plays, likes, dislikes, skips, playlist_adds)
+		VALUES ($1, GREATEST($2, 0), GREATEST($3, 0), GREATEST($4, 0), GREATEST($5, 0), GREATEST($6, 0))
+		ON CONFLICT (track_id) DO UPDATE SET
+			plays = GREATEST(track_stats.plays + $2, 0),
+			likes = GREATEST(track_stats.likes + $3, 0),
+			dislikes = GREATEST(track_stats.dislikes + $4, 0),
+			skips = GREATEST(track_stats.skips + $5, 0),
+			playlist_adds = GREATEST(track_stats.playlist_adds + $6, 0),
+			updated_at = now()`
+	_, err := tx.Exec(ctx, query, delta.TrackID, delta.Plays, delta.Likes, delta.Dislikes, delta.Skips, delta.PlaylistAdds)
+	return err
+}
+
+// GetTrackStats returns the counters of a track; a track nobody interacted with has all-zero stats.
+func (r *interactionRepository) GetTrackStats(ctx context.Context, trackID string) (*models.TrackStats, error) {
+	query := `SELECT track_id, plays, likes, dislikes, skips, playlist_adds, updated_at FROM track_stats WHERE track_id = $1`
+	stats := &models.TrackStats{}
+	err := r.db.QueryRow(ctx, query, trackID).Scan(&stats.TrackID, &stats.Plays, &stats.Likes, &stats.Dislikes, &stats.Skips, &stats.PlaylistAdds, &stats.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &models.TrackStats{TrackID: trackID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// RebuildTrackStats recomputes every counter from the interactions and user_track_state
+// tables, repairing drift in the incrementally maintained values. It returns the number
+// of tracks written.
+func (r *interactionRepository) RebuildTrackStats(ctx context.Context) (int64, error) {
+	query := `
+		WITH counts AS (
+			SELECT track_id,
+				COUNT(*) FILTER (WHERE type = 'play') AS plays,
+				COUNT(*) FILTER (WHERE type = 'skip') AS skips,
+				COUNT(*) FILTER (WHERE type = 'add_to_playlist') AS playlist_adds
+			FROM interactions
+			GROUP BY track_id
+		), states AS (
+			SELECT track_id,
+				COUNT(*) FILTER (WHERE state = 'liked') AS likes,
+				COUNT(*) FILTER (WHERE state = 'disliked') AS dislikes
+			FROM user_track_state
+			GROUP BY track_id
+		)
+		INSERT INTO track_stats (track_id, plays, likes, dislikes, skips, playlist_adds)
+		SELECT COALESCE(c.track_id, s.track_id), COALESCE(c.plays, 0), COALESCE(s.likes, 0), COALESCE(s.dislikes, 0), COALESCE(c.skips, 0), COALESCE(c.playlist_adds, 0)
+		FROM counts c
+		FULL OUTER JOIN states s ON s.track_id = c.track_id
+		ON CONFLICT (track_id) DO UPDATE SET
+			plays = EXCLUDED.plays,
+			likes = EXCLUDED.likes,
+			dislikes = EXCLUDED.dislikes,
+			skips = EXCLUDED.skips,
+			playlist_adds = EXCLUDED.playlist_adds,
+			updated_at = now()`
+	tag, err := r.db.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
 }
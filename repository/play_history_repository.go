@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+type PlayHistoryRepository interface {
+	CreateSession(ctx context.Context, session *models.PlaySession) (int64, error)
+	GetSession(ctx context.Context, id int64) (*models.PlaySession, error)
+	UpdateProgress(ctx context.Context, id int64, positionMs int64) error
+	EndSessionInTx(ctx context.Context, tx pgx.Tx, id int64, positionMs int64, completionRatio float64) (*models.PlaySession, error)
+	ListByUser(ctx context.Context, userID int, limit int, offset int) ([]models.PlayHistoryEntry, error)
+}
+
+type playHistoryRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPlayHistoryRepository(db *pgxpool.Pool) PlayHistoryRepository {
+	return &playHistoryRepository{db: db}
+}
+
+const playSessionColumns = `id, user_id, track_id, context_type, context_id, position_ms, track_duration_ms, completion_ratio, started_at, updated_at, ended_at`
+
+func (r *playHistoryRepository) CreateSession(ctx context.Context, session *models.PlaySession) (int64, error) {
+	query := `
+		INSERT INTO play_sessions (user_id, track_id, context_type, context_id, track_duration_ms)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, started_at, updated_at`
+	err := r.db.QueryRow(ctx, query, session.UserID, session.TrackID, session.ContextType, session.ContextID, session.TrackDurationMs).Scan(&session.ID, &session.StartedAt, &session.UpdatedAt)
+	return session.ID, err
+}
+
+func (r *playHistoryRepository) GetSession(ctx context.Context, id int64) (*models.PlaySession, error) {
+	query := `SELECT ` + playSessionColumns + ` FROM play_sessions WHERE id = $1`
+	session := &models.PlaySession{}
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&session.ID, &session.UserID, &session.TrackID, &session.ContextType, &session.ContextID, &session.PositionMs, &session.TrackDurationMs, &session.CompletionRatio, &session.StartedAt, &session.UpdatedAt, &session.EndedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// UpdateProgress only moves the position forward and ignores ended sessions.
+func (r *playHistoryRepository) UpdateProgress(ctx context.Context, id int64, positionMs int64) error {
+	query := `UPDATE play_sessions SET position_ms = GREATEST(position_ms, $2), updated_at = now() WHERE id = $1 AND ended_at IS NULL`
+	_, err := r.db.Exec(ctx, query, id, positionMs)
+	return err
+}
+
+// EndSessionInTx closes a session that is still open and returns it; pgx.ErrNoRows means it had already ended.
+func (r *playHistoryRepository) EndSessionInTx(ctx context.Context, tx pgx.Tx, id int64, positionMs int64, completionRatio float64) (*models.PlaySession, error) {
+	query := `
+		UPDATE play_sessions
+		SET position_ms = GREATEST(position_ms, $2), completion_ratio = $3, updated_at = now(), ended_at = now()
+		WHERE id = $1 AND ended_at IS NULL
+		RETURNING ` + playSessionColumns
+	session := &models.PlaySession{}
+	err := tx.QueryRow(ctx, query, id, positionMs, completionRatio).Scan(
+		&session.ID, &session.UserID, &session.TrackID, &session.ContextType, &session.ContextID, &session.PositionMs, &session.TrackDurationMs, &session.CompletionRatio, &session.StartedAt, &session.UpdatedAt, &session.EndedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (r *playHistoryRepository) ListByUser(ctx context.Context, userID int, limit int, offset int) ([]models.PlayHistoryEntry, error) {
+	query := `
+		SELECT ps.id, ps.user_id, ps.track_id, ps.context_type, ps.context_id, ps.position_ms, ps.track_duration_ms, ps.completion_ratio, ps.started_at, ps.updated_at, ps.ended_at,
+			t.track_id, t.artists, t.album_name, t.track_name, t.popularity, t.duration_ms, t.explicit, t.danceability, t.energy, t.key, t.loudness, t.mode, t.speechiness, t.acousticness, t.instrumentalness, t.liveness, t.valence, t.tempo, t.time_signature, t.track_genre
+		FROM play_sessions ps
+		JOIN spotify_tracks t ON t.track_id = ps.track_id
+		WHERE ps.user_id = $1
+		ORDER BY ps.started_at DESC, ps.id DESC
+		LIMIT $2 OFFSET $3`
+	rows, err := r.db.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.PlayHistoryEntry
+	for rows.Next() {
+		var e models.PlayHistoryEntry
+		track := &e.Track
+		if err := rows.Scan(
+			&e.ID, &e.UserID, &e.TrackID, &e.ContextType, &e.ContextID, &e.PositionMs, &e.TrackDurationMs, &e.CompletionRatio, &e.StartedAt, &e.UpdatedAt, &e.EndedAt,
+			&track.TrackID, &track.Artists, &track.AlbumName, &track.TrackName, &track.Popularity, &track.DurationMs, &track.Explicit, &track.Danceability, &track.Energy, &track.Key, &track.Loudness, &track.Mode, &track.Speechiness, &track.Acousticness, &track.Instrumentalness, &track.Liveness, &track.Valence, &track.Tempo, &track.TimeSignature, &track.TrackGenre,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
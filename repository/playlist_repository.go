@@ -2,6 +2,9 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"strconv"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -16,11 +19,20 @@ type PlaylistRepository interface {
 	DeletePlaylist(ctx context.Context, id int) error
 	ListPlaylistsByOwner(ctx context.Context, ownerID int) ([]models.Playlist, error)
 	AddTrackToPlaylist(ctx context.Context, playlistID int, trackID string) error
+	AddTracksToPlaylist(ctx context.Context, playlistID int, trackIDs []string) error
 	RemoveTrackFromPlaylist(ctx context.Context, playlistID int, trackID string) error
+	ReplacePlaylistTracksInTx(ctx context.Context, tx pgx.Tx, playlistID int, trackIDs []string, reasons map[string]string) error
+	ReorderPlaylistTracks(ctx context.Context, playlistID int, trackIDs []string) error
 	GetTracksInPlaylist(ctx context.Context, playlistID int) ([]models.SpotifyTrack, error)
+	ListTracksInPlaylist(ctx context.Context, playlistID int, page models.PageRequest) (*models.Page[models.SpotifyTrack], error)
+	GetTrackReasons(ctx context.Context, playlistID int, trackIDs []string) (map[string]string, error)
 	GetTrackInPlaylist(ctx context.Context, playlistID int, trackID string) (*models.SpotifyTrack, error)
 }
 
+// ErrPlaylistChanged is returned by ReorderPlaylistTracks when the tracks given are not
+// the tracks in the playlist, e.g. because one was added since the order was proposed.
+var ErrPlaylistChanged = errors.New("playlist changed")
+
 type playlistRepository struct {
 	db *pgxpool.Pool
 }
@@ -30,23 +42,21 @@ func NewPlaylistRepository(db *pgxpool.Pool) PlaylistRepository {
 }
 
 func (r *playlistRepository) CreatePlaylist(ctx context.Context, playlist *models.Playlist) (int, error) {
-	query := `INSERT INTO playlists (name, owner_id, modifyable, description) VALUES ($1, $2, $3, $4) RETURNING id`
-	var id int
-	err := r.db.QueryRow(ctx, query, playlist.Name, playlist.OwnerID, playlist.Modifyable, playlist.Description).Scan(&id)
-	return id, err
+	query := `INSERT INTO playlists (name, owner_id, modifyable, description) VALUES ($1, $2, $3, $4) RETURNING id, version, created_at`
+	err := r.db.QueryRow(ctx, query, playlist.Name, playlist.OwnerID, playlist.Modifyable, playlist.Description).Scan(&playlist.ID, &playlist.Version, &playlist.CreatedAt)
+	return playlist.ID, err
 }
 
 func (r *playlistRepository) CreatePlaylistInTx(ctx context.Context, tx pgx.Tx, playlist *models.Playlist) (int, error) {
-	query := `INSERT INTO playlists (name, owner_id, modifyable, description) VALUES ($1, $2, $3, $4) RETURNING id`
-	var id int
-	err := tx.QueryRow(ctx, query, playlist.Name, playlist.OwnerID, playlist.Modifyable, playlist.Description).Scan(&id)
-	return id, err
+	query := `INSERT INTO playlists (name, owner_id, modifyable, description) VALUES ($1, $2, $3, $4) RETURNING id, version, created_at`
+	err := tx.QueryRow(ctx, query, playlist.Name, playlist.OwnerID, playlist.Modifyable, playlist.Description).Scan(&playlist.ID, &playlist.Version, &playlist.CreatedAt)
+	return playlist.ID, err
 }
 
 func (r *playlistRepository) GetPlaylistByID(ctx context.Context, id int) (*models.Playlist, error) {
-	query := `SELECT id, name, description, owner_id, modifyable, created_at FROM playlists WHERE id = $1`
+	query := `SELECT id, name, description, owner_id, modifyable, version, created_at FROM playlists WHERE id = $1`
 	playlist := &models.Playlist{}
-	err := r.db.QueryRow(ctx, query, id).Scan(&playlist.ID, &playlist.Name, &playlist.Description, &playlist.OwnerID, &playlist.Modifyable, &playlist.CreatedAt)
+	err := r.db.QueryRow(ctx, query, id).Scan(&playlist.ID, &playlist.Name, &playlist.Description, &playlist.OwnerID, &playlist.Modifyable, &playlist.Version, &playlist.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +90,7 @@ func (r *playlistRepository) DeletePlaylist(ctx context.Context, id int) error {
 }
 
 func (r *playlistRepository) ListPlaylistsByOwner(ctx context.Context, ownerID int) ([]models.Playlist, error) {
-	query := `SELECT id, name, description, owner_id, modifyable, created_at FROM playlists WHERE owner_id = $1`
+	query := `SELECT id, name, description, owner_id, modifyable, version, created_at FROM playlists WHERE owner_id = $1`
 	rows, err := r.db.Query(ctx, query, ownerID)
 	if err != nil {
 		return nil, err
@@ -90,7 +100,7 @@ func (r *playlistRepository) ListPlaylistsByOwner(ctx context.Context, ownerID i
 	var playlists []models.Playlist
 	for rows.Next() {
 		var playlist models.Playlist
-		if err := rows.Scan(&playlist.ID, &playlist.Name, &playlist.Description, &playlist.OwnerID, &playlist.Modifyable, &playlist.CreatedAt); err != nil {
+		if err := rows.Scan(&playlist.ID, &playlist.Name, &playlist.Description, &playlist.OwnerID, &playlist.Modifyable, &playlist.Version, &playlist.CreatedAt); err != nil {
 			return nil, err
 		}
 		playlists = append(playlists, playlist)
@@ -99,17 +109,147 @@ func (r *playlistRepository) ListPlaylistsByOwner(ctx context.Context, ownerID i
 }
 
 func (r *playlistRepository) AddTrackToPlaylist(ctx context.Context, playlistID int, trackID string) error {
-	query := `INSERT INTO songs_playlists (playlist_id, track_id) VALUES ($1, $2)`
-	_, err := r.db.Exec(ctx, query, playlistID, trackID)
+	// Soft-deleted tracks cannot be added; pgx.ErrNoRows reports a missing track.
+	query := `
+		WITH added AS (
+			INSERT INTO songs_playlists (playlist_id, track_id, position)
+			SELECT $1, $2, (SELECT COALESCE(MAX(position), 0) + 1 FROM songs_playlists WHERE playlist_id = $1)
+			WHERE EXISTS (SELECT 1 FROM spotify_tracks WHERE track_id = $2 AND deleted_at IS NULL)
+			RETURNING 1
+		)
+		UPDATE playlists SET version = version + 1 WHERE id = $1 AND EXISTS (SELECT 1 FROM added)`
+	tag, err := r.db.Exec(ctx, query, playlistID, trackID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// AddTracksToPlaylist appends trackIDs, which must not repeat, to a playlist in order.
+// Tracks that are missing, soft-deleted or already in the playlist are skipped.
+func (r *playlistRepository) AddTracksToPlaylist(ctx context.Context, playlistID int, trackIDs []string) error {
+	query := `
+		WITH added AS (
+			INSERT INTO songs_playlists (playlist_id, track_id, position)
+			SELECT $1, t.track_id, (SELECT COALESCE(MAX(position), 0) FROM songs_playlists WHERE playlist_id = $1) + ROW_NUMBER() OVER (ORDER BY t.ord)
+			FROM unnest($2::text[]) WITH ORDINALITY AS t(track_id, ord)
+			WHERE EXISTS (SELECT 1 FROM spotify_tracks WHERE track_id = t.track_id AND deleted_at IS NULL)
+			  AND NOT EXISTS (SELECT 1 FROM songs_playlists WHERE playlist_id = $1 AND track_id = t.track_id)
+			RETURNING 1
+		)
+		UPDATE playlists SET version = version + 1 WHERE id = $1 AND EXISTS (SELECT 1 FROM added)`
+	_, err := r.db.Exec(ctx, query, playlistID, trackIDs)
 	return err
 }
 
 func (r *playlistRepository) RemoveTrackFromPlaylist(ctx context.Context, playlistID int, trackID string) error {
-	query := `DELETE FROM songs_playlists WHERE playlist_id = $1 AND track_id = $2`
+	query := `
+		WITH removed AS (
+			DELETE FROM songs_playlists WHERE playlist_id = $1 AND track_id = $2
+			RETURNING 1
+		)
+		UPDATE playlists SET version = version + 1 WHERE id = $1 AND EXISTS (SELECT 1 FROM removed)`
 	_, err := r.db.Exec(ctx, query, playlistID, trackID)
 	return err
 }
 
+// ReplacePlaylistTracksInTx swaps the whole content of a playlist for trackIDs, in that order.
+// reasons optionally explains why a track is in the playlist; tracks without one get none.
+// Bumping the version locks the playlist row, so concurrent replacements cannot interleave.
+func (r *playlistRepository) ReplacePlaylistTracksInTx(ctx context.Context, tx pgx.Tx, playlistID int, trackIDs []string, reasons map[string]string) error {
+	if _, err := tx.Exec(ctx, "UPDATE playlists SET version = version + 1 WHERE id = $1", playlistID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM songs_playlists WHERE playlist_id = $1", playlistID); err != nil {
+		return err
+	}
+
+	trackReasons := make([]string, len(trackIDs))
+	for i, trackID := range trackIDs {
+		trackReasons[i] = reasons[trackID]
+	}
+	query := `
+		INSERT INTO songs_playlists (playlist_id, track_id, position, reason)
+		SELECT $1, t.track_id, t.ord::int, NULLIF(t.reason, '')
+		FROM unnest($2::text[], $3::text[]) WITH ORDINALITY AS t(track_id, reason, ord)`
+	_, err := tx.Exec(ctx, query, playlistID, trackIDs, trackReasons)
+	return err
+}
+
+// ReorderPlaylistTracks puts the live tracks of a playlist in the order of trackIDs,
+// keeping their reasons. Tracks removed from the catalog keep their positions, so they
+// reappear where they were if restored. It fails with ErrPlaylistChanged unless trackIDs
+// holds exactly the live tracks in the playlist.
+func (r *playlistRepository) ReorderPlaylistTracks(ctx context.Context, playlistID int, trackIDs []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SELECT id FROM playlists WHERE id = $1 FOR UPDATE", playlistID); err != nil {
+		return err
+	}
+	query := `
+		SELECT sp.track_id, COALESCE(sp.reason, ''), t.deleted_at IS NOT NULL
+		FROM songs_playlists sp
+		JOIN spotify_tracks t ON t.track_id = sp.track_id
+		WHERE sp.playlist_id = $1
+		ORDER BY sp.position, sp.created_at`
+	rows, err := tx.Query(ctx, query, playlistID)
+	if err != nil {
+		return err
+	}
+	var all, live []string
+	deleted := make(map[string]bool)
+	reasons := make(map[string]string)
+	for rows.Next() {
+		var trackID, reason string
+		var isDeleted bool
+		if err := rows.Scan(&trackID, &reason, &isDeleted); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, trackID)
+		if isDeleted {
+			deleted[trackID] = true
+		} else {
+			live = append(live, trackID)
+		}
+		if reason != "" {
+			reasons[trackID] = reason
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	proposed := slices.Clone(trackIDs)
+	slices.Sort(live)
+	slices.Sort(proposed)
+	if !slices.Equal(live, proposed) {
+		return ErrPlaylistChanged
+	}
+
+	// Fill the positions of live tracks with the new order, leaving deleted ones in place.
+	next := 0
+	for i, trackID := range all {
+		if !deleted[trackID] {
+			all[i] = trackIDs[next]
+			next++
+		}
+	}
+	if err := r.ReplacePlaylistTracksInTx(ctx, tx, playlistID, all, reasons); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
 func (r *playlistRepository) GetTrackInPlaylist(ctx context.Context, playlistID int, trackID string) (*models.SpotifyTrack, error) {
 	query := `
 		SELECT t.track_id, t.artists, t.album_name, t.track_name, t.popularity, t.duration_ms, t.explicit, t.danceability, t.energy, t.key, t.loudness, t.mode, t.speechiness, t.acousticness, t.instrumentalness, t.liveness, t.valence, t.tempo, t.time_signature, t.track_genre
@@ -130,7 +270,8 @@ func (r *playlistRepository) GetTracksInPlaylist(ctx context.Context, playlistID
 		SELECT t.track_id, t.artists, t.album_name, t.track_name, t.popularity, t.duration_ms, t.explicit, t.danceability, t.energy, t.key, t.loudness, t.mode, t.speechiness, t.acousticness, t.instrumentalness, t.liveness, t.valence, t.tempo, t.time_signature, t.track_genre
 		FROM spotify_tracks t
 		JOIN songs_playlists sp ON t.track_id = sp.track_id
-		WHERE sp.playlist_id = $1`
+		WHERE sp.playlist_id = $1 AND t.deleted_at IS NULL
+		ORDER BY sp.position, sp.created_at`
 	rows, err := r.db.Query(ctx, query, playlistID)
 	if err != nil {
 		return nil, err
@@ -149,3 +290,93 @@ func (r *playlistRepository) GetTracksInPlaylist(ctx context.Context, playlistID
 	}
 	return tracks, nil
 }
+
+// ListTracksInPlaylist pages through the tracks of a playlist in playlist order.
+func (r *playlistRepository) ListTracksInPlaylist(ctx context.Context, playlistID int, page models.PageRequest) (*models.Page[models.SpotifyTrack], error) {
+	after, err := decodeCursor(page.Cursor, "position", "asc")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.Page[models.SpotifyTrack]{Items: []models.SpotifyTrack{}}
+	if page.WithTotal {
+		var total int64
+		countQuery := `
+			SELECT COUNT(*)
+			FROM songs_playlists sp
+			JOIN spotify_tracks t ON t.track_id = sp.track_id
+			WHERE sp.playlist_id = $1 AND t.deleted_at IS NULL`
+		if err := r.db.QueryRow(ctx, countQuery, playlistID).Scan(&total); err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	query := `
+		SELECT t.track_id, t.artists, t.album_name, t.track_name, t.popularity, t.duration_ms, t.explicit, t.danceability, t.energy, t.key, t.loudness, t.mode, t.speechiness, t.acousticness, t.instrumentalness, t.liveness, t.valence, t.tempo, t.time_signature, t.track_genre, sp.position
+		FROM spotify_tracks t
+		JOIN songs_playlists sp ON t.track_id = sp.track_id
+		WHERE sp.playlist_id = $1 AND t.deleted_at IS NULL`
+	var rows pgx.Rows
+	if after != nil {
+		query += ` AND (sp.position, sp.track_id) > ($2::integer, $3) ORDER BY sp.position, sp.track_id LIMIT $4`
+		rows, err = r.db.Query(ctx, query, playlistID, after.Value, after.ID, page.Limit+1)
+	} else {
+		query += ` ORDER BY sp.position, sp.track_id LIMIT $2 OFFSET $3`
+		rows, err = r.db.Query(ctx, query, playlistID, page.Limit+1, page.Offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var positions []int
+	for rows.Next() {
+		var track models.SpotifyTrack
+		var position int
+		if err := rows.Scan(
+			&track.TrackID, &track.Artists, &track.AlbumName, &track.TrackName, &track.Popularity, &track.DurationMs, &track.Explicit, &track.Danceability, &track.Energy, &track.Key, &track.Loudness, &track.Mode, &track.Speechiness, &track.Acousticness, &track.Instrumentalness, &track.Liveness, &track.Valence, &track.Tempo, &track.TimeSignature, &track.TrackGenre, &position,
+		); err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, track)
+		positions = append(positions, position)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var more bool
+	if result.Items, more = trimPage(result.Items, page.Limit); more {
+		last := len(result.Items) - 1
+		result.NextCursor = cursor{Sort: "position", Order: "asc", Value: strconv.Itoa(positions[last]), ID: result.Items[last].TrackID}.encode()
+	}
+	return result, nil
+}
+
+// GetTrackReasons returns why each of trackIDs is in a playlist, for the tracks that have
+// a reason.
+func (r *playlistRepository) GetTrackReasons(ctx context.Context, playlistID int, trackIDs []string) (map[string]string, error) {
+	reasons := make(map[string]string)
+	if len(trackIDs) == 0 {
+		return reasons, nil
+	}
+	query := `
+		SELECT track_id, reason
+		FROM songs_playlists
+		WHERE playlist_id = $1 AND track_id = ANY($2) AND reason IS NOT NULL`
+	rows, err := r.db.Query(ctx, query, playlistID, trackIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var trackID, reason string
+		if err := rows.Scan(&trackID, &reason); err != nil {
+			return nil, err
+		}
+		reasons[trackID] = reason
+	}
+	return reasons, rows.Err()
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+type RecommendationRepository interface {
+	CreateRunInTx(ctx context.Context, tx pgx.Tx, run *models.RecommendationRun) (int, error)
+}
+
+type recommendationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRecommendationRepository(db *pgxpool.Pool) RecommendationRepository {
+	return &recommendationRepository{db: db}
+}
+
+func (r *recommendationRepository) CreateRunInTx(ctx context.Context, tx pgx.Tx, run *models.RecommendationRun) (int, error) {
+	query := `INSERT INTO recommendation_runs (user_id, playlist_id, model_version, track_count) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err := tx.QueryRow(ctx, query, run.UserID, run.PlaylistID, run.ModelVersion, run.TrackCount).Scan(&run.ID, &run.CreatedAt)
+	return run.ID, err
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+// SearchRepository runs relevance-ranked fuzzy searches backed by the pg_trgm indexes.
+// Matching uses word similarity (the `<%` operator), so a query matches a misspelt or
+// partial word anywhere in the text. The score blends that similarity with popularity.
+type SearchRepository interface {
+	SearchTracks(ctx context.Context, query string, popularityWeight float64, limit int) ([]models.TrackSearchHit, error)
+	SearchArtists(ctx context.Context, query string, popularityWeight float64, limit int) ([]models.ArtistSearchHit, error)
+	SearchAlbums(ctx context.Context, query string, popularityWeight float64, limit int) ([]models.AlbumSearchHit, error)
+	ListSuggestions(ctx context.Context) ([]models.Suggestion, error)
+	SuggestFuzzy(ctx context.Context, query string, limit int) ([]models.Suggestion, error)
+}
+
+type searchRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSearchRepository(db *pgxpool.Pool) SearchRepository {
+	return &searchRepository{db: db}
+}
+
+// SearchTracks matches track names, artists and album names at once. A match on the
+// track name counts fully, on the artists slightly less and on the album least.
+func (r *searchRepository) SearchTracks(ctx context.Context, query string, popularityWeight float64, limit int) ([]models.TrackSearchHit, error) {
+	sqlQuery := `
+		SELECT track_id, artists, album_name, track_name, popularity, duration_ms, explicit, danceability, energy, key, loudness, mode, speechiness, acousticness, instrumentalness, liveness, valence, tempo, time_signature, track_genre,
+			(1 - $2::float8) * relevance + $2::float8 * popularity / 100.0 AS score
+		FROM (
+			SELECT t.*, GREATEST(
+				word_similarity($1, t.track_name),
+				word_similarity($1, t.artists) * 0.9,
+				word_similarity($1, t.album_name) * 0.8
+			) AS relevance
+			FROM spotify_tracks t
+			WHERE t.deleted_at IS NULL AND ($1 <% t.track_name OR $1 <% t.artists OR $1 <% t.album_name)
+		) matches
+		ORDER BY score DESC, track_id
+		LIMIT $3`
+	rows, err := r.db.Query(ctx, sqlQuery, query, popularityWeight, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []models.TrackSearchHit{}
+	for rows.Next() {
+		var hit models.TrackSearchHit
+		track := &hit.Track.SpotifyTrack
+		if err := rows.Scan(
+			&track.TrackID, &track.Artists, &track.AlbumName, &track.TrackName, &track.Popularity, &track.DurationMs, &track.Explicit, &track.Danceability, &track.Energy, &track.Key, &track.Loudness, &track.Mode, &track.Speechiness, &track.Acousticness, &track.Instrumentalness, &track.Liveness, &track.Valence, &track.Tempo, &track.TimeSignature, &track.TrackGenre,
+			&hit.Score,
+		); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// SearchArtists ranks artists by name similarity and the popularity of their best track.
+func (r *searchRepository) SearchArtists(ctx context.Context, query string, popularityWeight float64, limit int) ([]models.ArtistSearchHit, error) {
+	sqlQuery := `
+		SELECT a.id, a.name, (1 - $2::float8) * word_similarity($1, a.name) + $2::float8 * COALESCE(p.popularity, 0) / 100.0 AS score
+		FROM artists a
+		LEFT JOIN LATERAL (
+			SELECT MAX(t.popularity) AS popularity
+			FROM track_artists ta
+			JOIN spotify_tracks t ON t.track_id = ta.track_id
+			WHERE ta.artist_id = a.id AND t.deleted_at IS NULL
+		) p ON true
+		WHERE $1 <% a.name
+		ORDER BY score DESC, a.id
+		LIMIT $3`
+	rows, err := r.db.Query(ctx, sqlQuery, query, popularityWeight, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []models.ArtistSearchHit{}
+	for rows.Next() {
+		var hit models.ArtistSearchHit
+		if err := rows.Scan(&hit.Artist.ID, &hit.Artist.Name, &hit.Score); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// SearchAlbums ranks albums by name similarity and the popularity of their best track.
+func (r *searchRepository) SearchAlbums(ctx context.Context, query string, popularityWeight float64, limit int) ([]models.AlbumSearchHit, error) {
+	sqlQuery := `
+		SELECT al.id, al.name, al.artist_id, ar.name, (1 - $2::float8) * word_similarity($1, al.name) + $2::float8 * COALESCE(p.popularity, 0) / 100.0 AS score
+		FROM albums al
+		JOIN artists ar ON ar.id = al.artist_id
+		LEFT JOIN LATERAL (
+			SELECT MAX(t.popularity) AS popularity
+			FROM album_tracks alt
+			JOIN spotify_tracks t ON t.track_id = alt.track_id
+			WHERE alt.album_id = al.id AND t.deleted_at IS NULL
+		) p ON true
+		WHERE $1 <% al.name
+		ORDER BY score DESC, al.id
+		LIMIT $3`
+	rows, err := r.db.Query(ctx, sqlQuery, query, popularityWeight, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []models.AlbumSearchHit{}
+	for rows.Next() {
+		var hit models.AlbumSearchHit
+		if err := rows.Scan(&hit.Album.ID, &hit.Album.Name, &hit.Album.ArtistID, &hit.ArtistName, &hit.Score); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// ListSuggestions returns every distinct track name (with its most popular track) and
+// every artist name in the live catalog, as the source of the typeahead index.
+func (r *searchRepository) ListSuggestions(ctx context.Context) ([]models.Suggestion, error) {
+	query := `
+		SELECT DISTINCT ON (lower(track_name)) track_name, 'track', track_id, popularity
+		FROM spotify_tracks
+		WHERE deleted_at IS NULL
+		ORDER BY lower(track_name), popularity DESC, track_id
+	`
+	suggestions, err := r.querySuggestions(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	artistQuery := `
+		SELECT btrim(a.name), 'artist', '', MAX(t.popularity)
+		FROM spotify_tracks t
+		CROSS JOIN LATERAL unnest(string_to_array(t.artists, ';')) AS a(name)
+		WHERE t.deleted_at IS NULL AND btrim(a.name) <> ''
+		GROUP BY btrim(a.name)
+	`
+	artists, err := r.querySuggestions(ctx, artistQuery)
+	if err != nil {
+		return nil, err
+	}
+	return append(suggestions, artists...), nil
+}
+
+// SuggestFuzzy finds track and artist names similar to query, for queries the prefix
+// index has no completions for (typically because of a typo).
+func (r *searchRepository) SuggestFuzzy(ctx context.Context, query string, limit int) ([]models.Suggestion, error) {
+	sqlQuery := `
+		SELECT text, type, track_id, popularity
+		FROM (
+			(SELECT track_name AS text, 'track' AS type, track_id, popularity, word_similarity($1, track_name) AS similarity
+			FROM spotify_tracks
+			WHERE deleted_at IS NULL AND $1 <% track_name
+			ORDER BY similarity DESC, popularity DESC
+			LIMIT $2)
+			UNION ALL
+			(SELECT name, 'artist', '', 0, word_similarity($1, name)
+			FROM artists
+			WHERE $1 <% name
+			ORDER BY 5 DESC
+			LIMIT $2)
+		) matches
+		ORDER BY similarity DESC, popularity DESC
+		LIMIT $2
+	`
+	return r.querySuggestions(ctx, sqlQuery, query, limit)
+}
+
+func (r *searchRepository) querySuggestions(ctx context.Context, query string, args ...any) ([]models.Suggestion, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []models.Suggestion
+	for rows.Next() {
+		var suggestion models.Suggestion
+		if err := rows.Scan(&suggestion.Text, &suggestion.Type, &suggestion.TrackID, &suggestion.Popularity); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, rows.Err()
+}
@@ -2,18 +2,35 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kiasoh/basic-spotify-backend/models"
 )
 
 type SpotifyTrackRepository interface {
 	GetByTrackID(ctx context.Context, trackID string) (*models.SpotifyTrack, error)
-	List(ctx context.Context, limit int, offset int, sortBy string, order string) ([]models.SpotifyTrack, error)
-	Search(ctx context.Context, query string, searchField string, limit int, offset int) ([]models.SpotifyTrack, error)
+	List(ctx context.Context, filter models.TrackFilter, page models.PageRequest, sortBy string, order string) (*models.Page[models.SpotifyTrack], error)
+	Search(ctx context.Context, query string, searchField string, page models.PageRequest) (*models.Page[models.SpotifyTrack], error)
+	GetByTrackIDs(ctx context.Context, trackIDs []string) ([]models.SpotifyTrack, error)
+	ListAll(ctx context.Context) ([]models.SpotifyTrack, error)
+	GetTrackGenres(ctx context.Context, trackIDs []string) (map[string]string, error)
+	ListGenres(ctx context.Context) ([]models.Genre, error)
+	LockTrackInTx(ctx context.Context, tx pgx.Tx, trackID string) (*models.SpotifyTrack, bool, error)
+	CreateTrackInTx(ctx context.Context, tx pgx.Tx, track *models.SpotifyTrack) error
+	UpdateTrackInTx(ctx context.Context, tx pgx.Tx, track *models.SpotifyTrack) error
+	SetTrackDeletedInTx(ctx context.Context, tx pgx.Tx, trackID string, deleted bool) error
+	CreateAuditEntryInTx(ctx context.Context, tx pgx.Tx, entry *models.TrackAuditEntry) error
+	ListAuditEntries(ctx context.Context, trackID string, limit int, offset int) ([]models.TrackAuditEntry, error)
 }
 
+// ErrTrackExists is returned by CreateTrackInTx when the track ID is already taken,
+// including by a soft-deleted track.
+var ErrTrackExists = errors.New("track already exists")
+
 type spotifyTrackRepository struct {
 	db *pgxpool.Pool
 }
@@ -23,7 +40,7 @@ func NewSpotifyTrackRepository(db *pgxpool.Pool) SpotifyTrackRepository {
 }
 
 func (r *spotifyTrackRepository) GetByTrackID(ctx context.Context, trackID string) (*models.SpotifyTrack, error) {
-	query := `SELECT track_id, artists, album_name, track_name, popularity, duration_ms, explicit, danceability, energy, key, loudness, mode, speechiness, acousticness, instrumentalness, liveness, valence, tempo, time_signature, track_genre FROM spotify_tracks WHERE track_id = $1`
+	query := `SELECT track_id, artists, album_name, track_name, popularity, duration_ms, explicit, danceability, energy, key, loudness, mode, speechiness, acousticness, instrumentalness, liveness, valence, tempo, time_signature, track_genre FROM spotify_tracks WHERE track_id = $1 AND deleted_at IS NULL`
 	track := &models.SpotifyTrack{}
 	err := r.db.QueryRow(ctx, query, trackID).Scan(
 		&track.TrackID, &track.Artists, &track.AlbumName, &track.TrackName, &track.Popularity, &track.DurationMs, &track.Explicit, &track.Danceability, &track.Energy, &track.Key, &track.Loudness, &track.Mode, &track.Speechiness, &track.Acousticness, &track.Instrumentalness, &track.Liveness, &track.Valence, &track.Tempo, &track.TimeSignature, &track.TrackGenre,
@@ -34,63 +51,131 @@ func (r *spotifyTrackRepository) GetByTrackID(ctx context.Context, trackID strin
 	return track, nil
 }
 
-func (r *spotifyTrackRepository) List(ctx context.Context, limit int, offset int, sortBy string, order string) ([]models.SpotifyTrack, error) {
-	// Default sort by popularity if not specified or invalid
-	if sortBy == "" {
-		sortBy = "popularity"
+// List returns the live tracks matching filter, sorted and paginated.
+func (r *spotifyTrackRepository) List(ctx context.Context, filter models.TrackFilter, page models.PageRequest, sortBy string, order string) (*models.Page[models.SpotifyTrack], error) {
+	q, err := newTrackQuery(filter)
+	if err != nil {
+		return nil, err
 	}
+	return r.listTracks(ctx, q, page, sortBy, order)
+}
 
-	// Validate sortBy field to prevent SQL injection
-	// This list should ideally be dynamic or more comprehensive
-	validSortFields := map[string]bool{
-		"track_id": true, "artists": true, "album_name": true, "track_name": true,
-		"popularity": true, "duration_ms": true, "explicit": true, "danceability": true,
-		"energy": true, "key": true, "loudness": true, "mode": true,
-		"speechiness": true, "acousticness": true, "instrumentalness": true,
-		"liveness": true, "valence": true, "tempo": true, "time_signature": true,
-		"track_genre": true,
+func (r *spotifyTrackRepository) Search(ctx context.Context, query string, searchField string, page models.PageRequest) (*models.Page[models.SpotifyTrack], error) {
+	// Basic validation for searchField to prevent SQL injection
+	switch searchField {
+	case "track_name", "artists":
+		// Valid fields
+	default:
+		return nil, fmt.Errorf("invalid search field: %s", searchField)
 	}
 
-	if !validSortFields[sortBy] {
-		return nil, fmt.Errorf("invalid sort field: %s", sortBy)
+	q := &trackQuery{}
+	q.where("deleted_at IS NULL")
+	q.where(searchField+" ILIKE '%' || ? || '%'", query)
+	return r.listTracks(ctx, q, page, "popularity", "desc")
+}
+
+// listTracks runs a track query page by page. Pages follow the cursor in page when
+// there is one and fall back to page.Offset otherwise.
+func (r *spotifyTrackRepository) listTracks(ctx context.Context, q *trackQuery, page models.PageRequest, sortBy string, order string) (*models.Page[models.SpotifyTrack], error) {
+	sortBy, order, err := trackSort(sortBy, order)
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(page.Cursor, sortBy, order)
+	if err != nil {
+		return nil, err
 	}
 
-	if order != "asc" && order != "desc" {
-		order = "desc"
+	result := &models.Page[models.SpotifyTrack]{Items: []models.SpotifyTrack{}}
+	if page.WithTotal {
+		var total int64
+		countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM spotify_tracks %s`, q.whereClause())
+		if err := r.db.QueryRow(ctx, countQuery, q.args...).Scan(&total); err != nil {
+			return nil, err
+		}
+		result.Total = &total
 	}
 
-	query := fmt.Sprintf(`SELECT track_id, artists, album_name, track_name, popularity, duration_ms, explicit, danceability, energy, key, loudness, mode, speechiness, acousticness, instrumentalness, liveness, valence, tempo, time_signature, track_genre FROM spotify_tracks ORDER BY %s %s LIMIT $1 OFFSET $2`, sortBy, order)
-	rows, err := r.db.Query(ctx, query, limit, offset)
+	offset := page.Offset
+	if after != nil {
+		q.afterTrack(sortBy, order, after)
+		offset = 0
+	}
+	query := fmt.Sprintf(`SELECT %s, %s IS NULL FROM spotify_tracks %s %s LIMIT %s OFFSET %s`, trackColumns, sortBy, q.whereClause(), trackOrderBy(sortBy, order), q.arg(page.Limit+1), q.arg(offset))
+	rows, err := r.db.Query(ctx, query, q.args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var tracks []models.SpotifyTrack
+	var sortNull []bool // whether each track's sort value is NULL, for the cursor
 	for rows.Next() {
 		var track models.SpotifyTrack
+		var null bool
 		if err := rows.Scan(
-			&track.TrackID, &track.Artists, &track.AlbumName, &track.TrackName, &track.Popularity, &track.DurationMs, &track.Explicit, &track.Danceability, &track.Energy, &track.Key, &track.Loudness, &track.Mode, &track.Speechiness, &track.Acousticness, &track.Instrumentalness, &track.Liveness, &track.Valence, &track.Tempo, &track.TimeSignature, &track.TrackGenre,
+			&track.TrackID, &track.Artists, &track.AlbumName, &track.TrackName, &track.Popularity, &track.DurationMs, &track.Explicit, &track.Danceability, &track.Energy, &track.Key, &track.Loudness, &track.Mode, &track.Speechiness, &track.Acousticness, &track.Instrumentalness, &track.Liveness, &track.Valence, &track.Tempo, &track.TimeSignature, &track.TrackGenre, &null,
 		); err != nil {
 			return nil, err
 		}
-		tracks = append(tracks, track)
+		result.Items = append(result.Items, track)
+		sortNull = append(sortNull, null)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var more bool
+	if result.Items, more = trimPage(result.Items, page.Limit); more {
+		last := result.Items[len(result.Items)-1]
+		next := cursor{Sort: sortBy, Order: order, ID: last.TrackID, Null: sortNull[len(result.Items)-1]}
+		if !next.Null {
+			next.Value = trackSortValue(&last, sortBy)
+		}
+		result.NextCursor = next.encode()
+	}
+	return result, nil
+}
+
+// GetByTrackIDs returns the live tracks in trackIDs, in the order given. Unknown and
+// deleted tracks are skipped.
+func (r *spotifyTrackRepository) GetByTrackIDs(ctx context.Context, trackIDs []string) ([]models.SpotifyTrack, error) {
+	if len(trackIDs) == 0 {
+		return []models.SpotifyTrack{}, nil
+	}
+	query := fmt.Sprintf(`SELECT %s FROM spotify_tracks WHERE track_id = ANY($1) AND deleted_at IS NULL`, trackColumns)
+	found, err := r.queryTracks(ctx, query, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tracks: %w", err)
+	}
+
+	byID := make(map[string]models.SpotifyTrack, len(found))
+	for _, track := range found {
+		byID[track.TrackID] = track
+	}
+	tracks := make([]models.SpotifyTrack, 0, len(found))
+	for _, id := range trackIDs {
+		if track, ok := byID[id]; ok {
+			tracks = append(tracks, track)
+		}
 	}
 	return tracks, nil
 }
 
-func (r *spotifyTrackRepository) Search(ctx context.Context, query string, searchField string, limit int, offset int) ([]models.SpotifyTrack, error) {
-	// Basic validation for searchField to prevent SQL injection
-	switch searchField {
-	case "track_name", "artists":
-		// Valid fields
-	default:
-		return nil, fmt.Errorf("invalid search field: %s", searchField)
+// ListAll returns every live track, ordered by track ID. It is meant for building
+// in-memory indexes over the whole catalog, not for serving requests.
+func (r *spotifyTrackRepository) ListAll(ctx context.Context) ([]models.SpotifyTrack, error) {
+	query := fmt.Sprintf(`SELECT %s FROM spotify_tracks WHERE deleted_at IS NULL ORDER BY track_id`, trackColumns)
+	tracks, err := r.queryTracks(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tracks: %w", err)
 	}
+	return tracks, nil
+}
 
-	sqlQuery := fmt.Sprintf(`SELECT track_id, artists, album_name, track_name, popularity, duration_ms, explicit, danceability, energy, key, loudness, mode, speechiness, acousticness, instrumentalness, liveness, valence, tempo, time_signature, track_genre FROM spotify_tracks WHERE %s ILIKE '%%' || $1 || '%%' ORDER BY popularity DESC LIMIT $2 OFFSET $3`, searchField)
-	
-	rows, err := r.db.Query(ctx, sqlQuery, query, limit, offset)
+// queryTracks runs a query selecting trackColumns and scans every row.
+func (r *spotifyTrackRepository) queryTracks(ctx context.Context, query string, args ...any) ([]models.SpotifyTrack, error) {
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -106,5 +191,129 @@ func (r *spotifyTrackRepository) Search(ctx context.Context, query string, searc
 		}
 		tracks = append(tracks, track)
 	}
-	return tracks, nil
+	return tracks, rows.Err()
+}
+
+// GetTrackGenres returns the genre of every live track in trackIDs. Unknown and
+// deleted tracks are missing from the map.
+func (r *spotifyTrackRepository) GetTrackGenres(ctx context.Context, trackIDs []string) (map[string]string, error) {
+	genres := make(map[string]string)
+	if len(trackIDs) == 0 {
+		return genres, nil
+	}
+
+	rows, err := r.db.Query(ctx, `SELECT track_id, track_genre FROM spotify_tracks WHERE track_id = ANY($1) AND deleted_at IS NULL`, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get track genres: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var trackID, genre string
+		if err := rows.Scan(&trackID, &genre); err != nil {
+			return nil, fmt.Errorf("failed to scan track genre: %w", err)
+		}
+		genres[trackID] = genre
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return genres, nil
+}
+
+// ListGenres returns every genre in the catalog with its number of live tracks.
+func (r *spotifyTrackRepository) ListGenres(ctx context.Context) ([]models.Genre, error) {
+	rows, err := r.db.Query(ctx, `SELECT track_genre, COUNT(*) FROM spotify_tracks WHERE deleted_at IS NULL GROUP BY track_genre ORDER BY track_genre`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var genres []models.Genre
+	for rows.Next() {
+		var genre models.Genre
+		if err := rows.Scan(&genre.Name, &genre.TrackCount); err != nil {
+			return nil, err
+		}
+		genres = append(genres, genre)
+	}
+	return genres, rows.Err()
+}
+
+// LockTrackInTx loads a track, including a soft-deleted one, and locks its row until
+// the transaction ends. The boolean reports whether the track is soft-deleted.
+func (r *spotifyTrackRepository) LockTrackInTx(ctx context.Context, tx pgx.Tx, trackID string) (*models.SpotifyTrack, bool, error) {
+	query := `SELECT track_id, artists, album_name, track_name, popularity, duration_ms, explicit, danceability, energy, key, loudness, mode, speechiness, acousticness, instrumentalness, liveness, valence, tempo, time_signature, track_genre, deleted_at IS NOT NULL FROM spotify_tracks WHERE track_id = $1 FOR UPDATE`
+	track := &models.SpotifyTrack{}
+	var deleted bool
+	err := tx.QueryRow(ctx, query, trackID).Scan(
+		&track.TrackID, &track.Artists, &track.AlbumName, &track.TrackName, &track.Popularity, &track.DurationMs, &track.Explicit, &track.Danceability, &track.Energy, &track.Key, &track.Loudness, &track.Mode, &track.Speechiness, &track.Acousticness, &track.Instrumentalness, &track.Liveness, &track.Valence, &track.Tempo, &track.TimeSignature, &track.TrackGenre, &deleted,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	return track, deleted, nil
+}
+
+func (r *spotifyTrackRepository) CreateTrackInTx(ctx context.Context, tx pgx.Tx, track *models.SpotifyTrack) error {
+	query := `
+		INSERT INTO spotify_tracks (track_id, artists, album_name, track_name, popularity, duration_ms, explicit, danceability, energy, key, loudness, mode, speechiness, acousticness, instrumentalness, liveness, valence, tempo, time_signature, track_genre)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)`
+	_, err := tx.Exec(ctx, query,
+		track.TrackID, track.Artists, track.AlbumName, track.TrackName, track.Popularity, track.DurationMs, track.Explicit, track.Danceability, track.Energy, track.Key, track.Loudness, track.Mode, track.Speechiness, track.Acousticness, track.Instrumentalness, track.Liveness, track.Valence, track.Tempo, track.TimeSignature, track.TrackGenre,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+		return ErrTrackExists
+	}
+	return err
+}
+
+func (r *spotifyTrackRepository) UpdateTrackInTx(ctx context.Context, tx pgx.Tx, track *models.SpotifyTrack) error {
+	query := `
+		UPDATE spotify_tracks SET
+			artists = $2, album_name = $3, track_name = $4, popularity = $5, duration_ms = $6, explicit = $7, danceability = $8, energy = $9, key = $10,
+			loudness = $11, mode = $12, speechiness = $13, acousticness = $14, instrumentalness = $15, liveness = $16, valence = $17, tempo = $18, time_signature = $19, track_genre = $20
+		WHERE track_id = $1`
+	_, err := tx.Exec(ctx, query,
+		track.TrackID, track.Artists, track.AlbumName, track.TrackName, track.Popularity, track.DurationMs, track.Explicit, track.Danceability, track.Energy, track.Key, track.Loudness, track.Mode, track.Speechiness, track.Acousticness, track.Instrumentalness, track.Liveness, track.Valence, track.Tempo, track.TimeSignature, track.TrackGenre,
+	)
+	return err
+}
+
+// SetTrackDeletedInTx soft-deletes (deleted=true) or restores a track. Soft-deleted tracks
+// keep their playlist and interaction references but disappear from the catalog.
+func (r *spotifyTrackRepository) SetTrackDeletedInTx(ctx context.Context, tx pgx.Tx, trackID string, deleted bool) error {
+	query := `UPDATE spotify_tracks SET deleted_at = CASE WHEN $2 THEN now() ELSE NULL END WHERE track_id = $1`
+	if _, err := tx.Exec(ctx, query, trackID, deleted); err != nil {
+		return err
+	}
+	// Playlists hide deleted tracks, so their track lists change with it.
+	query = `UPDATE playlists SET version = version + 1 WHERE id IN (SELECT playlist_id FROM songs_playlists WHERE track_id = $1)`
+	_, err := tx.Exec(ctx, query, trackID)
+	return err
+}
+
+func (r *spotifyTrackRepository) CreateAuditEntryInTx(ctx context.Context, tx pgx.Tx, entry *models.TrackAuditEntry) error {
+	query := `INSERT INTO track_audit_log (track_id, actor_id, action, changes) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	return tx.QueryRow(ctx, query, entry.TrackID, entry.ActorID, entry.Action, entry.Changes).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+func (r *spotifyTrackRepository) ListAuditEntries(ctx context.Context, trackID string, limit int, offset int) ([]models.TrackAuditEntry, error) {
+	query := `SELECT id, track_id, actor_id, action, changes, created_at FROM track_audit_log WHERE track_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3`
+	rows, err := r.db.Query(ctx, query, trackID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.TrackAuditEntry
+	for rows.Next() {
+		var entry models.TrackAuditEntry
+		if err := rows.Scan(&entry.ID, &entry.TrackID, &entry.ActorID, &entry.Action, &entry.Changes, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
 }
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+type TrackNeighborRepository interface {
+	ListInteractionCounts(ctx context.Context) ([]models.InteractionCount, error)
+	GetUserInteractionCounts(ctx context.Context, userID int) ([]models.InteractionCount, error)
+	ReplaceNeighbors(ctx context.Context, neighbors []models.TrackNeighbor) error
+	GetNeighbors(ctx context.Context, trackIDs []string) ([]models.TrackNeighbor, error)
+}
+
+type trackNeighborRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTrackNeighborRepository(db *pgxpool.Pool) TrackNeighborRepository {
+	return &trackNeighborRepository{db: db}
+}
+
+// ListInteractionCounts counts the interactions of every user with every live track, by type.
+func (r *trackNeighborRepository) ListInteractionCounts(ctx context.Context) ([]models.InteractionCount, error) {
+	query := `
+		SELECT i.user_id, i.track_id, i.type, COUNT(*)
+		FROM interactions i
+		JOIN spotify_tracks t ON t.track_id = i.track_id AND t.deleted_at IS NULL
+		GROUP BY i.user_id, i.track_id, i.type`
+	return r.queryCounts(ctx, query)
+}
+
+// GetUserInteractionCounts counts the interactions of one user with every live track, by type.
+func (r *trackNeighborRepository) GetUserInteractionCounts(ctx context.Context, userID int) ([]models.InteractionCount, error) {
+	query := `
+		SELECT i.user_id, i.track_id, i.type, COUNT(*)
+		FROM interactions i
+		JOIN spotify_tracks t ON t.track_id = i.track_id AND t.deleted_at IS NULL
+		WHERE i.user_id = $1
+		GROUP BY i.user_id, i.track_id, i.type`
+	return r.queryCounts(ctx, query, userID)
+}
+
+func (r *trackNeighborRepository) queryCounts(ctx context.Context, query string, args ...any) ([]models.InteractionCount, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count interactions: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []models.InteractionCount
+	for rows.Next() {
+		var count models.InteractionCount
+		if err := rows.Scan(&count.UserID, &count.TrackID, &count.InteractionType, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan interaction count: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// ReplaceNeighbors swaps the whole neighbour table for neighbors in one transaction, so
+// readers see either the old or the new model.
+func (r *trackNeighborRepository) ReplaceNeighbors(ctx context.Context, neighbors []models.TrackNeighbor) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "DELETE FROM track_neighbors"); err != nil {
+		return err
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"track_neighbors"}, []string{"track_id", "neighbor_id", "score", "support"},
+		pgx.CopyFromSlice(len(neighbors), func(i int) ([]any, error) {
+			n := neighbors[i]
+			return []any{n.TrackID, n.NeighborID, n.Score, n.Support}, nil
+		}))
+	if err != nil {
+		return fmt.Errorf("failed to copy track neighbors: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// GetNeighbors returns the stored neighbours of trackIDs that are still live, best first per track.
+func (r *trackNeighborRepository) GetNeighbors(ctx context.Context, trackIDs []string) ([]models.TrackNeighbor, error) {
+	if len(trackIDs) == 0 {
+		return nil, nil
+	}
+	query := `
+		SELECT n.track_id, n.neighbor_id, n.score, n.support
+		FROM track_neighbors n
+		JOIN spotify_tracks t ON t.track_id = n.neighbor_id AND t.deleted_at IS NULL
+		WHERE n.track_id = ANY($1)
+		ORDER BY n.track_id, n.score DESC`
+	rows, err := r.db.Query(ctx, query, trackIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get track neighbors: %w", err)
+	}
+	defer rows.Close()
+
+	var neighbors []models.TrackNeighbor
+	for rows.Next() {
+		var n models.TrackNeighbor
+		if err := rows.Scan(&n.TrackID, &n.NeighborID, &n.Score, &n.Support); err != nil {
+			return nil, fmt.Errorf("failed to scan track neighbor: %w", err)
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, rows.Err()
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+// trackColumns is the column list every track query selects, in scan order.
+const trackColumns = `track_id, artists, album_name, track_name, popularity, duration_ms, explicit, danceability, energy, key, loudness, mode, speechiness, acousticness, instrumentalness, liveness, valence, tempo, time_signature, track_genre`
+
+// trackSortFields are the columns tracks may be sorted by, with their SQL type. The sort
+// field is spliced into the query, so it must be checked against this list to prevent
+// SQL injection. The type is used to cast cursor values back when paging.
+var trackSortFields = map[string]string{
+	"track_id": "text", "artists": "text", "album_name": "text", "track_name": "text",
+	"popularity": "bigint", "duration_ms": "bigint", "explicit": "boolean", "danceability": "double precision",
+	"energy": "double precision", "key": "bigint", "loudness": "double precision", "mode": "bigint",
+	"speechiness": "double precision", "acousticness": "double precision", "instrumentalness": "double precision",
+	"liveness": "double precision", "valence": "double precision", "tempo": "double precision", "time_signature": "bigint",
+	"track_genre": "text",
+}
+
+// trackSort validates a sort request. sortBy defaults to popularity and order to desc;
+// track_id always breaks ties so pages are stable.
+func trackSort(sortBy string, order string) (string, string, error) {
+	if sortBy == "" {
+		sortBy = "popularity"
+	}
+	if trackSortFields[sortBy] == "" {
+		return "", "", fmt.Errorf("invalid sort field: %s", sortBy)
+	}
+	if order != "asc" && order != "desc" {
+		order = "desc"
+	}
+	return sortBy, order, nil
+}
+
+// trackSortValue returns the value of the sort column of a track, formatted so that
+// casting it to the column type gives the value back exactly.
+func trackSortValue(t *models.SpotifyTrack, column string) string {
+	switch column {
+	case "track_id":
+		return t.TrackID
+	case "artists":
+		return t.Artists
+	case "album_name":
+		return t.AlbumName
+	case "track_name":
+		return t.TrackName
+	case "track_genre":
+		return t.TrackGenre
+	case "explicit":
+		return strconv.FormatBool(t.Explicit)
+	case "popularity":
+		return strconv.FormatInt(t.Popularity, 10)
+	case "duration_ms":
+		return strconv.FormatInt(t.DurationMs, 10)
+	case "key":
+		return strconv.FormatInt(t.Key, 10)
+	case "mode":
+		return strconv.FormatInt(t.Mode, 10)
+	case "time_signature":
+		return strconv.FormatInt(t.TimeSignature, 10)
+	}
+
+	var value float64
+	switch column {
+	case "danceability":
+		value = t.Danceability
+	case "energy":
+		value = t.Energy
+	case "loudness":
+		value = t.Loudness
+	case "speechiness":
+		value = t.Speechiness
+	case "acousticness":
+		value = t.Acousticness
+	case "instrumentalness":
+		value = t.Instrumentalness
+	case "liveness":
+		value = t.Liveness
+	case "valence":
+		value = t.Valence
+	case "tempo":
+		value = t.Tempo
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// trackQuery builds the WHERE clause of a track listing. Values are always passed as
+// numbered arguments; only whitelisted column names are spliced into the SQL.
+type trackQuery struct {
+	conditions []string
+	args       []any
+}
+
+// where adds a condition. Each "?" in cond is replaced by the placeholder of the matching arg.
+func (q *trackQuery) where(cond string, args ...any) {
+	for _, arg := range args {
+		q.args = append(q.args, arg)
+		cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(q.args)), 1)
+	}
+	q.conditions = append(q.conditions, cond)
+}
+
+// arg registers an argument that is not part of a condition (e.g. LIMIT) and returns its placeholder.
+func (q *trackQuery) arg(value any) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *trackQuery) whereClause() string {
+	return "WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// newTrackQuery starts a query over live tracks narrowed down by filter.
+func newTrackQuery(filter models.TrackFilter) (*trackQuery, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	q := &trackQuery{}
+	q.where("deleted_at IS NULL")
+
+	for _, field := range models.TrackRangeFields {
+		r, ok := filter.Ranges[field]
+		if !ok {
+			continue
+		}
+		if r.Min != nil {
+			q.where(field+" >= ?", *r.Min)
+		}
+		if r.Max != nil {
+			q.where(field+" <= ?", *r.Max)
+		}
+	}
+	if filter.Explicit != nil {
+		q.where("explicit = ?", *filter.Explicit)
+	}
+	if filter.Key != nil {
+		q.where("key = ?", *filter.Key)
+	}
+	if filter.Mode != nil {
+		q.where("mode = ?", *filter.Mode)
+	}
+	if filter.TimeSignature != nil {
+		q.where("time_signature = ?", *filter.TimeSignature)
+	}
+	if len(filter.Genres) > 0 {
+		q.where("track_genre = ANY(?)", filter.Genres)
+	}
+	return q, nil
+}
+
+// trackOrderBy returns the ORDER BY clause of a track listing. NULLs sort as if greater
+// than any value, Postgres' default, so that the sort column's indexes are used.
+func trackOrderBy(sortBy string, order string) string {
+	nulls := "NULLS LAST"
+	if order == "desc" {
+		nulls = "NULLS FIRST"
+	}
+	return fmt.Sprintf("ORDER BY %s %s %s, track_id", sortBy, order, nulls)
+}
+
+// afterTrack restricts the query to the tracks that sort after the cursor position,
+// in the order of trackOrderBy. Comparisons with NULL are never true, so tracks
+// without a sort value are matched explicitly: they follow every value in ascending
+// order and precede them in descending order.
+func (q *trackQuery) afterTrack(sortBy string, order string, after *cursor) {
+	if after.Null {
+		if order == "desc" {
+			q.where(fmt.Sprintf("((%s IS NULL AND track_id > ?) OR %s IS NOT NULL)", sortBy, sortBy), after.ID)
+		} else {
+			q.where(fmt.Sprintf("(%s IS NULL AND track_id > ?)", sortBy), after.ID)
+		}
+		return
+	}
+	op, nulls := ">", fmt.Sprintf(" OR %s IS NULL", sortBy)
+	if order == "desc" {
+		op, nulls = "<", ""
+	}
+	value := fmt.Sprintf("?::%s", trackSortFields[sortBy])
+	q.where(fmt.Sprintf("(%s %s %s OR (%s = %s AND track_id > ?)%s)", sortBy, op, value, sortBy, value, nulls), after.Value, after.Value, after.ID)
+}
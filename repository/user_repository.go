@@ -14,6 +14,9 @@ type UserRepository interface {
 	GetUserByID(ctx context.Context, id int) (*models.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
 	UpdateUser(ctx context.Context, user *models.User) error
+	LockUserInTx(ctx context.Context, tx pgx.Tx, id int) (*models.User, error)
+	UpdateAvgInterestInTx(ctx context.Context, tx pgx.Tx, userID int, avgInterest models.FloatVector) error
+	UpdateUserRole(ctx context.Context, id int, role models.Role) error
 	DeleteUser(ctx context.Context, id int) error
 	ListUsers(ctx context.Context) ([]models.User, error)
 }
@@ -27,13 +30,16 @@ func NewUserRepository(db *pgxpool.Pool) UserRepository {
 }
 
 func (r *userRepository) CreateUserInTx(ctx context.Context, tx pgx.Tx, user *models.User) (int, error) {
-	query := `INSERT INTO users (username, password, avg_interest) VALUES ($1, $2, $3) RETURNING id`
+	query := `INSERT INTO users (username, password, avg_interest, role) VALUES ($1, $2, $3, $4) RETURNING id`
 	var id int
 	avgInterest := user.AvgInterest
 	if avgInterest == nil {
 		avgInterest = models.FloatVector{0,0,0,0,0,0,0,0,0} // Ensure it's explicitly set to 9 empty slice if nil
 	}
-	err := tx.QueryRow(ctx, query, user.Username, user.Password.Bytes(), avgInterest).Scan(&id)
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	err := tx.QueryRow(ctx, query, user.Username, user.Password.Bytes(), avgInterest, user.Role).Scan(&id)
 	return id, err
 }
 
@@ -45,9 +51,9 @@ func (r *userRepository) UpdateRecommPlaylistIDInTx(ctx context.Context, tx pgx.
 
 
 func (r *userRepository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
-	query := `SELECT id, username, password, avg_interest, recomm_plylist_id, created_at FROM users WHERE id = $1`
+	query := `SELECT id, username, password, avg_interest, recomm_plylist_id, role, created_at FROM users WHERE id = $1`
 	user := &models.User{}
-	err := r.db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Username, &user.Password.Hash, &user.AvgInterest, &user.RecommPlaylistID, &user.CreatedAt)
+	err := r.db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Username, &user.Password.Hash, &user.AvgInterest, &user.RecommPlaylistID, &user.Role, &user.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -55,9 +61,9 @@ func (r *userRepository) GetUserByID(ctx context.Context, id int) (*models.User,
 }
 
 func (r *userRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
-	query := `SELECT id, username, password, avg_interest, recomm_plylist_id, created_at FROM users WHERE username = $1`
+	query := `SELECT id, username, password, avg_interest, recomm_plylist_id, role, created_at FROM users WHERE username = $1`
 	user := &models.User{}
-	err := r.db.QueryRow(ctx, query, username).Scan(&user.ID, &user.Username, &user.Password.Hash, &user.AvgInterest, &user.RecommPlaylistID, &user.CreatedAt)
+	err := r.db.QueryRow(ctx, query, username).Scan(&user.ID, &user.Username, &user.Password.Hash, &user.AvgInterest, &user.RecommPlaylistID, &user.Role, &user.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -74,6 +80,36 @@ func (r *userRepository) UpdateUser(ctx context.Context, user *models.User) erro
 	return err
 }
 
+// LockUserInTx loads a user and locks their row until the transaction ends, so that
+// concurrent updates of avg_interest cannot overwrite each other.
+func (r *userRepository) LockUserInTx(ctx context.Context, tx pgx.Tx, id int) (*models.User, error) {
+	query := `SELECT id, username, password, avg_interest, recomm_plylist_id, role, created_at FROM users WHERE id = $1 FOR UPDATE`
+	user := &models.User{}
+	err := tx.QueryRow(ctx, query, id).Scan(&user.ID, &user.Username, &user.Password.Hash, &user.AvgInterest, &user.RecommPlaylistID, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *userRepository) UpdateAvgInterestInTx(ctx context.Context, tx pgx.Tx, userID int, avgInterest models.FloatVector) error {
+	query := `UPDATE users SET avg_interest = $1 WHERE id = $2`
+	_, err := tx.Exec(ctx, query, avgInterest, userID)
+	return err
+}
+
+func (r *userRepository) UpdateUserRole(ctx context.Context, id int, role models.Role) error {
+	query := `UPDATE users SET role = $1 WHERE id = $2`
+	tag, err := r.db.Exec(ctx, query, role, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
 func (r *userRepository) DeleteUser(ctx context.Context, id int) error {
 	query := `DELETE FROM users WHERE id = $1`
 	_, err := r.db.Exec(ctx, query, id)
@@ -81,7 +117,7 @@ func (r *userRepository) DeleteUser(ctx context.Context, id int) error {
 }
 
 func (r *userRepository) ListUsers(ctx context.Context) ([]models.User, error) {
-	query := `SELECT id, username, avg_interest, recomm_plylist_id, created_at FROM users`
+	query := `SELECT id, username, avg_interest, recomm_plylist_id, role, created_at FROM users ORDER BY id`
 	rows, err := r.db.Query(ctx, query)
 	if err != nil {
 		return nil, err
@@ -91,7 +127,7 @@ func (r *userRepository) ListUsers(ctx context.Context) ([]models.User, error) {
 	var users []models.User
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(&user.ID, &user.Username, &user.AvgInterest, &user.RecommPlaylistID, &user.CreatedAt); err != nil {
+		if err := rows.Scan(&user.ID, &user.Username, &user.AvgInterest, &user.RecommPlaylistID, &user.Role, &user.CreatedAt); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
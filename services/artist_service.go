@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+type ArtistService struct {
+	Repo repository.ArtistRepository
+}
+
+func NewArtistService(repo repository.ArtistRepository) *ArtistService {
+	return &ArtistService{Repo: repo}
+}
+
+func (s *ArtistService) GetArtist(ctx context.Context, artistID int) (*models.ArtistDetail, error) {
+	log.Printf("Service: Attempting to get artist %d", artistID)
+	artist, err := s.Repo.GetArtist(ctx, artistID)
+	if err != nil {
+		log.Printf("Service: Error getting artist %d: %v", artistID, err)
+		return nil, errors.New("artist not found")
+	}
+	return artist, nil
+}
+
+func (s *ArtistService) ListArtistTracks(ctx context.Context, artistID int, limit int, offset int) ([]models.SpotifyTrack, error) {
+	log.Printf("Service: Listing tracks of artist %d with limit %d and offset %d", artistID, limit, offset)
+	if _, err := s.GetArtist(ctx, artistID); err != nil {
+		return nil, err
+	}
+	return s.Repo.ListArtistTracks(ctx, artistID, limit, offset)
+}
+
+func (s *ArtistService) GetAlbum(ctx context.Context, albumID int) (*models.AlbumDetail, error) {
+	log.Printf("Service: Attempting to get album %d", albumID)
+	album, err := s.Repo.GetAlbum(ctx, albumID)
+	if err != nil {
+		log.Printf("Service: Error getting album %d: %v", albumID, err)
+		return nil, errors.New("album not found")
+	}
+	return album, nil
+}
+
+// Backfill derives artists and albums from the text columns of the whole catalog.
+func (s *ArtistService) Backfill(ctx context.Context) (*models.ArtistBackfillResult, error) {
+	log.Println("Service: Backfilling artists and albums")
+	result, err := s.Repo.Backfill(ctx)
+	if err != nil {
+		log.Printf("Service: Error backfilling artists and albums: %v", err)
+		return nil, err
+	}
+	log.Printf("Service: Backfill created %d artists, %d track artists, %d albums and %d album tracks", result.Artists, result.TrackArtists, result.Albums, result.AlbumTracks)
+	return result, nil
+}
+
+// AttachArtists fills the structured artists and album of each response in place.
+func (s *ArtistService) AttachArtists(ctx context.Context, responses []models.SpotifyTrackResponse) error {
+	trackIDs := make([]string, len(responses))
+	for i, response := range responses {
+		trackIDs[i] = response.TrackID
+	}
+
+	artists, err := s.Repo.GetArtistsForTracks(ctx, trackIDs)
+	if err != nil {
+		return err
+	}
+	albums, err := s.Repo.GetAlbumsForTracks(ctx, trackIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range responses {
+		responses[i].ArtistDetails = artists[responses[i].TrackID]
+		if album, ok := albums[responses[i].TrackID]; ok {
+			responses[i].Album = &album
+		}
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/kiasoh/basic-spotify-backend/models"
 	"github.com/kiasoh/basic-spotify-backend/repository"
 )
 
@@ -42,7 +43,7 @@ func (s *AuthService) Login(ctx context.Context, username, plaintextPassword str
 	}
 
 	log.Printf("User %s authenticated successfully. Generating token.", username)
-	token, err := s.GenerateJWT(user.ID)
+	token, err := s.GenerateJWT(user.ID, user.Role)
 	if err != nil {
 		log.Printf("Error generating JWT for user %s: %v", username, err)
 		return "", errors.New("error generating token")
@@ -51,10 +52,13 @@ func (s *AuthService) Login(ctx context.Context, username, plaintextPassword str
 	return token, nil
 }
 
-// generateJWT creates a new JWT token for a given user ID.
-func (s *AuthService) GenerateJWT(userID int) (string, error) {
+// generateJWT creates a new JWT token for a given user ID and role.
+// The role claim tells clients the role at login; middleware.RequireRole checks the
+// user's current role instead, so role changes need no new token.
+func (s *AuthService) GenerateJWT(userID int, role models.Role) (string, error) {
 	claims := jwt.MapClaims{
 		"sub": userID,                          // Subject (who the token is for)
+		"role": role,                           // Role at login, for clients
 		"iat": time.Now().Unix(),               // Issued At
 		"exp": time.Now().Add(24*time.Hour).Unix(), // Expiration Time (1 hour)
 	}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// catalogRefreshInterval is how often in-memory catalog indexes check for changes.
+const catalogRefreshInterval = 30 * time.Second
+
+// catalogRefresher rebuilds an in-memory index derived from the catalog: once at
+// startup and then in the background after CatalogChanged reported a change. Embed it
+// to make an index a CatalogObserver.
+type catalogRefresher struct {
+	stale atomic.Bool
+}
+
+// CatalogChanged implements CatalogObserver.
+func (c *catalogRefresher) CatalogChanged(trackID string) {
+	c.stale.Store(true)
+}
+
+// run calls refresh now and after every change, until ctx is done. Failed refreshes are
+// retried on the next tick.
+func (c *catalogRefresher) run(ctx context.Context, name string, refresh func(ctx context.Context) error) {
+	if err := refresh(ctx); err != nil {
+		log.Printf("Service: Building %s failed: %v", name, err)
+		c.stale.Store(true)
+	}
+
+	ticker := time.NewTicker(catalogRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if c.stale.Swap(false) {
+				if err := refresh(ctx); err != nil {
+					log.Printf("Service: Rebuilding %s failed: %v", name, err)
+					c.stale.Store(true)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"reflect"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+// CatalogObserver is told about every committed catalog change, e.g. to refresh an
+// in-memory index built from the catalog.
+type CatalogObserver interface {
+	CatalogChanged(trackID string)
+}
+
+// CatalogService lets curators maintain the track catalog. Every change is written
+// to the audit log in the same transaction as the change itself.
+type CatalogService struct {
+	DB         *pgxpool.Pool
+	TrackRepo  repository.SpotifyTrackRepository
+	ArtistRepo repository.ArtistRepository
+	Observers  []CatalogObserver
+}
+
+func NewCatalogService(db *pgxpool.Pool, trackRepo repository.SpotifyTrackRepository, artistRepo repository.ArtistRepository, observers ...CatalogObserver) *CatalogService {
+	return &CatalogService{DB: db, TrackRepo: trackRepo, ArtistRepo: artistRepo, Observers: observers}
+}
+
+// trackFields flattens a track into its JSON field names and values.
+func trackFields(track *models.SpotifyTrack) (map[string]any, error) {
+	data, err := json.Marshal(track)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// diffTracks returns the fields whose value differs between old and new. A nil old
+// track reports every field of new as added.
+func diffTracks(old, new *models.SpotifyTrack) (map[string]models.FieldChange, error) {
+	newFields, err := trackFields(new)
+	if err != nil {
+		return nil, err
+	}
+	oldFields := map[string]any{}
+	if old != nil {
+		if oldFields, err = trackFields(old); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := make(map[string]models.FieldChange)
+	for name, newValue := range newFields {
+		oldValue := oldFields[name]
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes[name] = models.FieldChange{Old: oldValue, New: newValue}
+		}
+	}
+	return changes, nil
+}
+
+// withTrackTx runs fn in a transaction and appends the audit entry it returns.
+func (s *CatalogService) withTrackTx(ctx context.Context, fn func(tx pgx.Tx) (*models.TrackAuditEntry, error)) error {
+	// --- Start Transaction ---
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	entry, err := fn(tx)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		if err := s.TrackRepo.CreateAuditEntryInTx(ctx, tx, entry); err != nil {
+			log.Printf("Service: Failed to write audit entry for track %s: %v", entry.TrackID, err)
+			return err
+		}
+	}
+
+	// --- Commit Transaction ---
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	if entry != nil {
+		for _, observer := range s.Observers {
+			observer.CatalogChanged(entry.TrackID)
+		}
+	}
+	return nil
+}
+
+func (s *CatalogService) CreateTrack(ctx context.Context, actorID int, track *models.SpotifyTrack) (*models.SpotifyTrack, error) {
+	log.Printf("Service: User %d creating catalog track %s", actorID, track.TrackID)
+	if err := track.Validate(); err != nil {
+		return nil, err
+	}
+
+	err := s.withTrackTx(ctx, func(tx pgx.Tx) (*models.TrackAuditEntry, error) {
+		if err := s.TrackRepo.CreateTrackInTx(ctx, tx, track); err != nil {
+			if errors.Is(err, repository.ErrTrackExists) {
+				return nil, errors.New("track already exists")
+			}
+			log.Printf("Service: Failed to create track %s: %v", track.TrackID, err)
+			return nil, err
+		}
+		if err := s.ArtistRepo.SyncTrackInTx(ctx, tx, track.TrackID); err != nil {
+			log.Printf("Service: Failed to sync artists of track %s: %v", track.TrackID, err)
+			return nil, err
+		}
+		changes, err := diffTracks(nil, track)
+		if err != nil {
+			return nil, err
+		}
+		return &models.TrackAuditEntry{TrackID: track.TrackID, ActorID: actorID, Action: models.TrackAuditCreate, Changes: changes}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// UpdateTrack replaces every field of a live track with the fields of track.
+func (s *CatalogService) UpdateTrack(ctx context.Context, actorID int, trackID string, track *models.SpotifyTrack) (*models.SpotifyTrack, error) {
+	log.Printf("Service: User %d updating catalog track %s", actorID, trackID)
+	track.TrackID = trackID
+	if err := track.Validate(); err != nil {
+		return nil, err
+	}
+
+	err := s.withTrackTx(ctx, func(tx pgx.Tx) (*models.TrackAuditEntry, error) {
+		current, deleted, err := s.lockTrack(ctx, tx, trackID)
+		if err != nil {
+			return nil, err
+		}
+		if deleted {
+			return nil, errors.New("track is deleted")
+		}
+
+		changes, err := diffTracks(current, track)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) == 0 {
+			return nil, nil
+		}
+		if err := s.TrackRepo.UpdateTrackInTx(ctx, tx, track); err != nil {
+			log.Printf("Service: Failed to update track %s: %v", trackID, err)
+			return nil, err
+		}
+		if err := s.ArtistRepo.SyncTrackInTx(ctx, tx, trackID); err != nil {
+			log.Printf("Service: Failed to sync artists of track %s: %v", trackID, err)
+			return nil, err
+		}
+		return &models.TrackAuditEntry{TrackID: trackID, ActorID: actorID, Action: models.TrackAuditUpdate, Changes: changes}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// DeleteTrack soft-deletes a track. Playlists and interactions keep referencing it, but it
+// no longer shows up in the catalog, in playlists, or as a target for new interactions.
+func (s *CatalogService) DeleteTrack(ctx context.Context, actorID int, trackID string) error {
+	log.Printf("Service: User %d deleting catalog track %s", actorID, trackID)
+	return s.setDeleted(ctx, actorID, trackID, true)
+}
+
+// RestoreTrack undoes DeleteTrack.
+func (s *CatalogService) RestoreTrack(ctx context.Context, actorID int, trackID string) error {
+	log.Printf("Service: User %d restoring catalog track %s", actorID, trackID)
+	return s.setDeleted(ctx, actorID, trackID, false)
+}
+
+func (s *CatalogService) setDeleted(ctx context.Context, actorID int, trackID string, deleted bool) error {
+	return s.withTrackTx(ctx, func(tx pgx.Tx) (*models.TrackAuditEntry, error) {
+		_, isDeleted, err := s.lockTrack(ctx, tx, trackID)
+		if err != nil {
+			return nil, err
+		}
+		if isDeleted == deleted {
+			return nil, nil // already in the requested state
+		}
+		if err := s.TrackRepo.SetTrackDeletedInTx(ctx, tx, trackID, deleted); err != nil {
+			log.Printf("Service: Failed to change deletion of track %s: %v", trackID, err)
+			return nil, err
+		}
+		action := models.TrackAuditRestore
+		if deleted {
+			action = models.TrackAuditDelete
+		}
+		return &models.TrackAuditEntry{TrackID: trackID, ActorID: actorID, Action: action}, nil
+	})
+}
+
+func (s *CatalogService) lockTrack(ctx context.Context, tx pgx.Tx, trackID string) (*models.SpotifyTrack, bool, error) {
+	track, deleted, err := s.TrackRepo.LockTrackInTx(ctx, tx, trackID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, errors.New("track not found")
+		}
+		log.Printf("Service: Failed to load track %s: %v", trackID, err)
+		return nil, false, err
+	}
+	return track, deleted, nil
+}
+
+func (s *CatalogService) ListAuditLog(ctx context.Context, trackID string, limit int, offset int) ([]models.TrackAuditEntry, error) {
+	log.Printf("Service: Listing audit log of track %s with limit %d and offset %d", trackID, limit, offset)
+	return s.TrackRepo.ListAuditEntries(ctx, trackID, limit, offset)
+}
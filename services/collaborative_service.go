@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"log"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+const (
+	// cfNeighbors is how many neighbours are stored per track.
+	cfNeighbors = 50
+	// cfMaxItemsPerUser caps the tracks counted per user; the pairs of a user grow with
+	// the square of their tracks.
+	cfMaxItemsPerUser = 200
+	// cfMinSupport is how many users must like two tracks before they count as neighbours.
+	cfMinSupport = 2
+	// cfShrinkage damps the similarity of pairs with little support: a pair liked by
+	// cfShrinkage users keeps half its similarity.
+	cfShrinkage = 10.0
+	// cfSeedTracks is how many of a user's favourite tracks their recommendations start from.
+	cfSeedTracks = 50
+)
+
+// userPreferences turns interaction counts into a net preference per user and track,
+// weighting each interaction like the taste model does.
+func userPreferences(counts []models.InteractionCount, model models.TasteModel) map[int]map[string]float64 {
+	prefs := make(map[int]map[string]float64)
+	for _, count := range counts {
+		weight, err := tasteWeight(model, count.InteractionType)
+		if err != nil {
+			continue
+		}
+		if prefs[count.UserID] == nil {
+			prefs[count.UserID] = make(map[string]float64)
+		}
+		prefs[count.UserID][count.TrackID] += weight * float64(count.Count)
+	}
+	return prefs
+}
+
+// favourites returns the tracks a user prefers, most preferred first, at most limit.
+func favourites(prefs map[string]float64, limit int) []string {
+	var tracks []string
+	for trackID, pref := range prefs {
+		if pref > 0 {
+			tracks = append(tracks, trackID)
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		if prefs[tracks[i]] != prefs[tracks[j]] {
+			return prefs[tracks[i]] > prefs[tracks[j]]
+		}
+		return tracks[i] < tracks[j]
+	})
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+	return tracks
+}
+
+// buildNeighbors computes an item-item model from user preferences: the cosine
+// similarity of the tracks' positive preference vectors over users, shrunk towards 0
+// for pairs few users share, keeping the cfNeighbors best neighbours of each track.
+func buildNeighbors(prefs map[int]map[string]float64) ([]models.TrackNeighbor, int) {
+	index := make(map[string]int32)
+	var trackIDs []string
+	var norms []float64
+	type pair struct {
+		dot     float64
+		support int
+	}
+	pairs := make(map[uint64]*pair)
+
+	// Users are visited in order so that the sums, and with them the model, do not
+	// depend on map iteration order.
+	users := slices.Sorted(maps.Keys(prefs))
+	for _, userID := range users {
+		userPrefs := prefs[userID]
+		liked := favourites(userPrefs, cfMaxItemsPerUser)
+		items := make([]int32, len(liked))
+		for n, trackID := range liked {
+			i, ok := index[trackID]
+			if !ok {
+				i = int32(len(trackIDs))
+				index[trackID] = i
+				trackIDs = append(trackIDs, trackID)
+				norms = append(norms, 0)
+			}
+			items[n] = i
+			norms[i] += userPrefs[trackID] * userPrefs[trackID]
+		}
+		for a := range items {
+			for b := a + 1; b < len(items); b++ {
+				i, j := min(items[a], items[b]), max(items[a], items[b])
+				key := uint64(i)<<32 | uint64(j)
+				p := pairs[key]
+				if p == nil {
+					p = &pair{}
+					pairs[key] = p
+				}
+				p.dot += userPrefs[liked[a]] * userPrefs[liked[b]]
+				p.support++
+			}
+		}
+	}
+
+	best := make([][]models.TrackNeighbor, len(trackIDs))
+	better := func(a, b models.TrackNeighbor) bool {
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.NeighborID < b.NeighborID
+	}
+	keep := func(i int32, n models.TrackNeighbor) {
+		list := best[i]
+		if len(list) == cfNeighbors && !better(n, list[cfNeighbors-1]) {
+			return
+		}
+		pos := sort.Search(len(list), func(k int) bool { return better(n, list[k]) })
+		list = slices.Insert(list, pos, n)
+		if len(list) > cfNeighbors {
+			list = list[:cfNeighbors]
+		}
+		best[i] = list
+	}
+	for key, p := range pairs {
+		if p.support < cfMinSupport {
+			continue
+		}
+		i, j := int32(key>>32), int32(key&math.MaxUint32)
+		score := p.dot / math.Sqrt(norms[i]*norms[j]) * float64(p.support) / (float64(p.support) + cfShrinkage)
+		keep(i, models.TrackNeighbor{TrackID: trackIDs[i], NeighborID: trackIDs[j], Score: score, Support: p.support})
+		keep(j, models.TrackNeighbor{TrackID: trackIDs[j], NeighborID: trackIDs[i], Score: score, Support: p.support})
+	}
+
+	var neighbors []models.TrackNeighbor
+	for _, list := range best {
+		neighbors = append(neighbors, list...)
+	}
+	return neighbors, len(trackIDs)
+}
+
+// CollaborativeService maintains an item-item collaborative filtering model built from
+// the interactions table: for every track, the tracks most often liked by the same users.
+type CollaborativeService struct {
+	Repo repository.TrackNeighborRepository
+}
+
+func NewCollaborativeService(repo repository.TrackNeighborRepository) *CollaborativeService {
+	return &CollaborativeService{Repo: repo}
+}
+
+// Rebuild recomputes the model from all interactions and replaces the stored neighbours.
+func (s *CollaborativeService) Rebuild(ctx context.Context) (*models.NeighborBuildResult, error) {
+	start := time.Now()
+	counts, err := s.Repo.ListInteractionCounts(ctx)
+	if err != nil {
+		log.Printf("Service: Error loading interactions for collaborative filtering: %v", err)
+		return nil, err
+	}
+	prefs := userPreferences(counts, defaultTasteModel)
+	neighbors, tracks := buildNeighbors(prefs)
+	if err := s.Repo.ReplaceNeighbors(ctx, neighbors); err != nil {
+		log.Printf("Service: Error storing track neighbors: %v", err)
+		return nil, err
+	}
+
+	result := &models.NeighborBuildResult{Users: len(prefs), Tracks: tracks, Neighbors: len(neighbors), DurationMs: time.Since(start).Milliseconds()}
+	log.Printf("Service: Track neighbors rebuilt from %d users and %d tracks: %d neighbors in %d ms", result.Users, result.Tracks, result.Neighbors, result.DurationMs)
+	return result, nil
+}
+
+// Run rebuilds the model every interval until ctx is done. A failed rebuild keeps the
+// previous model.
+func (s *CollaborativeService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Rebuild(ctx); err != nil {
+			log.Printf("Service: Rebuilding track neighbors failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// UserPreferences returns the net preference of a user for every track they interacted with.
+func (s *CollaborativeService) UserPreferences(ctx context.Context, userID int) (map[string]float64, error) {
+	counts, err := s.Repo.GetUserInteractionCounts(ctx, userID)
+	if err != nil {
+		log.Printf("Service: Error loading interactions of user %d: %v", userID, err)
+		return nil, err
+	}
+	return userPreferences(counts, defaultTasteModel)[userID], nil
+}
+
+// scoredTrack is a candidate track with a score from 0 to 1.
+type scoredTrack struct {
+	trackID string
+	score   float64
+}
+
+// recommend scores the neighbours of a user's favourite tracks with scoreNeighbors.
+func (s *CollaborativeService) recommend(ctx context.Context, prefs map[string]float64, limit int) ([]scoredTrack, error) {
+	seeds := favourites(prefs, cfSeedTracks)
+	neighbors, err := s.Repo.GetNeighbors(ctx, seeds)
+	if err != nil {
+		log.Printf("Service: Error loading neighbors: %v", err)
+		return nil, err
+	}
+	return scoreNeighbors(prefs, seeds, neighbors, limit), nil
+}
+
+// scoreNeighbors scores the neighbours of seeds, a user's favourite tracks: each
+// neighbour scores the preference-weighted mean of its similarity to the favourites.
+// Tracks in prefs are never recommended. The best limit are returned, best first.
+func scoreNeighbors(prefs map[string]float64, seeds []string, neighbors []models.TrackNeighbor, limit int) []scoredTrack {
+	totalPref := 0.0
+	for _, seed := range seeds {
+		totalPref += prefs[seed]
+	}
+	scores := make(map[string]float64)
+	for _, n := range neighbors {
+		if _, seen := prefs[n.NeighborID]; seen {
+			continue
+		}
+		scores[n.NeighborID] += prefs[n.TrackID] * n.Score / totalPref
+	}
+
+	candidates := make([]scoredTrack, 0, len(scores))
+	for trackID, score := range scores {
+		candidates = append(candidates, scoredTrack{trackID: trackID, score: score})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].trackID < candidates[j].trackID
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
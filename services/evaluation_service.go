@@ -0,0 +1,372 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+// defaultEvaluationConfig evaluates every strategy with the service's taste model.
+var defaultEvaluationConfig = models.EvaluationConfig{
+	TrainFraction: 0.8,
+	K:             20,
+	Variants: []models.EvaluationVariant{
+		{Name: models.StrategyPopular, Strategy: models.StrategyPopular},
+		{Name: models.StrategyCF, Strategy: models.StrategyCF},
+		{Name: models.StrategyContent, Strategy: models.StrategyContent},
+		{Name: models.StrategyHybrid, Strategy: models.StrategyHybrid},
+	},
+}
+
+// LoadEvaluationConfig reads an evaluation config from a JSON file. An empty path gives
+// the default config.
+func LoadEvaluationConfig(path string) (models.EvaluationConfig, error) {
+	if path == "" {
+		return defaultEvaluationConfig, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return models.EvaluationConfig{}, fmt.Errorf("failed to read evaluation config: %w", err)
+	}
+	var config models.EvaluationConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return models.EvaluationConfig{}, fmt.Errorf("failed to parse evaluation config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return models.EvaluationConfig{}, err
+	}
+	return config, nil
+}
+
+// EvaluationService measures recommendation quality offline: models are trained on the
+// interactions before a point in time and judged on the tracks users went on to like
+// after it.
+type EvaluationService struct {
+	InteractionRepo repository.InteractionRepository
+	TrackRepo       repository.SpotifyTrackRepository
+}
+
+func NewEvaluationService(interactionRepo repository.InteractionRepository, trackRepo repository.SpotifyTrackRepository) *EvaluationService {
+	return &EvaluationService{InteractionRepo: interactionRepo, TrackRepo: trackRepo}
+}
+
+// Evaluate runs every variant of config against the interactions and catalog in the
+// database.
+func (s *EvaluationService) Evaluate(ctx context.Context, config models.EvaluationConfig) (*models.EvaluationReport, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	interactions, err := s.InteractionRepo.ListAllInteractions(ctx)
+	if err != nil {
+		log.Printf("Service: Error loading interactions for evaluation: %v", err)
+		return nil, err
+	}
+	tracks, err := s.TrackRepo.ListAll(ctx)
+	if err != nil {
+		log.Printf("Service: Error loading catalog for evaluation: %v", err)
+		return nil, err
+	}
+	log.Printf("Service: Evaluating %d variants on %d interactions and %d tracks", len(config.Variants), len(interactions), len(tracks))
+	return evaluate(config, interactions, tracks)
+}
+
+// trainedModel is what a taste model learns from the training interactions.
+type trainedModel struct {
+	prefs     map[int]map[string]float64
+	tastes    map[int][]float64
+	neighbors map[string][]models.TrackNeighbor
+}
+
+func evaluate(config models.EvaluationConfig, interactions []models.Interaction, tracks []models.SpotifyTrack) (*models.EvaluationReport, error) {
+	if len(interactions) == 0 {
+		return nil, errors.New("no interactions to evaluate")
+	}
+	interactions = slices.Clone(interactions)
+	sort.SliceStable(interactions, func(i, j int) bool {
+		if !interactions[i].CreatedAt.Equal(interactions[j].CreatedAt) {
+			return interactions[i].CreatedAt.Before(interactions[j].CreatedAt)
+		}
+		return interactions[i].ID < interactions[j].ID
+	})
+
+	var splitAt time.Time
+	if config.SplitAt != nil {
+		splitAt = *config.SplitAt
+	} else {
+		splitAt = interactions[int(config.TrainFraction*float64(len(interactions)))].CreatedAt
+	}
+	split := sort.Search(len(interactions), func(i int) bool { return !interactions[i].CreatedAt.Before(splitAt) })
+	train, test := interactions[:split], interactions[split:]
+	if len(train) == 0 || len(test) == 0 {
+		return nil, errors.New("the split leaves no interactions to train or test on")
+	}
+
+	// The variants' taste models are recorded in the report, so that it can be rerun.
+	config.Variants = slices.Clone(config.Variants)
+	for i := range config.Variants {
+		if config.Variants[i].TasteModel == nil {
+			model := DefaultTasteModel()
+			config.Variants[i].TasteModel = &model
+		}
+	}
+
+	idx := buildSimilarityIndex(tracks)
+	vectors := make(map[string]featureVector, len(idx.entries))
+	trackByID := make(map[string]*models.SpotifyTrack, len(tracks))
+	for i := range idx.entries {
+		vectors[idx.entries[i].trackID] = idx.entries[i].vector
+		trackByID[tracks[i].TrackID] = &tracks[i]
+	}
+
+	// What users went on to like is judged the same way for every variant: a positive net
+	// preference after the split, under the service's weights, for a track they had not
+	// interacted with before it.
+	trainPrefs := userPreferences(countInteractions(train), defaultTasteModel)
+	testPrefs := userPreferences(countInteractions(test), defaultTasteModel)
+	relevant := make(map[int]map[string]bool)
+	for userID, prefs := range testPrefs {
+		if len(trainPrefs[userID]) == 0 {
+			continue
+		}
+		for trackID, pref := range prefs {
+			if _, seen := trainPrefs[userID][trackID]; pref > 0 && !seen {
+				if relevant[userID] == nil {
+					relevant[userID] = make(map[string]bool)
+				}
+				relevant[userID][trackID] = true
+			}
+		}
+	}
+	users := make([]int, 0, len(relevant))
+	for userID := range relevant {
+		users = append(users, userID)
+	}
+	slices.Sort(users)
+
+	report := &models.EvaluationReport{
+		Config:            config,
+		SplitAt:           splitAt.UTC(),
+		TrainInteractions: len(train),
+		TestInteractions:  len(test),
+		CatalogTracks:     len(tracks),
+	}
+	trained := make(map[string]*trainedModel)
+	for _, variant := range config.Variants {
+		key, err := json.Marshal(variant.TasteModel)
+		if err != nil {
+			return nil, err
+		}
+		model, ok := trained[string(key)]
+		if !ok {
+			model = trainTasteModel(*variant.TasteModel, train, trackByID)
+			trained[string(key)] = model
+		}
+
+		metrics := models.EvaluationMetrics{Name: variant.Name, Strategy: variant.Strategy, Users: len(users)}
+		popular := popularTracks(model.prefs)
+		recommended := make(map[string]bool)
+		diverseLists := 0
+		for _, userID := range users {
+			list := recommendFromModel(variant.Strategy, model, idx, popular, userID, config.K)
+			for _, trackID := range list {
+				recommended[trackID] = true
+			}
+			precision, recall, ndcg := rankingMetrics(list, relevant[userID], config.K)
+			metrics.Precision += precision
+			metrics.Recall += recall
+			metrics.NDCG += ndcg
+			if diversity, ok := listDiversity(list, vectors); ok {
+				metrics.Diversity += diversity
+				diverseLists++
+			}
+		}
+		if len(users) > 0 {
+			metrics.Precision /= float64(len(users))
+			metrics.Recall /= float64(len(users))
+			metrics.NDCG /= float64(len(users))
+		}
+		if diverseLists > 0 {
+			metrics.Diversity /= float64(diverseLists)
+		}
+		if len(tracks) > 0 {
+			metrics.Coverage = float64(len(recommended)) / float64(len(tracks))
+		}
+		log.Printf("Service: Evaluated variant '%s' on %d users: precision@%d %.4f, recall@%d %.4f, ndcg@%d %.4f", variant.Name, len(users), config.K, metrics.Precision, config.K, metrics.Recall, config.K, metrics.NDCG)
+		report.Variants = append(report.Variants, metrics)
+	}
+	return report, nil
+}
+
+// countInteractions counts interactions per user, track and type.
+func countInteractions(interactions []models.Interaction) []models.InteractionCount {
+	type key struct {
+		userID          int
+		trackID         string
+		interactionType string
+	}
+	index := make(map[key]int)
+	var counts []models.InteractionCount
+	for _, interaction := range interactions {
+		k := key{interaction.UserID, interaction.TrackID, interaction.Type}
+		i, ok := index[k]
+		if !ok {
+			i = len(counts)
+			index[k] = i
+			counts = append(counts, models.InteractionCount{UserID: interaction.UserID, TrackID: interaction.TrackID, InteractionType: interaction.Type})
+		}
+		counts[i].Count++
+	}
+	return counts
+}
+
+// trainTasteModel replays the training interactions, oldest first, through a taste model
+// the way InteractionService does, and builds the collaborative model from them.
+func trainTasteModel(model models.TasteModel, train []models.Interaction, trackByID map[string]*models.SpotifyTrack) *trainedModel {
+	trained := &trainedModel{
+		prefs:     userPreferences(countInteractions(train), model),
+		tastes:    make(map[int][]float64),
+		neighbors: make(map[string][]models.TrackNeighbor),
+	}
+	for _, interaction := range train {
+		track, ok := trackByID[interaction.TrackID]
+		if !ok {
+			continue
+		}
+		weight, err := tasteWeight(model, interaction.Type)
+		if err != nil {
+			continue
+		}
+		taste, ok := trained.tastes[interaction.UserID]
+		if !ok {
+			taste = make([]float64, featureCount)
+			trained.tastes[interaction.UserID] = taste
+		}
+		updateTaste(model, taste, track, weight)
+	}
+	neighbors, _ := buildNeighbors(trained.prefs)
+	for _, n := range neighbors {
+		trained.neighbors[n.TrackID] = append(trained.neighbors[n.TrackID], n)
+	}
+	return trained
+}
+
+// popularTracks returns the tracks with a positive preference from the most users, most
+// popular first.
+func popularTracks(prefs map[int]map[string]float64) []string {
+	fans := make(map[string]int)
+	for _, userPrefs := range prefs {
+		for trackID, pref := range userPrefs {
+			if pref > 0 {
+				fans[trackID]++
+			}
+		}
+	}
+	tracks := make([]string, 0, len(fans))
+	for trackID := range fans {
+		tracks = append(tracks, trackID)
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		if fans[tracks[i]] != fans[tracks[j]] {
+			return fans[tracks[i]] > fans[tracks[j]]
+		}
+		return tracks[i] < tracks[j]
+	})
+	return tracks
+}
+
+// recommendFromModel is RecommendationService.Recommend on a trained model, plus the
+// popularity baseline.
+func recommendFromModel(strategy string, model *trainedModel, idx *similarityIndex, popular []string, userID int, k int) []string {
+	prefs := model.prefs[userID]
+	if strategy == models.StrategyPopular {
+		var list []string
+		for _, trackID := range popular {
+			if len(list) == k {
+				break
+			}
+			if _, seen := prefs[trackID]; !seen {
+				list = append(list, trackID)
+			}
+		}
+		return list
+	}
+
+	candidates := k
+	if strategy == models.StrategyHybrid {
+		candidates = 2 * k
+	}
+	var cf, content []scoredTrack
+	if strategy != models.StrategyContent {
+		seeds := favourites(prefs, cfSeedTracks)
+		sortedSeeds := slices.Sorted(slices.Values(seeds))
+		var neighbors []models.TrackNeighbor
+		for _, seed := range sortedSeeds {
+			neighbors = append(neighbors, model.neighbors[seed]...)
+		}
+		cf = scoreNeighbors(prefs, seeds, neighbors, candidates)
+	}
+	if strategy != models.StrategyCF {
+		interacted := make([]string, 0, len(prefs))
+		for trackID := range prefs {
+			interacted = append(interacted, trackID)
+		}
+		content, _ = idx.rankTaste(model.tastes[userID], models.TasteMatchOptions{Limit: candidates, ExcludeTrackIDs: interacted})
+	}
+	list, _ := blendRecommendations(strategy, cf, content, k)
+	return list
+}
+
+// rankingMetrics returns precision, recall and NDCG at k of a recommendation list, with
+// binary relevance.
+func rankingMetrics(list []string, relevant map[string]bool, k int) (precision, recall, ndcg float64) {
+	hits := 0
+	var dcg float64
+	for rank, trackID := range list {
+		if rank == k {
+			break
+		}
+		if relevant[trackID] {
+			hits++
+			dcg += 1 / math.Log2(float64(rank+2))
+		}
+	}
+	var idcg float64
+	for rank := 0; rank < min(len(relevant), k); rank++ {
+		idcg += 1 / math.Log2(float64(rank+2))
+	}
+	precision = float64(hits) / float64(k)
+	if len(relevant) > 0 {
+		recall = float64(hits) / float64(len(relevant))
+		ndcg = dcg / idcg
+	}
+	return precision, recall, ndcg
+}
+
+// listDiversity returns the mean distance between two tracks of a list in normalized
+// feature space, scaled to 0..1. It reports false for lists of fewer than two tracks.
+func listDiversity(list []string, vectors map[string]featureVector) (float64, bool) {
+	maxDistance := math.Sqrt(featureCount)
+	var total float64
+	pairs := 0
+	for i := range list {
+		for j := i + 1; j < len(list); j++ {
+			total += math.Sqrt(squaredDistance(vectors[list[i]], vectors[list[j]])) / maxDistance
+			pairs++
+		}
+	}
+	if pairs == 0 {
+		return 0, false
+	}
+	return total / float64(pairs), true
+}
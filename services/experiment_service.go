@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+// LoadExperiments reads experiment definitions from a JSON file. An empty path means no
+// experiments are running. Two experiments may not vary the same thing, since a user
+// can only get one strategy and one taste model.
+func LoadExperiments(path string) ([]models.Experiment, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read experiments: %w", err)
+	}
+	var experiments []models.Experiment
+	if err := json.Unmarshal(data, &experiments); err != nil {
+		return nil, fmt.Errorf("failed to parse experiments: %w", err)
+	}
+	seen := make(map[string]bool)
+	variedBy := make(map[string]string)
+	for i := range experiments {
+		if err := experiments[i].Validate(); err != nil {
+			return nil, err
+		}
+		if seen[experiments[i].Name] {
+			return nil, fmt.Errorf("experiment %s is defined twice", experiments[i].Name)
+		}
+		seen[experiments[i].Name] = true
+		for _, varied := range experiments[i].Varies() {
+			if other, ok := variedBy[varied]; ok {
+				return nil, fmt.Errorf("experiments %s and %s both vary %s", other, experiments[i].Name, varied)
+			}
+			variedBy[varied] = experiments[i].Name
+		}
+	}
+	return experiments, nil
+}
+
+// assignVariant buckets a user into a variant of an experiment. The FNV-1a hash of the
+// experiment's name and the user's ID picks a point in the variants' total weight, so a
+// user always gets the same variant while the experiment is unchanged, and independent
+// experiments split users independently.
+func assignVariant(experiment *models.Experiment, userID int) *models.ExperimentVariant {
+	total := 0
+	for _, v := range experiment.Variants {
+		total += v.Weight
+	}
+	h := fnv.New32a()
+	h.Write([]byte(experiment.Name + "\x00" + strconv.Itoa(userID)))
+	point := int(h.Sum32() % uint32(total))
+	for i := range experiment.Variants {
+		if point < experiment.Variants[i].Weight {
+			return &experiment.Variants[i]
+		}
+		point -= experiment.Variants[i].Weight
+	}
+	return &experiment.Variants[len(experiment.Variants)-1]
+}
+
+// ExperimentService runs A/B experiments: it tells other services which variant applies
+// to a user and logs the user's exposure the first time a variant is applied.
+type ExperimentService struct {
+	Repo        repository.ExperimentRepository
+	Experiments []models.Experiment
+	exposed     sync.Map // experiment name and user ID already logged by this process
+}
+
+func NewExperimentService(repo repository.ExperimentRepository, experiments []models.Experiment) *ExperimentService {
+	return &ExperimentService{Repo: repo, Experiments: experiments}
+}
+
+func (s *ExperimentService) ListExperiments() []models.Experiment {
+	return s.Experiments
+}
+
+func (s *ExperimentService) findExperiment(name string) (*models.Experiment, error) {
+	for i := range s.Experiments {
+		if s.Experiments[i].Name == name {
+			return &s.Experiments[i], nil
+		}
+	}
+	return nil, errors.New("experiment not found")
+}
+
+// applyVariant returns the user's variant of the experiment that sets what applies
+// reports as set, and logs the exposure. LoadExperiments makes sure that at most one
+// experiment does. It returns nil if no experiment decides it for the user.
+func (s *ExperimentService) applyVariant(ctx context.Context, userID int, applies func(*models.ExperimentVariant) bool) *models.ExperimentVariant {
+	for i := range s.Experiments {
+		experiment := &s.Experiments[i]
+		varies := false
+		for j := range experiment.Variants {
+			varies = varies || applies(&experiment.Variants[j])
+		}
+		if !varies {
+			continue
+		}
+		variant := assignVariant(experiment, userID)
+		s.expose(ctx, experiment.Name, variant.Name, userID)
+		if !applies(variant) {
+			return nil // a control group
+		}
+		return variant
+	}
+	return nil
+}
+
+// expose logs a user's exposure to an experiment once per process; the database keeps
+// the first exposure across processes. Failures are logged and otherwise ignored, as
+// they must not break the request that caused the exposure.
+func (s *ExperimentService) expose(ctx context.Context, experiment, variant string, userID int) {
+	key := experiment + "\x00" + strconv.Itoa(userID)
+	if _, logged := s.exposed.Load(key); logged {
+		return
+	}
+	exposure := &models.ExperimentExposure{Experiment: experiment, Variant: variant, UserID: userID}
+	if err := s.Repo.LogExposure(ctx, exposure); err != nil {
+		log.Printf("Service: Error logging exposure of user %d to experiment '%s': %v", userID, experiment, err)
+		return
+	}
+	s.exposed.Store(key, struct{}{})
+}
+
+// TasteModel returns the taste model that updates the user's avg_interest: their
+// variant's, if an experiment varies taste models, or else the default one.
+func (s *ExperimentService) TasteModel(ctx context.Context, userID int) models.TasteModel {
+	variant := s.applyVariant(ctx, userID, func(v *models.ExperimentVariant) bool { return v.TasteModel != nil })
+	if variant == nil {
+		return defaultTasteModel
+	}
+	return *variant.TasteModel
+}
+
+// RecommendationStrategy returns the user's variant's recommendation strategy, if an
+// experiment varies strategies, or else fallback.
+func (s *ExperimentService) RecommendationStrategy(ctx context.Context, userID int, fallback string) string {
+	variant := s.applyVariant(ctx, userID, func(v *models.ExperimentVariant) bool { return v.RecommendationStrategy != "" })
+	if variant == nil {
+		return fallback
+	}
+	return variant.RecommendationStrategy
+}
+
+// Summary compares the variants of an experiment by the interactions of their users
+// since exposure. Variants nobody was exposed to yet are included with zero counts.
+func (s *ExperimentService) Summary(ctx context.Context, name string) (*models.ExperimentSummary, error) {
+	experiment, err := s.findExperiment(name)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Service: Summarizing experiment '%s'", name)
+	counts, err := s.Repo.GetVariantSummaries(ctx, name)
+	if err != nil {
+		log.Printf("Service: Error summarizing experiment '%s': %v", name, err)
+		return nil, err
+	}
+
+	summary := &models.ExperimentSummary{Experiment: name, Variants: make([]models.VariantSummary, len(experiment.Variants))}
+	for i, variant := range experiment.Variants {
+		summary.Variants[i].Variant = variant.Name
+		for _, c := range counts {
+			if c.Variant == variant.Name {
+				summary.Variants[i] = c
+			}
+		}
+		v := &summary.Variants[i]
+		if heard := v.Plays + v.Skips; heard > 0 {
+			v.LikeRate = float64(v.Likes) / float64(heard)
+			v.SkipRate = float64(v.Skips) / float64(heard)
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+// defaultGenreTaxonomy groups the genres of the Spotify tracks dataset into families.
+// It can be replaced at startup with LoadGenreTaxonomy.
+var defaultGenreTaxonomy = models.GenreTaxonomy{
+	"house":             "electronic",
+	"deep-house":        "house",
+	"chicago-house":     "house",
+	"progressive-house": "house",
+	"techno":            "electronic",
+	"detroit-techno":    "techno",
+	"minimal-techno":    "techno",
+	"trance":            "electronic",
+	"edm":               "electronic",
+	"electro":           "electronic",
+	"dubstep":           "electronic",
+	"drum-and-bass":     "electronic",
+	"breakbeat":         "electronic",
+	"idm":               "electronic",
+	"hardstyle":         "electronic",
+	"ambient":           "electronic",
+	"trip-hop":          "electronic",
+	"club":              "dance",
+	"dance":             "electronic",
+	"alt-rock":          "rock",
+	"alternative":       "rock",
+	"hard-rock":         "rock",
+	"psych-rock":        "rock",
+	"grunge":            "rock",
+	"j-rock":            "rock",
+	"rock-n-roll":       "rock",
+	"rockabilly":        "rock-n-roll",
+	"punk":              "rock",
+	"punk-rock":         "punk",
+	"emo":               "punk",
+	"hardcore":          "punk",
+	"metal":             "rock",
+	"heavy-metal":       "metal",
+	"black-metal":       "metal",
+	"death-metal":       "metal",
+	"grindcore":         "death-metal",
+	"metalcore":         "metal",
+	"indie-pop":         "pop",
+	"power-pop":         "pop",
+	"synth-pop":         "pop",
+	"k-pop":             "pop",
+	"j-pop":             "pop",
+	"j-idol":            "j-pop",
+	"j-dance":           "j-pop",
+	"cantopop":          "pop",
+	"mandopop":          "pop",
+	"pop-film":          "pop",
+	"r-n-b":             "soul",
+	"gospel":            "soul",
+	"disco":             "funk",
+	"latino":            "latin",
+	"reggaeton":         "latin",
+	"salsa":             "latin",
+	"tango":             "latin",
+	"brazil":            "latin",
+	"samba":             "brazil",
+	"pagode":            "samba",
+	"mpb":               "brazil",
+	"forro":             "brazil",
+	"sertanejo":         "brazil",
+	"dancehall":         "reggae",
+	"dub":               "reggae",
+	"ska":               "reggae",
+	"bluegrass":         "country",
+	"honky-tonk":        "country",
+	"singer-songwriter": "folk",
+	"songwriter":        "folk",
+	"opera":             "classical",
+	"piano":             "classical",
+}
+
+// LoadGenreTaxonomy reads a genre taxonomy from a JSON object mapping each genre to its
+// parent. An empty path returns the built-in taxonomy.
+func LoadGenreTaxonomy(path string) (models.GenreTaxonomy, error) {
+	if path == "" {
+		return defaultGenreTaxonomy, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genre taxonomy: %w", err)
+	}
+	var taxonomy models.GenreTaxonomy
+	if err := json.Unmarshal(data, &taxonomy); err != nil {
+		return nil, fmt.Errorf("failed to parse genre taxonomy: %w", err)
+	}
+	if err := taxonomy.Validate(); err != nil {
+		return nil, err
+	}
+	return taxonomy, nil
+}
+
+// maxGenreRun is how many recommendations of the same genre family may follow each
+// other before DiversifyByGenre pulls a lower ranked track of another family forward.
+const maxGenreRun = 2
+
+type GenreService struct {
+	catalogRefresher
+	TrackRepo repository.SpotifyTrackRepository
+	Taxonomy  models.GenreTaxonomy
+	counts    atomic.Pointer[[]models.Genre] // track count per genre, as of the last refresh
+}
+
+func NewGenreService(trackRepo repository.SpotifyTrackRepository, taxonomy models.GenreTaxonomy) *GenreService {
+	return &GenreService{TrackRepo: trackRepo, Taxonomy: taxonomy}
+}
+
+// Refresh reloads the track count of every genre from the catalog.
+func (s *GenreService) Refresh(ctx context.Context) error {
+	counts, err := s.TrackRepo.ListGenres(ctx)
+	if err != nil {
+		log.Printf("Service: Error listing genres: %v", err)
+		return err
+	}
+	s.counts.Store(&counts)
+	log.Printf("Service: Genre counts refreshed with %d genres", len(counts))
+	return nil
+}
+
+// Run loads the genre counts and then reloads them whenever the catalog changed, until
+// ctx is done.
+func (s *GenreService) Run(ctx context.Context) {
+	s.run(ctx, "genre counts", s.Refresh)
+}
+
+// genreCounts returns the track count per genre as of the last refresh, or loads it if
+// there was none yet.
+func (s *GenreService) genreCounts(ctx context.Context) ([]models.Genre, error) {
+	if counts := s.counts.Load(); counts != nil {
+		return *counts, nil
+	}
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return *s.counts.Load(), nil
+}
+
+// ListGenres returns every genre that has tracks, directly or through its subgenres,
+// sorted by name.
+func (s *GenreService) ListGenres(ctx context.Context) ([]models.Genre, error) {
+	log.Println("Service: Listing genres")
+	counts, err := s.genreCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*models.Genre)
+	node := func(name string) *models.Genre {
+		genre, ok := byName[name]
+		if !ok {
+			genre = &models.Genre{Name: name, Parent: s.Taxonomy[name]}
+			byName[name] = genre
+		}
+		return genre
+	}
+	for _, count := range counts {
+		node(count.Name).TrackCount = count.TrackCount
+		// Roll the count up to every ancestor, creating grouping genres on the way.
+		for name, ok := count.Name, true; ok; name, ok = s.Taxonomy[name] {
+			node(name).TotalTrackCount += count.TrackCount
+		}
+	}
+
+	genres := make([]models.Genre, 0, len(byName))
+	for _, genre := range byName {
+		for _, child := range s.Taxonomy.Children(genre.Name) {
+			if _, ok := byName[child]; ok {
+				genre.Children = append(genre.Children, child)
+			}
+		}
+		genres = append(genres, *genre)
+	}
+	sort.Slice(genres, func(i, j int) bool { return genres[i].Name < genres[j].Name })
+	return genres, nil
+}
+
+// ListTracks lists the tracks of genre and, if includeSubgenres is set, of all its
+// subgenres. Any genres already set on filter are replaced.
+func (s *GenreService) ListTracks(ctx context.Context, genre string, includeSubgenres bool, filter models.TrackFilter, page models.PageRequest, sortBy string, order string) (*models.Page[models.SpotifyTrack], error) {
+	log.Printf("Service: Listing tracks of genre '%s' (subgenres: %t) with limit %d, offset %d, and sort by %s", genre, includeSubgenres, page.Limit, page.Offset, sortBy)
+
+	known, err := s.isKnownGenre(ctx, genre)
+	if err != nil {
+		return nil, err
+	}
+	if !known {
+		return nil, errors.New("genre not found")
+	}
+
+	filter.Genres = []string{genre}
+	if includeSubgenres {
+		filter.Genres = s.Taxonomy.Descendants(genre)
+	}
+
+	tracks, err := s.TrackRepo.List(ctx, filter, page, sortBy, order)
+	if err != nil {
+		log.Printf("Service: Error listing tracks of genre '%s': %v", genre, err)
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// ExpandGenres returns genres together with all their subgenres, without duplicates.
+func (s *GenreService) ExpandGenres(ctx context.Context, genres []string) ([]string, error) {
+	var expanded []string
+	seen := make(map[string]bool)
+	for _, genre := range genres {
+		known, err := s.isKnownGenre(ctx, genre)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			return nil, errors.New("genre not found")
+		}
+		for _, g := range s.Taxonomy.Descendants(genre) {
+			if !seen[g] {
+				seen[g] = true
+				expanded = append(expanded, g)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// isKnownGenre reports whether genre is part of the taxonomy or tagged on a track, as of
+// the last refresh of the genre counts.
+func (s *GenreService) isKnownGenre(ctx context.Context, genre string) (bool, error) {
+	if _, ok := s.Taxonomy[genre]; ok {
+		return true, nil
+	}
+	if len(s.Taxonomy.Children(genre)) > 0 {
+		return true, nil
+	}
+	counts, err := s.genreCounts(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, count := range counts {
+		if count.Name == genre {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DiversifyByGenre reorders ranked track IDs so that no more than maxGenreRun tracks of
+// the same top-level genre follow each other, as long as other families are left. The
+// order within each family is kept and no track is dropped. genres maps track IDs to
+// their genre; tracks without a genre form their own family.
+func (s *GenreService) DiversifyByGenre(trackIDs []string, genres map[string]string) []string {
+	remaining := append([]string(nil), trackIDs...)
+	diversified := make([]string, 0, len(trackIDs))
+	lastFamily, run := "", 0
+
+	for len(remaining) > 0 {
+		pick := 0
+		if run >= maxGenreRun {
+			for i, trackID := range remaining {
+				if s.Taxonomy.Root(genres[trackID]) != lastFamily {
+					pick = i
+					break
+				}
+			}
+		}
+
+		trackID := remaining[pick]
+		remaining = append(remaining[:pick], remaining[pick+1:]...)
+		diversified = append(diversified, trackID)
+
+		if family := s.Taxonomy.Root(genres[trackID]); family == lastFamily {
+			run++
+		} else {
+			lastFamily, run = family, 1
+		}
+	}
+	return diversified
+}
@@ -4,94 +4,227 @@ import (
 	"context"
 	"errors"
 	"log"
-	"strconv"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kiasoh/basic-spotify-backend/events"
 	"github.com/kiasoh/basic-spotify-backend/models"
 	"github.com/kiasoh/basic-spotify-backend/repository"
-	"github.com/segmentio/kafka-go"
 )
 
+// TrackStateObserver is told about every committed change of a user's like/dislike
+// state for a track, e.g. to drop a cached copy of it.
+type TrackStateObserver interface {
+	TrackStateChanged(userID int, trackID string)
+}
+
 type InteractionService struct {
+	DB          *pgxpool.Pool
 	Repo        repository.InteractionRepository
 	TrackRepo   repository.SpotifyTrackRepository
 	UserRepo    repository.UserRepository
-	KafkaWriter *kafka.Writer
+	Publisher   events.EventPublisher
+	Experiments *ExperimentService
+	Observers   []TrackStateObserver
 }
 
-func NewInteractionService(repo repository.InteractionRepository, kafkaWriter *kafka.Writer, trackRepo repository.SpotifyTrackRepository, userRepo repository.UserRepository) *InteractionService {
+func NewInteractionService(db *pgxpool.Pool, repo repository.InteractionRepository, publisher events.EventPublisher, trackRepo repository.SpotifyTrackRepository, userRepo repository.UserRepository, experiments *ExperimentService, observers ...TrackStateObserver) *InteractionService {
 	return &InteractionService{
+		DB:          db,
 		Repo:        repo,
 		TrackRepo:   trackRepo,
 		UserRepo:    userRepo,
-		KafkaWriter: kafkaWriter,
+		Publisher:   publisher,
+		Experiments: experiments,
+		Observers:   observers,
 	}
 }
 
-func (s *InteractionService) convertTrackToVector(track *models.SpotifyTrack, multiplier float64) ([]float64, error) {
-	return []float64{track.Danceability * multiplier, track.Energy * multiplier, track.Loudness * multiplier, track.Speechiness * multiplier, track.Acousticness * multiplier, track.Instrumentalness * multiplier, track.Liveness * multiplier, track.Valence * multiplier, track.Tempo * multiplier}, nil
+// interactionWeight returns how strongly an interaction type pulls the user's
+// interest vector towards (positive) or away from (negative) a track, in the default
+// taste model.
+func interactionWeight(interactionType string) (float64, error) {
+	return tasteWeight(defaultTasteModel, interactionType)
 }
 
-const alpha = 0.45
+// TasteModel returns the taste model the user's interactions update avg_interest with,
+// which an experiment may vary per user.
+func (s *InteractionService) TasteModel(ctx context.Context, userID int) models.TasteModel {
+	if s.Experiments == nil {
+		return defaultTasteModel
+	}
+	return s.Experiments.TasteModel(ctx, userID)
+}
 
+// HandleInteraction moves the user's interest vector as an interaction of the given
+// type would, without recording the interaction.
 func (s *InteractionService) HandleInteraction(ctx context.Context, userID int, trackID string, interactionType string) error {
-	var weight float64
+	model := s.TasteModel(ctx, userID)
+	weight, err := tasteWeight(model, interactionType)
+	if err != nil {
+		return err
+	}
+	track, err := s.getTrack(ctx, trackID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := s.updateTasteInTx(ctx, tx, model, userID, track, weight); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// getTrack loads the track an interaction is about.
+func (s *InteractionService) getTrack(ctx context.Context, trackID string) (*models.SpotifyTrack, error) {
+	track, err := s.TrackRepo.GetByTrackID(ctx, trackID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.New("track not found")
+	}
+	if err != nil {
+		log.Printf("Service: Error getting track %s for interaction: %v", trackID, err)
+		return nil, err
+	}
+	return track, nil
+}
+
+// updateTasteInTx moves the user's interest vector towards the track by weight.
+func (s *InteractionService) updateTasteInTx(ctx context.Context, tx pgx.Tx, model models.TasteModel, userID int, track *models.SpotifyTrack, weight float64) error {
+	user, err := s.UserRepo.LockUserInTx(ctx, tx, userID)
+	if err != nil {
+		log.Printf("Service: Error getting user %d to update their interest: %v", userID, err)
+		return err
+	}
+	updateTaste(model, user.AvgInterest, track, weight)
+	if err := s.UserRepo.UpdateAvgInterestInTx(ctx, tx, userID, user.AvgInterest); err != nil {
+		log.Printf("Service: Error updating interest of user %d: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+// stateTransition returns the track state an interaction type moves to and the states
+// it may move from (any, when empty). ok is false for types that do not touch the state.
+func stateTransition(interactionType string) (to models.TrackInteractionState, allowedFrom []models.TrackInteractionState, ok bool) {
 	switch interactionType {
 	case "like":
-		weight = 3.0
+		return models.TrackStateLiked, nil, true
 	case "unlike":
-		weight = -2.5
+		return models.TrackStateNeutral, []models.TrackInteractionState{models.TrackStateLiked}, true
 	case "dislike":
-		weight = -4.0
+		return models.TrackStateDisliked, nil, true
 	case "undislike":
-		weight = 4.5
-	case "skip":
-		weight = -1.0
-	case "play":
-		weight = 1.0
-	case "add_to_playlist":
-		weight = 5.0
-	case "remove_from_playlist":
-		weight = -3.0
+		return models.TrackStateNeutral, []models.TrackInteractionState{models.TrackStateDisliked}, true
 	default:
-		return errors.New("invalid interaction type")
+		return "", nil, false
 	}
-	track, err := s.TrackRepo.GetByTrackID(ctx, trackID)
-	if err != nil {
-		log.Println("Service: Track not found", err)
-		return err
-	}
-	user, err := s.UserRepo.GetUserByID(ctx, userID)
+}
+
+// CreateInteraction records an interaction. Like/dislike style interactions are
+// idempotent: when they do not change the stored track state (e.g. liking a track
+// that is already liked) nothing is recorded and the interest vector is untouched.
+func (s *InteractionService) CreateInteraction(ctx context.Context, userID int, trackID string, interactionType string) error {
+	model := s.TasteModel(ctx, userID)
+	weight, err := tasteWeight(model, interactionType)
 	if err != nil {
-		log.Println("Service: User not found", err)
+		log.Printf("Service: Rejected interaction of type '%s' from user %d: %v", interactionType, userID, err)
 		return err
 	}
+	return s.createInteraction(ctx, model, userID, trackID, interactionType, weight)
+}
 
-	trackVector, err := s.convertTrackToVector(track, weight)
-	if err != nil {
-		log.Println("Service: Error converting track to vector", err)
-		return err
+// statsDelta returns the track counter changes caused by an interaction. previous is
+// the track state the interaction moved away from, for like/dislike style types.
+func statsDelta(trackID string, interactionType string, previous models.TrackInteractionState) models.TrackStats {
+	delta := models.TrackStats{TrackID: trackID}
+	switch interactionType {
+	case "play":
+		delta.Plays = 1
+	case "skip":
+		delta.Skips = 1
+	case "add_to_playlist":
+		delta.PlaylistAdds = 1
 	}
 
-	for i := 0; i < len(user.AvgInterest); i++ {
-		user.AvgInterest[i] = alpha*user.AvgInterest[i] + (1-alpha)*trackVector[i]
+	if to, _, ok := stateTransition(interactionType); ok {
+		switch previous {
+		case models.TrackStateLiked:
+			delta.Likes--
+		case models.TrackStateDisliked:
+			delta.Dislikes--
+		}
+		switch to {
+		case models.TrackStateLiked:
+			delta.Likes++
+		case models.TrackStateDisliked:
+			delta.Dislikes++
+		}
 	}
+	return delta
+}
 
-	err = s.UserRepo.UpdateUser(ctx, user)
+func (s *InteractionService) createInteraction(ctx context.Context, model models.TasteModel, userID int, trackID string, interactionType string, weight float64) error {
+	tx, err := s.DB.Begin(ctx)
 	if err != nil {
-		log.Println("Service: Error updating user interest", err)
 		return err
 	}
+	defer tx.Rollback(ctx)
 
+	recorded, err := s.recordInteractionInTx(ctx, tx, model, userID, trackID, interactionType, weight)
+	if err != nil || recorded == nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Service: Error committing interaction of user %d with track %s: %v", userID, trackID, err)
+		return err
+	}
+	s.interactionCommitted(ctx, recorded)
 	return nil
 }
 
-func (s *InteractionService) CreateInteraction(ctx context.Context, userID int, trackID string, interactionType string) error {
-	log.Printf("Service: User %d creating interaction of type '%s' for track %s", userID, interactionType, trackID)
+// recordedInteraction is an interaction written in a transaction that has yet to be
+// announced, once the transaction commits.
+type recordedInteraction struct {
+	interaction  *models.Interaction
+	weight       float64
+	stateChanged bool
+}
 
-	err := s.HandleInteraction(ctx, userID, trackID, interactionType)
+// recordInteractionInTx writes an interaction with everything derived from it: the
+// user's state for the track, the track's counters and the user's interest vector. It
+// returns nil when a like/dislike style interaction leaves the state as it was, in
+// which case nothing is written. weight is the interaction's pull on the interest
+// vector, which callers may derive themselves (e.g. from how much of a track was heard).
+func (s *InteractionService) recordInteractionInTx(ctx context.Context, tx pgx.Tx, model models.TasteModel, userID int, trackID string, interactionType string, weight float64) (*recordedInteraction, error) {
+	log.Printf("Service: User %d creating interaction of type '%s' (weight %.2f) for track %s", userID, interactionType, weight, trackID)
+
+	track, err := s.getTrack(ctx, trackID)
 	if err != nil {
-		log.Printf("Service: Error handling interaction in service: %v", err)
-		return err
+		return nil, err
+	}
+
+	previous := models.TrackStateNeutral
+	to, allowedFrom, stateful := stateTransition(interactionType)
+	if stateful {
+		var changed bool
+		previous, changed, err = s.Repo.UpdateTrackStateInTx(ctx, tx, userID, trackID, to, allowedFrom...)
+		if err != nil {
+			log.Printf("Service: Error updating track state for user %d and track %s: %v", userID, trackID, err)
+			return nil, err
+		}
+		if !changed {
+			log.Printf("Service: Interaction '%s' leaves track %s %s for user %d, ignoring", interactionType, trackID, previous, userID)
+			return nil, nil
+		}
+	}
+
+	if err := s.updateTasteInTx(ctx, tx, model, userID, track, weight); err != nil {
+		return nil, err
 	}
 
 	interaction := &models.Interaction{
@@ -99,56 +232,139 @@ func (s *InteractionService) CreateInteraction(ctx context.Context, userID int,
 		TrackID: trackID,
 		Type:    interactionType,
 	}
-
-	err = s.Repo.CreateInteraction(ctx, interaction)
-	if err != nil {
+	if err := s.Repo.CreateInteractionInTx(ctx, tx, interaction); err != nil {
 		log.Printf("Service: Error creating interaction in DB: %v", err)
-		return err
+		return nil, err
 	}
 
-	log.Printf("Service: Successfully created interaction for user %d and track %s. Publishing to Kafka.", userID, trackID)
+	if delta := statsDelta(trackID, interactionType, previous); !delta.IsZero() {
+		if err := s.Repo.IncrementTrackStatsInTx(ctx, tx, delta); err != nil {
+			log.Printf("Service: Failed to update stats for track %s: %v", trackID, err)
+			return nil, err
+		}
+	}
+	return &recordedInteraction{interaction: interaction, weight: weight, stateChanged: stateful}, nil
+}
 
-	// Publish event to Kafka
-	msg := kafka.Message{
-		Value: []byte(strconv.Itoa(userID)),
+// interactionCommitted tells the observers about a changed track state and publishes
+// the interaction event; it does nothing for an ignored (nil) interaction. Publishing
+// errors are logged but not returned to the client, as the primary operation (saving
+// the interaction) was successful.
+func (s *InteractionService) interactionCommitted(ctx context.Context, recorded *recordedInteraction) {
+	if recorded == nil {
+		return
 	}
-	err = s.KafkaWriter.WriteMessages(ctx, msg)
+	interaction := recorded.interaction
+	if recorded.stateChanged {
+		for _, observer := range s.Observers {
+			observer.TrackStateChanged(interaction.UserID, interaction.TrackID)
+		}
+	}
+
+	log.Printf("Service: Successfully created interaction for user %d and track %s. Publishing event.", interaction.UserID, interaction.TrackID)
+	event, err := events.NewInteractionEvent(interaction.UserID, interaction.TrackID, interaction.Type, recorded.weight, interaction.CreatedAt)
 	if err != nil {
-		// Log the error but don't return it to the client,
-		// as the primary operation (saving the interaction) was successful.
-		log.Printf("Service: Failed to write message to Kafka: %v", err)
+		log.Printf("Service: Failed to build interaction event: %v", err)
+		return
+	}
+	if err := s.Publisher.Publish(ctx, event); err != nil {
+		log.Printf("Service: Failed to publish interaction event: %v", err)
 	}
+}
 
-	return nil
+func (s *InteractionService) GetInteractionsForTrack(ctx context.Context, trackID string, page models.PageRequest) (*models.Page[models.Interaction], error) {
+	log.Printf("Service: Getting interactions for track %s with limit %d and offset %d", trackID, page.Limit, page.Offset)
+	return s.Repo.GetInteractionsForTrack(ctx, trackID, page)
 }
 
-func (s *InteractionService) GetInteractionsForTrack(ctx context.Context, trackID string) ([]models.Interaction, error) {
-	log.Printf("Service: Getting interactions for track %s", trackID)
-	return s.Repo.GetInteractionsForTrack(ctx, trackID)
+func (s *InteractionService) GetInteractionsByUser(ctx context.Context, userID int, page models.PageRequest) (*models.Page[models.Interaction], error) {
+	log.Printf("Service: Getting interactions of user %d with limit %d and offset %d", userID, page.Limit, page.Offset)
+	return s.Repo.GetInteractionsByUser(ctx, userID, page)
+}
+
+func (s *InteractionService) RebuildTrackStats(ctx context.Context) (int64, error) {
+	log.Println("Service: Rebuilding track stats")
+	count, err := s.Repo.RebuildTrackStats(ctx)
+	if err != nil {
+		log.Printf("Service: Error rebuilding track stats: %v", err)
+		return 0, err
+	}
+	log.Printf("Service: Rebuilt stats for %d tracks", count)
+	return count, nil
+}
+
+// BackfillTrackStates derives missing track states from past interactions and returns
+// how many were added.
+func (s *InteractionService) BackfillTrackStates(ctx context.Context) (int64, error) {
+	log.Println("Service: Backfilling track states")
+	count, err := s.Repo.BackfillTrackStates(ctx)
+	if err != nil {
+		log.Printf("Service: Error backfilling track states: %v", err)
+		return 0, err
+	}
+	log.Printf("Service: Backfilled %d track states", count)
+	return count, nil
+}
+
+func (s *InteractionService) GetTrackStats(ctx context.Context, trackID string) (*models.TrackStats, error) {
+	log.Printf("Service: Getting stats for track %s", trackID)
+	if _, err := s.TrackRepo.GetByTrackID(ctx, trackID); err != nil {
+		log.Printf("Service: Error getting track %s for stats: %v", trackID, err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("track not found")
+		}
+		return nil, err
+	}
+	return s.Repo.GetTrackStats(ctx, trackID)
 }
 
 func (s *InteractionService) GetTrackInteractionStates(ctx context.Context, userID int, trackIDs []string) (map[string]models.TrackInteractionState, error) {
-	interactionMap, err := s.Repo.GetLatestInteractionsForUserTracks(ctx, userID, trackIDs)
+	stateMap, err := s.Repo.GetTrackStates(ctx, userID, trackIDs)
 	if err != nil {
 		return nil, err
 	}
 
 	trackStates := make(map[string]models.TrackInteractionState)
 	for _, trackID := range trackIDs {
-		interactionType, found := interactionMap[trackID]
+		state, found := stateMap[trackID]
 		if !found {
-			trackStates[trackID] = models.TrackStateNeutral
-			continue
-		}
-
-		switch interactionType {
-		case "like":
-			trackStates[trackID] = models.TrackStateLiked
-		case "dislike":
-			trackStates[trackID] = models.TrackStateDisliked
-		default:
-			trackStates[trackID] = models.TrackStateNeutral
+			state = models.TrackStateNeutral
 		}
+		trackStates[trackID] = state
 	}
 	return trackStates, nil
 }
+
+// SetLiked likes (liked=true) or unlikes a track and returns its resulting state.
+func (s *InteractionService) SetLiked(ctx context.Context, userID int, trackID string, liked bool) (models.TrackInteractionState, error) {
+	interactionType := "unlike"
+	if liked {
+		interactionType = "like"
+	}
+	return s.setTrackState(ctx, userID, trackID, interactionType)
+}
+
+// SetDisliked dislikes (disliked=true) or undislikes a track and returns its resulting state.
+func (s *InteractionService) SetDisliked(ctx context.Context, userID int, trackID string, disliked bool) (models.TrackInteractionState, error) {
+	interactionType := "undislike"
+	if disliked {
+		interactionType = "dislike"
+	}
+	return s.setTrackState(ctx, userID, trackID, interactionType)
+}
+
+func (s *InteractionService) setTrackState(ctx context.Context, userID int, trackID string, interactionType string) (models.TrackInteractionState, error) {
+	if err := s.CreateInteraction(ctx, userID, trackID, interactionType); err != nil {
+		return "", err
+	}
+	states, err := s.GetTrackInteractionStates(ctx, userID, []string{trackID})
+	if err != nil {
+		return "", err
+	}
+	return states[trackID], nil
+}
+
+func (s *InteractionService) ListLikedTracks(ctx context.Context, userID int, limit int, offset int) ([]models.SpotifyTrack, error) {
+	log.Printf("Service: Listing liked tracks for user %d with limit %d and offset %d", userID, limit, offset)
+	return s.Repo.ListTracksByState(ctx, userID, models.TrackStateLiked, limit, offset)
+}
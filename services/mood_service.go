@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+// MaxMoodPlaylistTracks is the most tracks a generated mood playlist holds.
+const MaxMoodPlaylistTracks = 100
+
+func bound(value float64) *float64 {
+	return &value
+}
+
+// defaultMoods are the built-in moods. They can be replaced at startup with LoadMoods.
+var defaultMoods = []models.Mood{
+	{
+		Name:        "workout",
+		Description: "High energy and a fast, steady beat",
+		Ranges: map[string]models.Range{
+			"energy":       {Min: bound(0.7)},
+			"danceability": {Min: bound(0.5)},
+			"tempo":        {Min: bound(120), Max: bound(180)},
+		},
+	},
+	{
+		Name:        "party",
+		Description: "Danceable, upbeat and loud",
+		Ranges: map[string]models.Range{
+			"danceability": {Min: bound(0.7)},
+			"energy":       {Min: bound(0.6)},
+			"valence":      {Min: bound(0.5)},
+		},
+	},
+	{
+		Name:        "chill",
+		Description: "Relaxed and mellow, but not gloomy",
+		Ranges: map[string]models.Range{
+			"energy":  {Max: bound(0.5)},
+			"valence": {Min: bound(0.3)},
+			"tempo":   {Max: bound(115)},
+		},
+	},
+	{
+		Name:        "focus",
+		Description: "Mostly instrumental with few vocals to distract",
+		Ranges: map[string]models.Range{
+			"instrumentalness": {Min: bound(0.5)},
+			"speechiness":      {Max: bound(0.1)},
+			"energy":           {Max: bound(0.6)},
+		},
+	},
+	{
+		Name:        "sad",
+		Description: "Low valence and low energy",
+		Ranges: map[string]models.Range{
+			"valence": {Max: bound(0.3)},
+			"energy":  {Max: bound(0.5)},
+		},
+	},
+	{
+		Name:        "sleep",
+		Description: "Quiet, acoustic and calm",
+		Ranges: map[string]models.Range{
+			"energy":       {Max: bound(0.3)},
+			"acousticness": {Min: bound(0.6)},
+			"loudness":     {Max: bound(-12)},
+		},
+	},
+}
+
+// LoadMoods reads moods from a JSON array of models.Mood. An empty path returns the
+// built-in moods.
+func LoadMoods(path string) ([]models.Mood, error) {
+	if path == "" {
+		return defaultMoods, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read moods: %w", err)
+	}
+	var moods []models.Mood
+	if err := json.Unmarshal(data, &moods); err != nil {
+		return nil, fmt.Errorf("failed to parse moods: %w", err)
+	}
+	seen := make(map[string]bool)
+	for i := range moods {
+		if err := moods[i].Validate(); err != nil {
+			return nil, err
+		}
+		if seen[moods[i].Name] {
+			return nil, fmt.Errorf("mood %s is defined twice", moods[i].Name)
+		}
+		seen[moods[i].Name] = true
+	}
+	return moods, nil
+}
+
+type MoodService struct {
+	TrackRepo  repository.SpotifyTrackRepository
+	UserRepo   repository.UserRepository
+	Similarity *SimilarityService
+	Playlists  *PlaylistService
+	Moods      []models.Mood
+}
+
+func NewMoodService(trackRepo repository.SpotifyTrackRepository, userRepo repository.UserRepository, similarity *SimilarityService, playlists *PlaylistService, moods []models.Mood) *MoodService {
+	return &MoodService{TrackRepo: trackRepo, UserRepo: userRepo, Similarity: similarity, Playlists: playlists, Moods: moods}
+}
+
+func (s *MoodService) ListMoods() []models.Mood {
+	return s.Moods
+}
+
+func (s *MoodService) findMood(name string) (*models.Mood, error) {
+	for i := range s.Moods {
+		if s.Moods[i].Name == name {
+			return &s.Moods[i], nil
+		}
+	}
+	return nil, errors.New("mood not found")
+}
+
+// ListTracks lists the tracks in a mood, further narrowed down by filter. Ranges in
+// filter are intersected with the mood's.
+func (s *MoodService) ListTracks(ctx context.Context, name string, filter models.TrackFilter, page models.PageRequest, sortBy string, order string) (*models.Page[models.SpotifyTrack], error) {
+	mood, err := s.findMood(name)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Service: Listing tracks of mood '%s'", name)
+
+	ranges := make(map[string]models.Range, len(filter.Ranges)+len(mood.Ranges))
+	for field, r := range filter.Ranges {
+		ranges[field] = r
+	}
+	for field, r := range mood.Ranges {
+		ranges[field] = r.Intersect(ranges[field])
+	}
+	filter.Ranges = ranges
+	return s.TrackRepo.List(ctx, filter, page, sortBy, order)
+}
+
+// GeneratePlaylist creates a playlist of up to limit tracks in a mood for a user, picked
+// to match the user's avg_interest. An empty playlistName defaults to "<mood> mix".
+func (s *MoodService) GeneratePlaylist(ctx context.Context, userID int, name string, playlistName string, description *string, limit int) (*models.Playlist, []models.SpotifyTrack, error) {
+	mood, err := s.findMood(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Service: Generating '%s' playlist of %d tracks for user %d", name, limit, userID)
+
+	user, err := s.UserRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("Service: Error getting user %d for mood playlist: %v", userID, err)
+		return nil, nil, errors.New("user not found")
+	}
+
+	tracks, err := s.Similarity.MatchTaste(ctx, user.AvgInterest, models.TasteMatchOptions{Limit: limit, Ranges: mood.Ranges})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tracks) == 0 {
+		return nil, nil, errors.New("no tracks match this mood")
+	}
+
+	if playlistName == "" {
+		playlistName = mood.Name + " mix"
+	}
+	if description == nil {
+		description = &mood.Description
+	}
+	trackIDs := make([]string, len(tracks))
+	for i, track := range tracks {
+		trackIDs[i] = track.TrackID
+	}
+	playlist, err := s.Playlists.CreatePlaylistWithTracks(ctx, userID, playlistName, description, trackIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return playlist, tracks, nil
+}
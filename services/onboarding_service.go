@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+const (
+	// MaxOnboardingSamples is the most sample tracks a single request returns.
+	MaxOnboardingSamples = 50
+	// onboardingTracksPerArtist and onboardingTracksPerGenre are how many of the most
+	// popular tracks of a picked artist or genre seed the taste.
+	onboardingTracksPerArtist = 5
+	onboardingTracksPerGenre  = 10
+	// onboardingPickedWeight is how much more a picked track counts than the tracks
+	// standing in for a picked artist or genre.
+	onboardingPickedWeight = 2.0
+	// onboardingRecommendations is how many tracks the first recommendation playlist holds.
+	onboardingRecommendations = 30
+	// onboardingModelVersion marks recommendation runs produced by onboarding.
+	onboardingModelVersion = "onboarding"
+)
+
+// OnboardingService gives new users a starting taste, so that recommendations make
+// sense before they have interacted with enough tracks.
+type OnboardingService struct {
+	DB              *pgxpool.Pool
+	UserRepo        repository.UserRepository
+	InteractionRepo repository.InteractionRepository
+	TrackRepo       repository.SpotifyTrackRepository
+	Artists         *ArtistService
+	Genres          *GenreService
+	Similarity      *SimilarityService
+	Recommendations *RecommendationService
+}
+
+func NewOnboardingService(db *pgxpool.Pool, userRepo repository.UserRepository, interactionRepo repository.InteractionRepository, trackRepo repository.SpotifyTrackRepository, artists *ArtistService, genres *GenreService, similarity *SimilarityService, recommendations *RecommendationService) *OnboardingService {
+	return &OnboardingService{DB: db, UserRepo: userRepo, InteractionRepo: interactionRepo, TrackRepo: trackRepo, Artists: artists, Genres: genres, Similarity: similarity, Recommendations: recommendations}
+}
+
+// ListGenres returns the top-level genres with tracks, largest first, to pick from.
+func (s *OnboardingService) ListGenres(ctx context.Context) ([]models.Genre, error) {
+	genres, err := s.Genres.ListGenres(ctx)
+	if err != nil {
+		return nil, err
+	}
+	topLevel := make([]models.Genre, 0, len(genres))
+	for _, genre := range genres {
+		if genre.Parent == "" && genre.TotalTrackCount > 0 {
+			topLevel = append(topLevel, genre)
+		}
+	}
+	sort.SliceStable(topLevel, func(i, j int) bool { return topLevel[i].TotalTrackCount > topLevel[j].TotalTrackCount })
+	return topLevel, nil
+}
+
+// SampleTracks returns up to limit popular tracks to pick from, spread evenly over genres
+// (each with its subgenres), or from the whole catalog if no genres are given.
+func (s *OnboardingService) SampleTracks(ctx context.Context, genres []string, limit int) ([]models.SpotifyTrack, error) {
+	if len(genres) == 0 {
+		page, err := s.TrackRepo.List(ctx, models.TrackFilter{}, models.PageRequest{Limit: limit}, "popularity", "desc")
+		if err != nil {
+			return nil, err
+		}
+		return page.Items, nil
+	}
+
+	perGenre := (limit + len(genres) - 1) / len(genres)
+	var samples []models.SpotifyTrack
+	for _, genre := range genres {
+		tracks, err := s.popularTracksOfGenres(ctx, []string{genre}, perGenre)
+		if err != nil {
+			return nil, err
+		}
+		for _, track := range tracks {
+			if !slices.ContainsFunc(samples, func(t models.SpotifyTrack) bool { return t.TrackID == track.TrackID }) {
+				samples = append(samples, track)
+			}
+		}
+	}
+	if len(samples) > limit {
+		samples = samples[:limit]
+	}
+	return samples, nil
+}
+
+func (s *OnboardingService) popularTracksOfGenres(ctx context.Context, genres []string, limit int) ([]models.SpotifyTrack, error) {
+	expanded, err := s.Genres.ExpandGenres(ctx, genres)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.TrackRepo.List(ctx, models.TrackFilter{Genres: expanded}, models.PageRequest{Limit: limit}, "popularity", "desc")
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// Complete seeds the user's avg_interest from the picks and fills the recommendation
+// playlist with tracks matching it. The taste is the weighted mean of the features of
+// the picked tracks, the most popular tracks of the picked artists and of the picked
+// genres, scaled as if the user had liked them all. Recommendations stay within the
+// picked genres and the genres of the picked tracks, if any genres were picked.
+// Onboarding is for new users only: it fails with "user already onboarded" once the user
+// has a taste or any interaction, so that a taste learned from listening is never
+// replaced. The taste and the recommendation playlist are saved in one transaction.
+func (s *OnboardingService) Complete(ctx context.Context, userID int, req models.OnboardingRequest) (*models.OnboardingResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	log.Printf("Service: User %d onboarding with %d genres, %d artists and %d tracks", userID, len(req.Genres), len(req.ArtistIDs), len(req.TrackIDs))
+
+	user, err := s.UserRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("Service: Error getting user %d for onboarding: %v", userID, err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	if hasTaste(user.AvgInterest) {
+		return nil, errors.New("user already onboarded")
+	}
+
+	trackIDs := slices.Compact(slices.Sorted(slices.Values(req.TrackIDs)))
+	picked, err := s.TrackRepo.GetByTrackIDs(ctx, trackIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(picked) != len(trackIDs) {
+		return nil, fmt.Errorf("%w: unknown track", models.ErrInvalidOnboarding)
+	}
+
+	var standIns []models.SpotifyTrack
+	for _, artistID := range req.ArtistIDs {
+		tracks, err := s.Artists.ListArtistTracks(ctx, artistID, onboardingTracksPerArtist, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(tracks) == 0 {
+			return nil, fmt.Errorf("%w: unknown artist %d", models.ErrInvalidOnboarding, artistID)
+		}
+		standIns = append(standIns, tracks...)
+	}
+	var genres []string
+	if len(req.Genres) > 0 {
+		tracks, err := s.popularTracksOfGenres(ctx, req.Genres, onboardingTracksPerGenre*len(req.Genres))
+		if err != nil {
+			if err.Error() == "genre not found" {
+				return nil, fmt.Errorf("%w: unknown genre", models.ErrInvalidOnboarding)
+			}
+			return nil, err
+		}
+		standIns = append(standIns, tracks...)
+
+		if genres, err = s.Genres.ExpandGenres(ctx, req.Genres); err != nil {
+			return nil, err
+		}
+		for _, track := range picked {
+			if !slices.Contains(genres, track.TrackGenre) {
+				genres = append(genres, track.TrackGenre)
+			}
+		}
+	}
+	if len(picked)+len(standIns) == 0 {
+		return nil, fmt.Errorf("%w: the picks have no tracks", models.ErrInvalidOnboarding)
+	}
+
+	likeWeight, _ := interactionWeight("like")
+	avgInterest := make(models.FloatVector, featureCount)
+	totalWeight := 0.0
+	add := func(tracks []models.SpotifyTrack, weight float64) {
+		for i := range tracks {
+			for f, value := range trackFeatures(&tracks[i]) {
+				avgInterest[f] += weight * value
+			}
+			totalWeight += weight
+		}
+	}
+	add(picked, onboardingPickedWeight)
+	add(standIns, 1)
+	for f := range avgInterest {
+		avgInterest[f] = avgInterest[f] / totalWeight * likeWeight
+	}
+
+	seedIDs := make([]string, 0, len(picked)+len(standIns))
+	for _, track := range slices.Concat(picked, standIns) {
+		seedIDs = append(seedIDs, track.TrackID)
+	}
+	recommended, err := s.Similarity.MatchTaste(ctx, avgInterest, models.TasteMatchOptions{Limit: onboardingRecommendations, Genres: genres, ExcludeTrackIDs: seedIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	// --- Start Transaction ---
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Check again under the lock, interactions may have arrived since.
+	if user, err = s.UserRepo.LockUserInTx(ctx, tx, userID); err != nil {
+		log.Printf("Service: Error locking user %d for onboarding: %v", userID, err)
+		return nil, err
+	}
+	interacted, err := s.InteractionRepo.HasInteractionsInTx(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if interacted || hasTaste(user.AvgInterest) {
+		return nil, errors.New("user already onboarded")
+	}
+
+	if err := s.UserRepo.UpdateAvgInterestInTx(ctx, tx, userID, avgInterest); err != nil {
+		log.Printf("Service: Error saving onboarding taste of user %d: %v", userID, err)
+		return nil, err
+	}
+	user.AvgInterest = avgInterest
+
+	result := &models.OnboardingResult{AvgInterest: avgInterest, SeedTrackCount: len(seedIDs), RecommPlaylistID: user.RecommPlaylistID}
+	if len(recommended) > 0 {
+		trackIDs := make([]string, len(recommended))
+		for i, track := range recommended {
+			trackIDs[i] = track.TrackID
+		}
+		if result.RecommendedTrackCount, err = s.Recommendations.applyRecommendationsInTx(ctx, tx, user, trackIDs, onboardingModelVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	// --- Commit Transaction ---
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Failed to commit onboarding of user %d: %v", userID, err)
+		return nil, err
+	}
+	log.Printf("Service: User %d onboarded from %d seed tracks with %d recommendations", userID, result.SeedTrackCount, result.RecommendedTrackCount)
+	return result, nil
+}
+
+// hasTaste reports whether avgInterest holds anything learned, from onboarding or
+// interactions.
+func hasTaste(avgInterest models.FloatVector) bool {
+	for _, value := range avgInterest {
+		if value != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+// A listen that ends before skipThreshold of the track counts as a skip.
+const skipThreshold = 0.3
+
+type PlayHistoryService struct {
+	DB                 *pgxpool.Pool
+	Repo               repository.PlayHistoryRepository
+	TrackRepo          repository.SpotifyTrackRepository
+	InteractionService *InteractionService
+}
+
+func NewPlayHistoryService(db *pgxpool.Pool, repo repository.PlayHistoryRepository, trackRepo repository.SpotifyTrackRepository, interactionService *InteractionService) *PlayHistoryService {
+	return &PlayHistoryService{DB: db, Repo: repo, TrackRepo: trackRepo, InteractionService: interactionService}
+}
+
+// completionInteraction turns how much of a track was heard into an interaction type
+// and weight: early skips weigh as much as a regular skip, late skips barely count,
+// and plays weigh proportionally to how much was heard. The full weights come from the
+// user's taste model.
+func completionInteraction(model models.TasteModel, completionRatio float64) (string, float64) {
+	if completionRatio < skipThreshold {
+		skipWeight, _ := tasteWeight(model, "skip")
+		return "skip", skipWeight * (1 - completionRatio/skipThreshold)
+	}
+	playWeight, _ := tasteWeight(model, "play")
+	return "play", playWeight * completionRatio
+}
+
+func (s *PlayHistoryService) StartSession(ctx context.Context, userID int, trackID string, contextType models.PlayContextType, contextID *string) (*models.PlaySession, error) {
+	log.Printf("Service: User %d starting playback of track %s from %s", userID, trackID, contextType)
+
+	if contextType == "" {
+		contextType = models.PlayContextUnknown
+	}
+	if !contextType.IsValid() {
+		return nil, errors.New("invalid context type")
+	}
+
+	track, err := s.TrackRepo.GetByTrackID(ctx, trackID)
+	if err != nil {
+		log.Printf("Service: Error getting track %s for play session: %v", trackID, err)
+		return nil, errors.New("track not found")
+	}
+
+	session := &models.PlaySession{
+		UserID:          userID,
+		TrackID:         trackID,
+		ContextType:     contextType,
+		ContextID:       contextID,
+		TrackDurationMs: track.DurationMs,
+	}
+	if _, err := s.Repo.CreateSession(ctx, session); err != nil {
+		log.Printf("Service: Error creating play session: %v", err)
+		return nil, err
+	}
+	return session, nil
+}
+
+// getOpenSession loads a session and checks that it belongs to userID and has not ended yet.
+func (s *PlayHistoryService) getOpenSession(ctx context.Context, userID int, sessionID int64) (*models.PlaySession, error) {
+	session, err := s.Repo.GetSession(ctx, sessionID)
+	if err != nil {
+		log.Printf("Service: Error getting play session %d: %v", sessionID, err)
+		return nil, errors.New("play session not found")
+	}
+	if session.UserID != userID {
+		log.Printf("Service: User %d does not own play session %d", userID, sessionID)
+		return nil, errors.New("forbidden: you do not own this play session")
+	}
+	if session.EndedAt != nil {
+		return nil, errors.New("play session already ended")
+	}
+	return session, nil
+}
+
+// clampPosition keeps a reported position within the track.
+func clampPosition(positionMs int64, durationMs int64) int64 {
+	if positionMs < 0 {
+		return 0
+	}
+	if durationMs > 0 && positionMs > durationMs {
+		return durationMs
+	}
+	return positionMs
+}
+
+func (s *PlayHistoryService) RecordProgress(ctx context.Context, userID int, sessionID int64, positionMs int64) error {
+	session, err := s.getOpenSession(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	return s.Repo.UpdateProgress(ctx, sessionID, clampPosition(positionMs, session.TrackDurationMs))
+}
+
+// EndSession closes the session and feeds its completion ratio into the user's interest
+// vector, in one transaction: if recording the play or skip fails the session stays open
+// and ending it can be retried. A session whose track was deleted meanwhile is ended
+// without an interaction.
+func (s *PlayHistoryService) EndSession(ctx context.Context, userID int, sessionID int64, positionMs int64) (*models.PlaySession, error) {
+	log.Printf("Service: User %d ending play session %d at %dms", userID, sessionID, positionMs)
+
+	session, err := s.getOpenSession(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	positionMs = clampPosition(positionMs, session.TrackDurationMs)
+	if session.PositionMs > positionMs {
+		positionMs = session.PositionMs
+	}
+	completionRatio := 1.0
+	if session.TrackDurationMs > 0 {
+		completionRatio = float64(positionMs) / float64(session.TrackDurationMs)
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	ended, err := s.Repo.EndSessionInTx(ctx, tx, sessionID, positionMs, completionRatio)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("play session already ended")
+		}
+		log.Printf("Service: Error ending play session %d: %v", sessionID, err)
+		return nil, err
+	}
+
+	model := s.InteractionService.TasteModel(ctx, userID)
+	interactionType, weight := completionInteraction(model, completionRatio)
+	recorded, err := s.InteractionService.recordInteractionInTx(ctx, tx, model, userID, ended.TrackID, interactionType, weight)
+	if err != nil && err.Error() == "track not found" {
+		// The track was deleted while it played; the session still has to end.
+		log.Printf("Service: Track %s of play session %d is gone, ending it without a %s", ended.TrackID, sessionID, interactionType)
+		recorded, err = nil, nil
+	}
+	if err != nil {
+		log.Printf("Service: Error recording %s for play session %d: %v", interactionType, sessionID, err)
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Service: Error committing the end of play session %d: %v", sessionID, err)
+		return nil, err
+	}
+	s.InteractionService.interactionCommitted(ctx, recorded)
+	return ended, nil
+}
+
+func (s *PlayHistoryService) ListHistory(ctx context.Context, userID int, limit int, offset int) ([]models.PlayHistoryEntry, error) {
+	log.Printf("Service: Listing play history for user %d with limit %d and offset %d", userID, limit, offset)
+	return s.Repo.ListByUser(ctx, userID, limit, offset)
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+const (
+	// generateDurationTolerance is how far a generated playlist may be off its target duration.
+	generateDurationTolerance = 60 * 1000
+	// generateTrackEstimateMs is the track length used to estimate how many candidates
+	// a duration needs.
+	generateTrackEstimateMs = 3 * 60 * 1000
+	// generateCandidateFactor is how many candidates are ranked per track needed, so that
+	// artist limits and durations still leave enough to choose from.
+	generateCandidateFactor = 4
+	// minArtistGap is how many tracks must separate two tracks of the same artist.
+	minArtistGap = 3
+
+	featureEnergy = 1
+	featureTempo  = 8
+)
+
+// PlaylistGeneratorService builds playlists from seed tracks, seed genres and target
+// feature values using the similarity index.
+type PlaylistGeneratorService struct {
+	TrackRepo  repository.SpotifyTrackRepository
+	Similarity *SimilarityService
+	Genres     *GenreService
+	Playlists  *PlaylistService
+}
+
+func NewPlaylistGeneratorService(trackRepo repository.SpotifyTrackRepository, similarity *SimilarityService, genres *GenreService, playlists *PlaylistService) *PlaylistGeneratorService {
+	return &PlaylistGeneratorService{TrackRepo: trackRepo, Similarity: similarity, Genres: genres, Playlists: playlists}
+}
+
+// Generate picks tracks for req and orders them into a smooth flow. If req.Save is set,
+// the tracks are saved as a new playlist of userID, which is returned too.
+func (s *PlaylistGeneratorService) Generate(ctx context.Context, userID int, req models.GeneratePlaylistRequest) (*models.Playlist, []models.SpotifyTrack, error) {
+	if err := req.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if req.DurationMs == 0 {
+		req.DurationMs = models.DefaultGeneratedDurationMs
+	}
+	log.Printf("Service: User %d generating a playlist of %d ms from %d seed tracks, %d seed genres and %d targets", userID, req.DurationMs, len(req.SeedTracks), len(req.SeedGenres), len(req.Targets))
+
+	idx := s.Similarity.index.Load()
+	if idx == nil {
+		return nil, nil, errors.New("similarity index is not ready")
+	}
+
+	seeds, err := s.TrackRepo.GetByTrackIDs(ctx, req.SeedTracks)
+	if err != nil {
+		log.Printf("Service: Error getting seed tracks: %v", err)
+		return nil, nil, err
+	}
+	for _, id := range req.SeedTracks {
+		if !slices.ContainsFunc(seeds, func(t models.SpotifyTrack) bool { return t.TrackID == id }) {
+			return nil, nil, fmt.Errorf("%w: seed track %s not found", models.ErrInvalidGenerateRequest, id)
+		}
+	}
+
+	var genres []string
+	if len(req.SeedGenres) > 0 {
+		if genres, err = s.Genres.ExpandGenres(ctx, req.SeedGenres); err != nil {
+			if err.Error() == "genre not found" {
+				return nil, nil, fmt.Errorf("%w: unknown seed genre", models.ErrInvalidGenerateRequest)
+			}
+			return nil, nil, err
+		}
+	}
+
+	target, weighted := generateTarget(idx, seeds, req.Targets)
+	seedSongs := make([]string, len(seeds))
+	for i := range seeds {
+		seedSongs[i] = songKey(&seeds[i])
+	}
+	score := func(entry *similarityEntry) float64 {
+		popularity := float64(entry.popularity) / 100
+		var squared float64
+		var dims int
+		for f := range target {
+			if weighted[f] {
+				d := entry.vector[f] - target[f]
+				squared += d * d
+				dims++
+			}
+		}
+		if dims == 0 {
+			return popularity // only genres were given
+		}
+		closeness := 1 - math.Sqrt(squared/float64(dims))
+		return (1-tastePopularityWeight)*closeness + tastePopularityWeight*popularity
+	}
+	keep := func(entry *similarityEntry) bool {
+		if slices.Contains(seedSongs, entry.song) {
+			return false
+		}
+		return len(genres) == 0 || slices.Contains(genres, entry.genre)
+	}
+
+	candidates := int(req.DurationMs/generateTrackEstimateMs+1) * generateCandidateFactor
+	selected := selectForDuration(idx.top(candidates, score, keep), req.DurationMs)
+	if len(selected) == 0 {
+		return nil, nil, errors.New("no tracks match the request")
+	}
+
+	ordered := orderByFlow(selected)
+	trackIDs := make([]string, len(ordered))
+	for i, entry := range ordered {
+		trackIDs[i] = entry.trackID
+	}
+	tracks, err := s.TrackRepo.GetByTrackIDs(ctx, trackIDs)
+	if err != nil {
+		log.Printf("Service: Error loading generated tracks: %v", err)
+		return nil, nil, err
+	}
+
+	if !req.Save {
+		return nil, tracks, nil
+	}
+	name := req.Name
+	if name == "" {
+		name = "Generated playlist"
+	}
+	playlist, err := s.Playlists.CreatePlaylistWithTracks(ctx, userID, name, req.Description, trackIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return playlist, tracks, nil
+}
+
+// generateTarget returns the normalized point generated tracks should be close to: the
+// mean of the seed tracks, with explicit targets taking precedence. Features that are
+// neither seeded nor targeted are not weighted.
+func generateTarget(idx *similarityIndex, seeds []models.SpotifyTrack, targets map[string]float64) (featureVector, [featureCount]bool) {
+	var target featureVector
+	var weighted [featureCount]bool
+	for i := range seeds {
+		vector := idx.normalize(trackFeatures(&seeds[i]))
+		for f := range target {
+			target[f] += vector[f] / float64(len(seeds))
+			weighted[f] = true
+		}
+	}
+
+	var raw featureVector
+	for f, feature := range models.AudioFeatures {
+		if value, ok := targets[feature]; ok {
+			raw[f] = value
+		}
+	}
+	normalized := idx.normalize(raw)
+	for f, feature := range models.AudioFeatures {
+		if _, ok := targets[feature]; ok {
+			target[f] = normalized[f]
+			weighted[f] = true
+		}
+	}
+	return target, weighted
+}
+
+// selectForDuration takes ranked candidates, best first, until they add up to about
+// durationMs, with at most maxTracksPerArtist tracks per artist.
+func selectForDuration(candidates []similarityMatch, durationMs int64) []*similarityEntry {
+	var selected []*similarityEntry
+	var total int64
+	perArtist := make(map[string]int)
+	for _, candidate := range candidates {
+		if total >= durationMs-generateDurationTolerance {
+			break
+		}
+		entry := candidate.entry
+		if total+entry.durationMs > durationMs+generateDurationTolerance {
+			continue
+		}
+		if lead := leadArtist(entry); lead != "" {
+			if perArtist[lead] == maxTracksPerArtist {
+				continue
+			}
+			perArtist[lead]++
+		}
+		selected = append(selected, entry)
+		total += entry.durationMs
+	}
+	return selected
+}
+
+func leadArtist(entry *similarityEntry) string {
+	if len(entry.artists) == 0 {
+		return ""
+	}
+	return entry.artists[0]
+}
+
+// orderByFlow orders tracks so that energy and tempo change gradually: it starts with the
+// calmest track and always continues with the closest remaining one in energy and tempo,
+// skipping tracks whose artist played in the last minArtistGap tracks while others are left.
+func orderByFlow(tracks []*similarityEntry) []*similarityEntry {
+	remaining := slices.Clone(tracks)
+	start := 0
+	for i, entry := range remaining {
+		if entry.vector[featureEnergy] < remaining[start].vector[featureEnergy] {
+			start = i
+		}
+	}
+	ordered := []*similarityEntry{remaining[start]}
+	remaining = slices.Delete(remaining, start, start+1)
+
+	for len(remaining) > 0 {
+		last := ordered[len(ordered)-1]
+		recent := ordered[max(0, len(ordered)-minArtistGap):]
+		best, bestCost, bestRepeats := -1, math.Inf(1), true
+		for i, entry := range remaining {
+			repeats := slices.ContainsFunc(recent, func(r *similarityEntry) bool { return leadArtist(r) != "" && leadArtist(r) == leadArtist(entry) })
+			cost := math.Abs(entry.vector[featureEnergy]-last.vector[featureEnergy]) + math.Abs(entry.vector[featureTempo]-last.vector[featureTempo])
+			// Any track that keeps the artist gap beats one that does not.
+			if (bestRepeats && !repeats) || (repeats == bestRepeats && cost < bestCost) {
+				best, bestCost, bestRepeats = i, cost, repeats
+			}
+		}
+		ordered = append(ordered, remaining[best])
+		remaining = slices.Delete(remaining, best, best+1)
+	}
+	return ordered
+}
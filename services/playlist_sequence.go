@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+const (
+	// MaxSequenceTracks is the longest playlist that can be sequenced.
+	MaxSequenceTracks = 500
+	// sequenceKeyWeight is the share of a transition's score that comes from the key;
+	// the rest comes from the tempo.
+	sequenceKeyWeight = 0.6
+	// sequenceTempoTolerance is the relative tempo change at which a transition's tempo
+	// score reaches 0; DJs rarely pitch a track by more than about 8%.
+	sequenceTempoTolerance = 0.08
+	// sequenceGreedyStarts is how many start tracks the greedy pass tries.
+	sequenceGreedyStarts = 50
+	// sequenceMaxPasses bounds the 2-opt improvement passes.
+	sequenceMaxPasses = 50
+)
+
+// camelot returns the Camelot wheel position of a key: a number from 1 to 12 and the
+// letter B for major or A for minor. Keys a fifth apart have neighbouring numbers, and
+// a major key and its relative minor share a number. ok is false for unknown keys.
+func camelot(key int64, mode int64) (number int, minor bool, ok bool) {
+	if key < 0 || key > 11 {
+		return 0, false, false
+	}
+	offset := int64(8) // C major is 8B
+	if mode == 0 {
+		offset = 5 // A minor is 8A
+	}
+	number = int((7*key + offset) % 12)
+	if number == 0 {
+		number = 12
+	}
+	return number, mode == 0, true
+}
+
+func camelotCode(track *models.SpotifyTrack) string {
+	number, minor, ok := camelot(track.Key, track.Mode)
+	if !ok {
+		return ""
+	}
+	if minor {
+		return fmt.Sprintf("%dA", number)
+	}
+	return fmt.Sprintf("%dB", number)
+}
+
+// keyScore rates how well two keys mix: the same key or a neighbour on the wheel mix
+// cleanly, the relative major/minor and a diagonal step are fine, two steps is an energy
+// boost, anything else clashes. Unknown keys score neutral.
+func keyScore(a, b *models.SpotifyTrack) float64 {
+	na, minorA, okA := camelot(a.Key, a.Mode)
+	nb, minorB, okB := camelot(b.Key, b.Mode)
+	if !okA || !okB {
+		return 0.5
+	}
+	steps := (na - nb + 12) % 12
+	steps = min(steps, 12-steps)
+	switch {
+	case steps == 0 && minorA == minorB:
+		return 1
+	case steps == 1 && minorA == minorB:
+		return 0.9
+	case steps == 0:
+		return 0.8
+	case steps == 1:
+		return 0.6
+	case steps == 2 && minorA == minorB:
+		return 0.5
+	}
+	return 0
+}
+
+// tempoScore rates the tempo jump between two tracks, treating half and double time as
+// the same tempo. Unknown tempos score neutral.
+func tempoScore(a, b *models.SpotifyTrack) float64 {
+	if a.Tempo <= 0 || b.Tempo <= 0 {
+		return 0.5
+	}
+	change := math.Inf(1)
+	for _, factor := range []float64{0.5, 1, 2} {
+		other := b.Tempo * factor
+		change = math.Min(change, math.Abs(a.Tempo-other)/math.Max(a.Tempo, other))
+	}
+	return math.Max(0, 1-change/sequenceTempoTolerance)
+}
+
+func transition(a, b *models.SpotifyTrack) models.Transition {
+	key, tempo := keyScore(a, b), tempoScore(a, b)
+	return models.Transition{
+		FromTrackID: a.TrackID,
+		ToTrackID:   b.TrackID,
+		FromKey:     camelotCode(a),
+		ToKey:       camelotCode(b),
+		KeyScore:    key,
+		TempoScore:  tempo,
+		Score:       sequenceKeyWeight*key + (1-sequenceKeyWeight)*tempo,
+	}
+}
+
+// sequenceOrder finds an order of tracks with few key clashes and tempo jumps: the best
+// of several greedy nearest-neighbour paths, improved by 2-opt (reversing stretches of
+// the path while that lowers the total cost). Costs are symmetric, so a reversed stretch
+// costs the same inside.
+func sequenceOrder(tracks []models.SpotifyTrack) []int {
+	n := len(tracks)
+	cost := make([][]float64, n)
+	for i := range tracks {
+		cost[i] = make([]float64, n)
+		for j := range tracks {
+			if i != j {
+				cost[i][j] = 1 - transition(&tracks[i], &tracks[j]).Score
+			}
+		}
+	}
+	pathCost := func(path []int) float64 {
+		total := 0.0
+		for i := 1; i < len(path); i++ {
+			total += cost[path[i-1]][path[i]]
+		}
+		return total
+	}
+
+	best := make([]int, n)
+	for i := range best {
+		best[i] = i // the current order
+	}
+	bestCost := pathCost(best)
+
+	step := max(1, n/sequenceGreedyStarts)
+	for start := 0; start < n; start += step {
+		path := []int{start}
+		used := make([]bool, n)
+		used[start] = true
+		for len(path) < n {
+			last, next := path[len(path)-1], -1
+			for j := 0; j < n; j++ {
+				if !used[j] && (next < 0 || cost[last][j] < cost[last][next]) {
+					next = j
+				}
+			}
+			path = append(path, next)
+			used[next] = true
+		}
+		if c := pathCost(path); c < bestCost {
+			best, bestCost = path, c
+		}
+	}
+
+	for pass := 0; pass < sequenceMaxPasses; pass++ {
+		improved := false
+		for i := 0; i < n-1; i++ {
+			for j := i + 1; j < n; j++ {
+				// Reversing best[i..j] replaces the edges into best[i] and out of best[j].
+				var before, after float64
+				if i > 0 {
+					before += cost[best[i-1]][best[i]]
+					after += cost[best[i-1]][best[j]]
+				}
+				if j < n-1 {
+					before += cost[best[j]][best[j+1]]
+					after += cost[best[i]][best[j+1]]
+				}
+				if after < before-1e-9 {
+					for l, r := i, j; l < r; l, r = l+1, r-1 {
+						best[l], best[r] = best[r], best[l]
+					}
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return best
+}
+
+func meanTransitionScore(transitions []models.Transition) float64 {
+	if len(transitions) == 0 {
+		return 1
+	}
+	total := 0.0
+	for _, t := range transitions {
+		total += t.Score
+	}
+	return total / float64(len(transitions))
+}
+
+func transitions(tracks []models.SpotifyTrack) []models.Transition {
+	result := make([]models.Transition, 0, max(0, len(tracks)-1))
+	for i := 1; i < len(tracks); i++ {
+		result = append(result, transition(&tracks[i-1], &tracks[i]))
+	}
+	return result
+}
+
+// modifiablePlaylist loads a playlist the user owns and may change.
+func (s *PlaylistService) modifiablePlaylist(ctx context.Context, userID int, playlistID int) (*models.Playlist, error) {
+	playlist, err := s.Repo.GetPlaylistByID(ctx, playlistID)
+	if err != nil {
+		log.Printf("Service: Error checking playlist: could not get playlist %d. Error: %v", playlistID, err)
+		return nil, errors.New("playlist not found")
+	}
+	if playlist.OwnerID != userID {
+		log.Printf("Service: User %d does not own playlist %d", userID, playlistID)
+		return nil, errors.New("forbidden: you do not own this playlist")
+	}
+	if !playlist.Modifyable {
+		log.Printf("Service: User %d cannot modify unmodifiable playlist %d", userID, playlistID)
+		return nil, errors.New("forbidden: this playlist is not modifiable")
+	}
+	return playlist, nil
+}
+
+// ProposeSequence suggests a DJ-style order for a playlist that minimizes key clashes on
+// the Camelot wheel and tempo jumps. Nothing is changed; see ApplySequence.
+func (s *PlaylistService) ProposeSequence(ctx context.Context, userID int, playlistID int) (*models.SequenceProposal, error) {
+	log.Printf("Service: User %d proposing a sequence for playlist %d", userID, playlistID)
+	if _, err := s.modifiablePlaylist(ctx, userID, playlistID); err != nil {
+		return nil, err
+	}
+
+	current, err := s.Repo.GetTracksInPlaylist(ctx, playlistID)
+	if err != nil {
+		log.Printf("Service: Error getting tracks of playlist %d: %v", playlistID, err)
+		return nil, err
+	}
+	if len(current) > MaxSequenceTracks {
+		return nil, errors.New("playlist is too long to sequence")
+	}
+
+	proposal := &models.SequenceProposal{
+		PlaylistID:   playlistID,
+		TrackIDs:     make([]string, len(current)),
+		Tracks:       make([]models.SpotifyTrackResponse, len(current)),
+		CurrentScore: meanTransitionScore(transitions(current)),
+	}
+	ordered := make([]models.SpotifyTrack, len(current))
+	for i, index := range sequenceOrder(current) {
+		ordered[i] = current[index]
+		proposal.TrackIDs[i] = current[index].TrackID
+		proposal.Tracks[i].SpotifyTrack = current[index]
+	}
+	proposal.Transitions = transitions(ordered)
+	proposal.Score = meanTransitionScore(proposal.Transitions)
+	log.Printf("Service: Proposed sequence for playlist %d scores %.3f (currently %.3f)", playlistID, proposal.Score, proposal.CurrentScore)
+	return proposal, nil
+}
+
+// ApplySequence reorders a playlist to trackIDs, normally a confirmed proposal. It fails
+// with "playlist changed" if trackIDs are not exactly the tracks in the playlist.
+func (s *PlaylistService) ApplySequence(ctx context.Context, userID int, playlistID int, trackIDs []string) error {
+	log.Printf("Service: User %d applying a sequence of %d tracks to playlist %d", userID, len(trackIDs), playlistID)
+	if _, err := s.modifiablePlaylist(ctx, userID, playlistID); err != nil {
+		return err
+	}
+
+	err := s.Repo.ReorderPlaylistTracks(ctx, playlistID, trackIDs)
+	if errors.Is(err, repository.ErrPlaylistChanged) {
+		return errors.New("playlist changed")
+	}
+	if err != nil {
+		log.Printf("Service: Error reordering playlist %d: %v", playlistID, err)
+	}
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/kiasoh/basic-spotify-backend/models"
 	"github.com/kiasoh/basic-spotify-backend/repository"
 )
@@ -12,10 +13,11 @@ import (
 type PlaylistService struct {
 	Repo               repository.PlaylistRepository
 	InteractionService *InteractionService // Add this field
+	ArtistService      *ArtistService
 }
 
-func NewPlaylistService(repo repository.PlaylistRepository, interactionService *InteractionService) *PlaylistService {
-	return &PlaylistService{Repo: repo, InteractionService: interactionService}
+func NewPlaylistService(repo repository.PlaylistRepository, interactionService *InteractionService, artistService *ArtistService) *PlaylistService {
+	return &PlaylistService{Repo: repo, InteractionService: interactionService, ArtistService: artistService}
 }
 
 func (s *PlaylistService) CreatePlaylist(ctx context.Context, ownerID int, name string, description *string) (*models.Playlist, error) {
@@ -37,6 +39,27 @@ func (s *PlaylistService) CreatePlaylist(ctx context.Context, ownerID int, name
 	return playlist, nil
 }
 
+// CreatePlaylistWithTracks creates a playlist holding trackIDs in order. If the tracks
+// cannot be added the playlist is removed again.
+func (s *PlaylistService) CreatePlaylistWithTracks(ctx context.Context, ownerID int, name string, description *string, trackIDs []string) (*models.Playlist, error) {
+	playlist, err := s.CreatePlaylist(ctx, ownerID, name, description)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.AddTracksToPlaylist(ctx, playlist.ID, trackIDs); err != nil {
+		log.Printf("Service: Error adding %d tracks to playlist %d: %v", len(trackIDs), playlist.ID, err)
+		if delErr := s.Repo.DeletePlaylist(ctx, playlist.ID); delErr != nil {
+			log.Printf("Service: Error removing incomplete playlist %d: %v", playlist.ID, delErr)
+		}
+		return nil, err
+	}
+	// Adding the tracks bumped the version.
+	if updated, err := s.Repo.GetPlaylistByID(ctx, playlist.ID); err == nil {
+		playlist = updated
+	}
+	return playlist, nil
+}
+
 func (s *PlaylistService) ListUserPlaylists(ctx context.Context, ownerID int) ([]models.Playlist, error) {
 	log.Printf("Service: User %d attempting to list their playlists", ownerID)
 	return s.Repo.ListPlaylistsByOwner(ctx, ownerID)
@@ -65,7 +88,12 @@ func (s *PlaylistService) AddTrackToPlaylist(ctx context.Context, userID, playli
 		return nil
 	}
 
-	return s.Repo.AddTrackToPlaylist(ctx, playlistID, trackID)
+	err = s.Repo.AddTrackToPlaylist(ctx, playlistID, trackID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("Service: Track %s does not exist or was removed from the catalog", trackID)
+		return errors.New("track not found")
+	}
+	return err
 }
 
 func (s *PlaylistService) RemoveTrackFromPlaylist(ctx context.Context, userID, playlistID int, trackID string) error {
@@ -124,7 +152,27 @@ func (s *PlaylistService) UpdatePlaylistDetails(ctx context.Context, userID int,
 	return playlist, nil
 }
 
+// GetPlaylist returns a playlist without its tracks.
+func (s *PlaylistService) GetPlaylist(ctx context.Context, playlistID int) (*models.Playlist, error) {
+	playlist, err := s.Repo.GetPlaylistByID(ctx, playlistID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.New("playlist not found")
+	}
+	return playlist, err
+}
+
 func (s *PlaylistService) GetTracksInPlaylist(ctx context.Context, playlistID int) ([]models.SpotifyTrack, error) {
 	log.Printf("Service: Attempting to get tracks for playlist %d", playlistID)
 	return s.Repo.GetTracksInPlaylist(ctx, playlistID)
 }
+
+func (s *PlaylistService) ListTracksInPlaylist(ctx context.Context, playlistID int, page models.PageRequest) (*models.Page[models.SpotifyTrack], error) {
+	log.Printf("Service: Listing tracks of playlist %d with limit %d and offset %d", playlistID, page.Limit, page.Offset)
+	return s.Repo.ListTracksInPlaylist(ctx, playlistID, page)
+}
+
+// GetTrackReasons returns why each of trackIDs was recommended into a playlist, for the
+// tracks the recommender added.
+func (s *PlaylistService) GetTrackReasons(ctx context.Context, playlistID int, trackIDs []string) (map[string]string, error) {
+	return s.Repo.GetTrackReasons(ctx, playlistID, trackIDs)
+}
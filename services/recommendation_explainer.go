@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+const (
+	// explainMinNeighborScore is how similar a recommended track must be to one of the
+	// user's favourites before "listeners who liked X also liked this" is used.
+	explainMinNeighborScore = 0.05
+	// explainTopGenres is how many of the user's most played genres count as played often.
+	explainTopGenres = 3
+	// explainMinGenreShare is the share of the user's preference a genre needs to count
+	// as played often.
+	explainMinGenreShare = 0.1
+	// explainPopularity is the popularity from which a track is called popular.
+	explainPopularity = 60
+	// explainFeatureTolerance is how close, in normalized feature space, a track must be
+	// to the user's taste in a feature to be called similar in it.
+	explainFeatureTolerance = 0.1
+	// explainFeatureContrast is how far the user's taste must be from the catalog's mean
+	// in a feature before matching it is worth mentioning.
+	explainFeatureContrast = 0.1
+)
+
+// explainRecommendations returns a short, user-facing reason for every track, from the
+// most specific evidence available: a favourite track that collaborative filtering links
+// it to, a genre the user plays often, audio features shared with the user's taste, and
+// otherwise its popularity. prefs holds the user's net preference per track as returned
+// by CollaborativeService.UserPreferences. Evidence that cannot be loaded is skipped, so
+// every track gets a reason even when the collaborative model is unavailable.
+func (s *RecommendationService) explainRecommendations(ctx context.Context, user *models.User, prefs map[string]float64, tracks []models.SpotifyTrack) map[string]string {
+	reasons := make(map[string]string, len(tracks))
+	if len(tracks) == 0 {
+		return reasons
+	}
+
+	seedFor, err := s.neighborSeeds(ctx, prefs, tracks)
+	if err != nil {
+		seedFor = nil
+	}
+	seedIDs := make([]string, 0, len(seedFor))
+	for _, seedID := range seedFor {
+		if !slices.Contains(seedIDs, seedID) {
+			seedIDs = append(seedIDs, seedID)
+		}
+	}
+	seeds, err := s.TrackRepo.GetByTrackIDs(ctx, seedIDs)
+	if err != nil {
+		log.Printf("Service: Error loading tracks to explain recommendations: %v", err)
+	}
+	seedNames := make(map[string]string, len(seeds))
+	for _, seed := range seeds {
+		seedNames[seed.TrackID] = seed.TrackName
+	}
+
+	topGenres, err := s.topGenres(ctx, prefs)
+	if err != nil {
+		topGenres = nil
+	}
+
+	for i := range tracks {
+		track := &tracks[i]
+		if name, ok := seedNames[seedFor[track.TrackID]]; ok {
+			reasons[track.TrackID] = fmt.Sprintf("listeners who liked %q also liked this", name)
+			continue
+		}
+		if slices.Contains(topGenres, track.TrackGenre) {
+			if track.Popularity >= explainPopularity {
+				reasons[track.TrackID] = fmt.Sprintf("popular in %s, which you play often", track.TrackGenre)
+			} else {
+				reasons[track.TrackID] = fmt.Sprintf("more %s, which you play often", track.TrackGenre)
+			}
+			continue
+		}
+		features, personalized := s.Similarity.tasteFeatures(user.AvgInterest, track, explainFeatureTolerance, explainFeatureContrast)
+		switch {
+		case len(features) >= 2:
+			reasons[track.TrackID] = fmt.Sprintf("similar %s and %s to tracks you liked", features[0], features[1])
+		case len(features) == 1:
+			reasons[track.TrackID] = fmt.Sprintf("similar %s to tracks you liked", features[0])
+		case personalized:
+			reasons[track.TrackID] = "close to your overall taste"
+		case track.Popularity >= explainPopularity:
+			reasons[track.TrackID] = "popular with listeners right now"
+		default:
+			reasons[track.TrackID] = "picked to broaden your taste"
+		}
+	}
+	return reasons
+}
+
+// neighborSeeds maps each track that is a strong enough collaborative neighbour of one of
+// the user's favourites to the favourite contributing most to it.
+func (s *RecommendationService) neighborSeeds(ctx context.Context, prefs map[string]float64, tracks []models.SpotifyTrack) (map[string]string, error) {
+	seedFor := make(map[string]string)
+	seeds := favourites(prefs, cfSeedTracks)
+	if len(seeds) == 0 {
+		return seedFor, nil
+	}
+	neighbors, err := s.Collaborative.Repo.GetNeighbors(ctx, seeds)
+	if err != nil {
+		log.Printf("Service: Error loading neighbors to explain recommendations: %v", err)
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(tracks))
+	for _, track := range tracks {
+		wanted[track.TrackID] = true
+	}
+	best := make(map[string]float64)
+	for _, n := range neighbors {
+		if !wanted[n.NeighborID] || n.Score < explainMinNeighborScore {
+			continue
+		}
+		if contribution := prefs[n.TrackID] * n.Score; contribution > best[n.NeighborID] {
+			best[n.NeighborID] = contribution
+			seedFor[n.NeighborID] = n.TrackID
+		}
+	}
+	return seedFor, nil
+}
+
+// topGenres returns the genres holding most of the user's positive preference, at most
+// explainTopGenres of them.
+func (s *RecommendationService) topGenres(ctx context.Context, prefs map[string]float64) ([]string, error) {
+	liked := favourites(prefs, len(prefs))
+	if len(liked) == 0 {
+		return nil, nil
+	}
+	trackGenres, err := s.TrackRepo.GetTrackGenres(ctx, liked)
+	if err != nil {
+		log.Printf("Service: Error loading genres to explain recommendations: %v", err)
+		return nil, err
+	}
+
+	total := 0.0
+	weights := make(map[string]float64)
+	for _, trackID := range liked {
+		total += prefs[trackID]
+		if genre, ok := trackGenres[trackID]; ok && genre != "" {
+			weights[genre] += prefs[trackID]
+		}
+	}
+	var genres []string
+	for genre, weight := range weights {
+		if weight/total >= explainMinGenreShare {
+			genres = append(genres, genre)
+		}
+	}
+	sort.Slice(genres, func(i, j int) bool {
+		if weights[genres[i]] != weights[genres[j]] {
+			return weights[genres[i]] > weights[genres[j]]
+		}
+		return genres[i] < genres[j]
+	})
+	if len(genres) > explainTopGenres {
+		genres = genres[:explainTopGenres]
+	}
+	return genres, nil
+}
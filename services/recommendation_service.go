@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kiasoh/basic-spotify-backend/events"
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+// hybridCFWeight is the share of a hybrid recommendation's score that comes from
+// collaborative filtering; the rest comes from the content-based taste match.
+const hybridCFWeight = 0.5
+
+// MaxRecommendations is the most recommendations a single request returns.
+const MaxRecommendations = 100
+
+type RecommendationService struct {
+	DB            *pgxpool.Pool
+	Repo          repository.RecommendationRepository
+	UserRepo      repository.UserRepository
+	TrackRepo     repository.SpotifyTrackRepository
+	PlaylistRepo  repository.PlaylistRepository
+	Genres        *GenreService
+	Similarity    *SimilarityService
+	Collaborative *CollaborativeService
+	Experiments   *ExperimentService
+}
+
+func NewRecommendationService(db *pgxpool.Pool, repo repository.RecommendationRepository, userRepo repository.UserRepository, trackRepo repository.SpotifyTrackRepository, playlistRepo repository.PlaylistRepository, genres *GenreService, similarity *SimilarityService, collaborative *CollaborativeService, experiments *ExperimentService) *RecommendationService {
+	return &RecommendationService{
+		DB:            db,
+		Repo:          repo,
+		UserRepo:      userRepo,
+		TrackRepo:     trackRepo,
+		PlaylistRepo:  playlistRepo,
+		Genres:        genres,
+		Similarity:    similarity,
+		Collaborative: collaborative,
+		Experiments:   experiments,
+	}
+}
+
+// HandleRecommendationMessage is the events.MessageHandler for the `recommendations` topic.
+// Messages that do not decode, name a user without a recommendation playlist or hold no
+// known live track are permanent failures; anything else, such as a database error, is
+// retried.
+func (s *RecommendationService) HandleRecommendationMessage(ctx context.Context, value []byte) error {
+	event, err := events.DecodeRecommendationEvent(value)
+	if err != nil {
+		return events.Permanent(err)
+	}
+	err = s.ApplyRecommendations(ctx, event.UserID, event.TrackIDs, event.ModelVersion)
+	if err != nil && (err.Error() == "user not found" || err.Error() == "user has no recommendation playlist" || err.Error() == "no valid tracks in recommendation") {
+		return events.Permanent(err)
+	}
+	return err
+}
+
+// ApplyRecommendations replaces the content of the user's recommendation playlist with
+// trackIDs (best first). Unknown and duplicate track IDs are dropped and the rest is
+// spread across genre families. Every track is stored with a reason explaining the pick;
+// if reasons cannot be worked out the tracks are stored without. The playlist swap and
+// the record of the producing model version happen in one transaction.
+func (s *RecommendationService) ApplyRecommendations(ctx context.Context, userID int, trackIDs []string, modelVersion string) error {
+	log.Printf("Service: Applying %d recommendations from model '%s' for user %d", len(trackIDs), modelVersion, userID)
+
+	user, err := s.UserRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("Service: Error getting user %d for recommendations: %v", userID, err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errors.New("user not found")
+		}
+		return err
+	}
+
+	// --- Start Transaction ---
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		log.Printf("Failed to begin transaction: %v", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := s.applyRecommendationsInTx(ctx, tx, user, trackIDs, modelVersion); err != nil {
+		return err
+	}
+
+	// --- Commit Transaction ---
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Failed to commit recommendations for user %d: %v", userID, err)
+		return err
+	}
+	return nil
+}
+
+// applyRecommendationsInTx is ApplyRecommendations within tx, for callers that change
+// more of the user in the same transaction. It returns how many tracks were stored.
+func (s *RecommendationService) applyRecommendationsInTx(ctx context.Context, tx pgx.Tx, user *models.User, trackIDs []string, modelVersion string) (int, error) {
+	if user.RecommPlaylistID == 0 {
+		log.Printf("Service: User %d has no recommendation playlist", user.ID)
+		return 0, errors.New("user has no recommendation playlist")
+	}
+
+	genres, err := s.TrackRepo.GetTrackGenres(ctx, trackIDs)
+	if err != nil {
+		log.Printf("Service: Error validating recommended tracks for user %d: %v", user.ID, err)
+		return 0, err
+	}
+	validTrackIDs := make([]string, 0, len(trackIDs))
+	seen := make(map[string]bool, len(trackIDs))
+	for _, trackID := range trackIDs {
+		if _, ok := genres[trackID]; !ok || seen[trackID] {
+			continue
+		}
+		seen[trackID] = true
+		validTrackIDs = append(validTrackIDs, trackID)
+	}
+	if dropped := len(trackIDs) - len(validTrackIDs); dropped > 0 {
+		log.Printf("Service: Dropped %d unknown or duplicate recommended tracks for user %d", dropped, user.ID)
+	}
+	if len(validTrackIDs) == 0 {
+		return 0, errors.New("no valid tracks in recommendation")
+	}
+	validTrackIDs = s.Genres.DiversifyByGenre(validTrackIDs, genres)
+
+	reasons, err := s.explainTracks(ctx, user, validTrackIDs)
+	if err != nil {
+		log.Printf("Service: Storing recommendations for user %d without reasons: %v", user.ID, err)
+	}
+
+	if err := s.PlaylistRepo.ReplacePlaylistTracksInTx(ctx, tx, user.RecommPlaylistID, validTrackIDs, reasons); err != nil {
+		log.Printf("Service: Failed to replace recommendation playlist %d: %v", user.RecommPlaylistID, err)
+		return 0, err
+	}
+
+	run := &models.RecommendationRun{
+		UserID:       user.ID,
+		PlaylistID:   user.RecommPlaylistID,
+		ModelVersion: modelVersion,
+		TrackCount:   len(validTrackIDs),
+	}
+	if _, err := s.Repo.CreateRunInTx(ctx, tx, run); err != nil {
+		log.Printf("Service: Failed to record recommendation run for user %d: %v", user.ID, err)
+		return 0, err
+	}
+
+	log.Printf("Service: Recommendation playlist %d of user %d now holds %d tracks from model '%s'", user.RecommPlaylistID, user.ID, len(validTrackIDs), modelVersion)
+	return len(validTrackIDs), nil
+}
+
+// explainTracks is explainRecommendations for track IDs. Without the user's preferences
+// the reasons fall back to their taste and the tracks' popularity.
+func (s *RecommendationService) explainTracks(ctx context.Context, user *models.User, trackIDs []string) (map[string]string, error) {
+	prefs, err := s.Collaborative.UserPreferences(ctx, user.ID)
+	if err != nil {
+		log.Printf("Service: Explaining recommendations for user %d without their preferences: %v", user.ID, err)
+		prefs = nil
+	}
+	tracks, err := s.TrackRepo.GetByTrackIDs(ctx, trackIDs)
+	if err != nil {
+		return nil, err
+	}
+	return s.explainRecommendations(ctx, user, prefs, tracks), nil
+}
+
+// Recommend returns up to limit tracks for a user that they have not interacted with yet.
+// strategy is models.StrategyCF (tracks liked by users with similar likes),
+// models.StrategyContent (tracks close to the user's avg_interest) or
+// models.StrategyHybrid (both, with collaborative scores scaled to the best one and
+// blended by hybridCFWeight). Hybrid falls back to content alone while the user has no
+// collaborative neighbours. An empty strategy means the user's variant's strategy if an
+// experiment varies strategies, and hybrid otherwise. Every recommendation carries a
+// reason.
+func (s *RecommendationService) Recommend(ctx context.Context, userID int, strategy string, limit int) (*models.RecommendationList, error) {
+	if strategy == "" {
+		strategy = models.StrategyHybrid
+		if s.Experiments != nil {
+			strategy = s.Experiments.RecommendationStrategy(ctx, userID, strategy)
+		}
+	}
+	if strategy != models.StrategyCF && strategy != models.StrategyContent && strategy != models.StrategyHybrid {
+		return nil, errors.New("invalid strategy")
+	}
+	log.Printf("Service: Recommending %d tracks to user %d with strategy '%s'", limit, userID, strategy)
+
+	user, err := s.UserRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("Service: Error getting user %d for recommendations: %v", userID, err)
+		return nil, errors.New("user not found")
+	}
+	prefs, err := s.Collaborative.UserPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := limit
+	if strategy == models.StrategyHybrid {
+		candidates = 2 * limit // the two lists overlap only partly
+	}
+	var cf, content []scoredTrack
+	if strategy != models.StrategyContent {
+		if cf, err = s.Collaborative.recommend(ctx, prefs, candidates); err != nil {
+			return nil, err
+		}
+	}
+	if strategy != models.StrategyCF {
+		interacted := make([]string, 0, len(prefs))
+		for trackID := range prefs {
+			interacted = append(interacted, trackID)
+		}
+		if content, err = s.Similarity.rankTaste(user.AvgInterest, models.TasteMatchOptions{Limit: candidates, ExcludeTrackIDs: interacted}); err != nil {
+			return nil, err
+		}
+	}
+
+	order, scores := blendRecommendations(strategy, cf, content, limit)
+
+	tracks, err := s.TrackRepo.GetByTrackIDs(ctx, order)
+	if err != nil {
+		log.Printf("Service: Error loading recommended tracks: %v", err)
+		return nil, err
+	}
+	reasons := s.explainRecommendations(ctx, user, prefs, tracks)
+	list := &models.RecommendationList{Strategy: strategy, Recommendations: make([]models.Recommendation, len(tracks))}
+	for i, track := range tracks {
+		b := scores[track.TrackID]
+		list.Recommendations[i] = models.Recommendation{Track: models.SpotifyTrackResponse{SpotifyTrack: track}, Score: b.score, Source: b.source, Reason: reasons[track.TrackID]}
+	}
+	return list, nil
+}
+
+// blendedScore is a recommendation's score and the strategy that found it, or "both".
+type blendedScore struct {
+	score  float64
+	source string
+}
+
+// blendRecommendations merges collaborative and content candidates as strategy says and
+// returns the best limit track IDs, best first, with their scores. For hybrid the
+// collaborative scores are scaled to the best one and weighed by hybridCFWeight.
+func blendRecommendations(strategy string, cf, content []scoredTrack, limit int) ([]string, map[string]*blendedScore) {
+	scores := make(map[string]*blendedScore)
+	var order []string
+	add := func(tracks []scoredTrack, weight float64, source string) {
+		for _, t := range tracks {
+			b, ok := scores[t.trackID]
+			if !ok {
+				b = &blendedScore{source: source}
+				scores[t.trackID] = b
+				order = append(order, t.trackID)
+			} else if b.source != source {
+				b.source = "both"
+			}
+			b.score += weight * t.score
+		}
+	}
+	switch {
+	case strategy == models.StrategyHybrid && len(cf) > 0:
+		add(cf, hybridCFWeight/cf[0].score, models.StrategyCF)
+		add(content, 1-hybridCFWeight, models.StrategyContent)
+	default:
+		add(cf, 1, models.StrategyCF)
+		add(content, 1, models.StrategyContent)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]].score > scores[order[j]].score })
+	if len(order) > limit {
+		order = order[:limit]
+	}
+	return order, scores
+}
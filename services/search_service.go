@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+const (
+	// searchPopularityWeight is the share of a hit's score that comes from popularity;
+	// the rest is text similarity.
+	searchPopularityWeight = 0.2
+	// highlightSimilarity is the trigram similarity a word needs to a query word to be
+	// highlighted, so misspelt query words still mark the word they matched.
+	highlightSimilarity  = 0.4
+	maxSearchQueryLength = 100
+)
+
+type SearchService struct {
+	Repo repository.SearchRepository
+}
+
+func NewSearchService(repo repository.SearchRepository) *SearchService {
+	return &SearchService{Repo: repo}
+}
+
+// Search looks query up in track names, artists and albums at once and returns up to
+// limit hits of each kind, with the matched spans of every hit highlighted.
+func (s *SearchService) Search(ctx context.Context, query string, limit int) (*models.SearchResults, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New("query is required")
+	}
+	if len([]rune(query)) > maxSearchQueryLength {
+		return nil, errors.New("query is too long")
+	}
+	log.Printf("Service: Searching for '%s' with limit %d", query, limit)
+
+	tracks, err := s.Repo.SearchTracks(ctx, query, searchPopularityWeight, limit)
+	if err != nil {
+		log.Printf("Service: Error searching tracks: %v", err)
+		return nil, err
+	}
+	artists, err := s.Repo.SearchArtists(ctx, query, searchPopularityWeight, limit)
+	if err != nil {
+		log.Printf("Service: Error searching artists: %v", err)
+		return nil, err
+	}
+	albums, err := s.Repo.SearchAlbums(ctx, query, searchPopularityWeight, limit)
+	if err != nil {
+		log.Printf("Service: Error searching albums: %v", err)
+		return nil, err
+	}
+
+	queryWords := searchWords(query)
+	for i := range tracks {
+		track := tracks[i].Track.SpotifyTrack
+		tracks[i].Highlights = make(map[string][]models.HighlightSpan)
+		for field, text := range map[string]string{"track_name": track.TrackName, "artists": track.Artists, "album_name": track.AlbumName} {
+			if spans := highlight(text, queryWords); len(spans) > 0 {
+				tracks[i].Highlights[field] = spans
+			}
+		}
+	}
+	for i := range artists {
+		artists[i].Highlights = highlight(artists[i].Artist.Name, queryWords)
+	}
+	for i := range albums {
+		albums[i].Highlights = highlight(albums[i].Album.Name, queryWords)
+	}
+
+	return &models.SearchResults{Query: query, Tracks: tracks, Artists: artists, Albums: albums}, nil
+}
+
+// searchWord is a word of a text with its character offsets.
+type searchWord struct {
+	text       string
+	start, end int
+}
+
+// searchWords splits text into lower-cased words of letters and digits.
+func searchWords(text string) []searchWord {
+	var words []searchWord
+	var current []rune
+	start := 0
+	flush := func(end int) {
+		if len(current) > 0 {
+			words = append(words, searchWord{text: strings.ToLower(string(current)), start: start, end: end})
+			current = current[:0]
+		}
+	}
+
+	i := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if len(current) == 0 {
+				start = i
+			}
+			current = append(current, r)
+		} else {
+			flush(i)
+		}
+		i++
+	}
+	flush(i)
+	return words
+}
+
+// highlight returns the spans of the words of text that match a query word: words
+// that contain it, or are similar enough to it to be a misspelling.
+func highlight(text string, queryWords []searchWord) []models.HighlightSpan {
+	var spans []models.HighlightSpan
+	for _, word := range searchWords(text) {
+		for _, q := range queryWords {
+			if strings.Contains(word.text, q.text) || trigramSimilarity(word.text, q.text) >= highlightSimilarity {
+				spans = append(spans, models.HighlightSpan{Start: word.start, End: word.end})
+				break
+			}
+		}
+	}
+	return spans
+}
+
+// trigramSimilarity mirrors pg_trgm's similarity() for a single word: the share of
+// trigrams the two words have in common, with the word padded as pg_trgm does.
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(word string) map[string]bool {
+	padded := []rune("  " + word + " ")
+	set := make(map[string]bool)
+	for i := 0; i+3 <= len(padded); i++ {
+		set[string(padded[i:i+3])] = true
+	}
+	return set
+}
@@ -0,0 +1,408 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+const (
+	// MaxSimilarTracks is the most neighbours a single lookup returns.
+	MaxSimilarTracks = 50
+	// tastePopularityWeight is the share of a taste match's score that comes from
+	// popularity; the rest is closeness to the user's taste.
+	tastePopularityWeight = 0.2
+	// maxTracksPerArtist is how many tracks of one artist a taste match may contain.
+	maxTracksPerArtist = 2
+)
+
+// featureCount is the number of audio features in a feature vector, in the order of
+// models.AudioFeatures and of avg_interest.
+const featureCount = 9
+
+type featureVector [featureCount]float64
+
+func trackFeatures(track *models.SpotifyTrack) featureVector {
+	return featureVector{track.Danceability, track.Energy, track.Loudness, track.Speechiness, track.Acousticness, track.Instrumentalness, track.Liveness, track.Valence, track.Tempo}
+}
+
+// similarityEntry is a track as the similarity index sees it.
+type similarityEntry struct {
+	trackID    string
+	genre      string
+	artists    []string // lower-cased
+	song       string   // lower-cased name and artists, shared by re-releases of a song
+	popularity int64
+	durationMs int64
+	raw        featureVector
+	vector     featureVector // normalized
+}
+
+// similarityIndex is an immutable index of the catalog in normalized feature space.
+// Features are min-max scaled to 0..1 over the catalog so that loudness and tempo do not
+// outweigh the features that already lie in 0..1. Lookups scan every entry, which for
+// catalogs of this size is fast enough and exact.
+type similarityIndex struct {
+	entries        []similarityEntry
+	min, max, mean featureVector // of the raw features
+}
+
+func splitArtists(artists string) []string {
+	var names []string
+	for _, name := range strings.Split(artists, ";") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func songKey(track *models.SpotifyTrack) string {
+	return strings.ToLower(track.TrackName + "\x00" + track.Artists)
+}
+
+func buildSimilarityIndex(tracks []models.SpotifyTrack) *similarityIndex {
+	idx := &similarityIndex{entries: make([]similarityEntry, len(tracks))}
+	for f := range idx.min {
+		idx.min[f], idx.max[f] = math.Inf(1), math.Inf(-1)
+	}
+	for i := range tracks {
+		raw := trackFeatures(&tracks[i])
+		for f, value := range raw {
+			idx.min[f] = math.Min(idx.min[f], value)
+			idx.max[f] = math.Max(idx.max[f], value)
+			idx.mean[f] += value / float64(len(tracks))
+		}
+		idx.entries[i] = similarityEntry{
+			trackID:    tracks[i].TrackID,
+			genre:      tracks[i].TrackGenre,
+			artists:    splitArtists(tracks[i].Artists),
+			song:       songKey(&tracks[i]),
+			popularity: tracks[i].Popularity,
+			durationMs: tracks[i].DurationMs,
+			raw:        raw,
+		}
+	}
+	for i := range idx.entries {
+		idx.entries[i].vector = idx.normalize(idx.entries[i].raw)
+	}
+	return idx
+}
+
+// normalize scales raw features onto the catalog's range. Values outside it, from tracks
+// added since the index was built, are clamped.
+func (idx *similarityIndex) normalize(raw featureVector) featureVector {
+	var vector featureVector
+	for f, value := range raw {
+		if span := idx.max[f] - idx.min[f]; span > 0 {
+			vector[f] = math.Max(0, math.Min(1, (value-idx.min[f])/span))
+		}
+	}
+	return vector
+}
+
+// tastePoint turns a user's avg_interest into the point in normalized feature space the
+// user's taste is centred on. avg_interest is a moving average of track features
+// multiplied by interaction weights, so it is the taste point scaled by an unknown
+// factor; the factor is estimated by projecting avg_interest onto the catalog's mean
+// track (both scaled per feature by the catalog's range). It reports false when there
+// is no usable taste: no interactions yet, or mostly negative ones.
+func (idx *similarityIndex) tastePoint(avgInterest []float64) (featureVector, bool) {
+	if len(avgInterest) != featureCount {
+		return featureVector{}, false
+	}
+	var dot, norm float64
+	for f := range idx.mean {
+		span := idx.max[f] - idx.min[f]
+		if span <= 0 {
+			continue
+		}
+		dot += avgInterest[f] / span * idx.mean[f] / span
+		norm += idx.mean[f] / span * idx.mean[f] / span
+	}
+	if norm == 0 || dot <= 0 {
+		return featureVector{}, false
+	}
+	scale := dot / norm
+
+	var raw featureVector
+	for f := range raw {
+		raw[f] = avgInterest[f] / scale
+	}
+	return idx.normalize(raw), true
+}
+
+func (idx *similarityIndex) inRanges(entry *similarityEntry, ranges map[string]models.Range) bool {
+	for f, feature := range models.AudioFeatures {
+		if r, ok := ranges[feature]; ok && !r.Contains(entry.raw[f]) {
+			return false
+		}
+	}
+	return true
+}
+
+func squaredDistance(a, b featureVector) float64 {
+	var squared float64
+	for f := range a {
+		d := a[f] - b[f]
+		squared += d * d
+	}
+	return squared
+}
+
+type similarityMatch struct {
+	entry *similarityEntry
+	score float64
+}
+
+// top returns the limit entries with the highest score that keep accepts, best first.
+// Of several releases of the same song only the best is returned.
+func (idx *similarityIndex) top(limit int, score func(*similarityEntry) float64, keep func(*similarityEntry) bool) []similarityMatch {
+	best := make([]similarityMatch, 0, limit+1)
+	for i := range idx.entries {
+		entry := &idx.entries[i]
+		value := score(entry)
+		if len(best) == limit && value <= best[limit-1].score {
+			continue
+		}
+		if !keep(entry) {
+			continue
+		}
+		if dup := slices.IndexFunc(best, func(m similarityMatch) bool { return m.entry.song == entry.song }); dup >= 0 {
+			if best[dup].score >= value {
+				continue
+			}
+			best = slices.Delete(best, dup, dup+1)
+		}
+		pos := sort.Search(len(best), func(j int) bool { return value > best[j].score })
+		best = slices.Insert(best, pos, similarityMatch{entry: entry, score: value})
+		if len(best) > limit {
+			best = best[:limit]
+		}
+	}
+	return best
+}
+
+// SimilarityService answers "more like this" lookups from an in-memory index of the
+// catalog's audio features, rebuilt in the background after the catalog changes.
+type SimilarityService struct {
+	catalogRefresher
+	TrackRepo repository.SpotifyTrackRepository
+	index     atomic.Pointer[similarityIndex]
+}
+
+func NewSimilarityService(trackRepo repository.SpotifyTrackRepository) *SimilarityService {
+	return &SimilarityService{TrackRepo: trackRepo}
+}
+
+// Refresh rebuilds the index from the catalog.
+func (s *SimilarityService) Refresh(ctx context.Context) error {
+	start := time.Now()
+	tracks, err := s.TrackRepo.ListAll(ctx)
+	if err != nil {
+		log.Printf("Service: Error loading tracks for similarity index: %v", err)
+		return err
+	}
+	s.index.Store(buildSimilarityIndex(tracks))
+	log.Printf("Service: Similarity index rebuilt with %d tracks in %s", len(tracks), time.Since(start))
+	return nil
+}
+
+// Run builds the index and then rebuilds it whenever the catalog changed, until ctx is done.
+func (s *SimilarityService) Run(ctx context.Context) {
+	s.run(ctx, "similarity index", s.Refresh)
+}
+
+// SimilarTracks returns the tracks closest to trackID in audio-feature space. The seed
+// track itself and other releases of the same song are never returned.
+func (s *SimilarityService) SimilarTracks(ctx context.Context, trackID string, opts models.SimilarityOptions) ([]models.SimilarTrack, error) {
+	idx := s.index.Load()
+	if idx == nil {
+		return nil, errors.New("similarity index is not ready")
+	}
+
+	// Look the seed up in the database, so tracks added or removed since the last
+	// rebuild are handled correctly.
+	seed, err := s.TrackRepo.GetByTrackID(ctx, trackID)
+	if err != nil {
+		log.Printf("Service: Error getting track %s for similarity: %v", trackID, err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("track not found")
+		}
+		return nil, err
+	}
+	seedSong := songKey(seed)
+	seedArtists := splitArtists(seed.Artists)
+	seedVector := idx.normalize(trackFeatures(seed))
+
+	closeness := func(entry *similarityEntry) float64 { return -squaredDistance(entry.vector, seedVector) }
+	matches := idx.top(opts.Limit, closeness, func(entry *similarityEntry) bool {
+		if entry.trackID == seed.TrackID || entry.song == seedSong {
+			return false
+		}
+		if opts.SameGenre && entry.genre != seed.TrackGenre {
+			return false
+		}
+		if opts.ExcludeArtist && slices.ContainsFunc(entry.artists, func(a string) bool { return slices.Contains(seedArtists, a) }) {
+			return false
+		}
+		return true
+	})
+
+	trackIDs := make([]string, len(matches))
+	distances := make(map[string]float64, len(matches))
+	for i, match := range matches {
+		trackIDs[i] = match.entry.trackID
+		distances[match.entry.trackID] = math.Sqrt(-match.score)
+	}
+	// Tracks deleted since the last rebuild are dropped here.
+	tracks, err := s.TrackRepo.GetByTrackIDs(ctx, trackIDs)
+	if err != nil {
+		log.Printf("Service: Error loading similar tracks: %v", err)
+		return nil, err
+	}
+
+	maxDistance := math.Sqrt(featureCount)
+	similar := make([]models.SimilarTrack, len(tracks))
+	for i, track := range tracks {
+		distance := distances[track.TrackID]
+		similar[i] = models.SimilarTrack{
+			Track:      models.SpotifyTrackResponse{SpotifyTrack: track},
+			Distance:   distance,
+			Similarity: 1 - distance/maxDistance,
+		}
+	}
+	return similar, nil
+}
+
+// MatchTaste returns up to opts.Limit tracks matching opts, ranked by closeness to the
+// taste described by avgInterest blended with popularity, with at most
+// maxTracksPerArtist tracks per artist. Without a usable taste the most popular tracks
+// are returned.
+func (s *SimilarityService) MatchTaste(ctx context.Context, avgInterest []float64, opts models.TasteMatchOptions) ([]models.SpotifyTrack, error) {
+	matches, err := s.rankTaste(avgInterest, opts)
+	if err != nil {
+		return nil, err
+	}
+	trackIDs := make([]string, len(matches))
+	for i, match := range matches {
+		trackIDs[i] = match.trackID
+	}
+	tracks, err := s.TrackRepo.GetByTrackIDs(ctx, trackIDs)
+	if err != nil {
+		log.Printf("Service: Error loading taste matches: %v", err)
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// rankTaste is MatchTaste without loading the tracks; scores range from 0 to 1.
+func (s *SimilarityService) rankTaste(avgInterest []float64, opts models.TasteMatchOptions) ([]scoredTrack, error) {
+	idx := s.index.Load()
+	if idx == nil {
+		return nil, errors.New("similarity index is not ready")
+	}
+	ranked, personalized := idx.rankTaste(avgInterest, opts)
+	log.Printf("Service: Matched %d tracks to taste (personalized: %t)", len(ranked), personalized)
+	return ranked, nil
+}
+
+// rankTaste reports whether the ranking is personalized, which it is unless the user has
+// no usable taste yet.
+func (idx *similarityIndex) rankTaste(avgInterest []float64, opts models.TasteMatchOptions) ([]scoredTrack, bool) {
+	taste, personalized := idx.tastePoint(avgInterest)
+	maxDistance := math.Sqrt(featureCount)
+	score := func(entry *similarityEntry) float64 {
+		popularity := float64(entry.popularity) / 100
+		if !personalized {
+			return popularity
+		}
+		closeness := 1 - math.Sqrt(squaredDistance(entry.vector, taste))/maxDistance
+		return (1-tastePopularityWeight)*closeness + tastePopularityWeight*popularity
+	}
+
+	excludedSongs := make(map[string]bool)
+	if len(opts.ExcludeTrackIDs) > 0 {
+		excluded := make(map[string]bool, len(opts.ExcludeTrackIDs))
+		for _, id := range opts.ExcludeTrackIDs {
+			excluded[id] = true
+		}
+		for i := range idx.entries {
+			if excluded[idx.entries[i].trackID] {
+				excludedSongs[idx.entries[i].song] = true
+			}
+		}
+	}
+	keep := func(entry *similarityEntry) bool {
+		if len(opts.Genres) > 0 && !slices.Contains(opts.Genres, entry.genre) {
+			return false
+		}
+		return !excludedSongs[entry.song] && idx.inRanges(entry, opts.Ranges)
+	}
+
+	// Take more candidates than needed so that dropping repeated artists still fills the list.
+	perArtist := make(map[string]int)
+	var ranked []scoredTrack
+	for _, match := range idx.top(opts.Limit*(maxTracksPerArtist+1), score, keep) {
+		if len(ranked) == opts.Limit {
+			break
+		}
+		if lead := leadArtist(match.entry); lead != "" {
+			if perArtist[lead] == maxTracksPerArtist {
+				continue
+			}
+			perArtist[lead]++
+		}
+		ranked = append(ranked, scoredTrack{trackID: match.entry.trackID, score: match.score})
+	}
+	return ranked, personalized
+}
+
+// tasteFeatures returns the audio features in which a track matches the user's taste,
+// most telling first: features where the track lies within tolerance of the taste point
+// while the taste lies at least contrast away from the catalog's mean track, so that
+// matching it says something about the user. It reports false when the user has no
+// usable taste or the index is not built yet.
+func (s *SimilarityService) tasteFeatures(avgInterest []float64, track *models.SpotifyTrack, tolerance, contrast float64) ([]string, bool) {
+	idx := s.index.Load()
+	if idx == nil {
+		return nil, false
+	}
+	taste, ok := idx.tastePoint(avgInterest)
+	if !ok {
+		return nil, false
+	}
+	vector := idx.normalize(trackFeatures(track))
+	mean := idx.normalize(idx.mean)
+
+	type match struct {
+		feature  string
+		contrast float64
+	}
+	var matches []match
+	for f, feature := range models.AudioFeatures {
+		if math.Abs(vector[f]-taste[f]) > tolerance {
+			continue
+		}
+		if c := math.Abs(taste[f] - mean[f]); c >= contrast {
+			matches = append(matches, match{feature: feature, contrast: c})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].contrast > matches[j].contrast })
+
+	features := make([]string, len(matches))
+	for i, m := range matches {
+		features[i] = m.feature
+	}
+	return features, true
+}
@@ -11,10 +11,11 @@ import (
 type SpotifyTrackService struct {
 	Repo             repository.SpotifyTrackRepository
 	InteractionService *InteractionService // Add this field
+	ArtistService      *ArtistService
 }
 
-func NewSpotifyTrackService(repo repository.SpotifyTrackRepository, interactionService *InteractionService) *SpotifyTrackService {
-	return &SpotifyTrackService{Repo: repo, InteractionService: interactionService}
+func NewSpotifyTrackService(repo repository.SpotifyTrackRepository, interactionService *InteractionService, artistService *ArtistService) *SpotifyTrackService {
+	return &SpotifyTrackService{Repo: repo, InteractionService: interactionService, ArtistService: artistService}
 }
 
 func (s *SpotifyTrackService) GetByTrackID(ctx context.Context, trackID string) (*models.SpotifyTrack, error) {
@@ -27,9 +28,9 @@ func (s *SpotifyTrackService) GetByTrackID(ctx context.Context, trackID string)
 	return track, nil
 }
 
-func (s *SpotifyTrackService) List(ctx context.Context, limit int, offset int, sortBy string, order string) ([]models.SpotifyTrack, error) {
-	log.Printf("Service: Attempting to list tracks with limit %d, offset %d, and sort by %s", limit, offset, sortBy)
-	tracks, err := s.Repo.List(ctx, limit, offset, sortBy, order)
+func (s *SpotifyTrackService) List(ctx context.Context, filter models.TrackFilter, page models.PageRequest, sortBy string, order string) (*models.Page[models.SpotifyTrack], error) {
+	log.Printf("Service: Attempting to list tracks with limit %d, offset %d, and sort by %s", page.Limit, page.Offset, sortBy)
+	tracks, err := s.Repo.List(ctx, filter, page, sortBy, order)
 	if err != nil {
 		log.Printf("Service: Error listing tracks: %v", err)
 		return nil, err
@@ -37,9 +38,9 @@ func (s *SpotifyTrackService) List(ctx context.Context, limit int, offset int, s
 	return tracks, nil
 }
 
-func (s *SpotifyTrackService) Search(ctx context.Context, query string, searchField string, limit int, offset int) ([]models.SpotifyTrack, error) {
-	log.Printf("Service: Attempting to search tracks for query '%s' in field '%s' with limit %d and offset %d", query, searchField, limit, offset)
-	tracks, err := s.Repo.Search(ctx, query, searchField, limit, offset)
+func (s *SpotifyTrackService) Search(ctx context.Context, query string, searchField string, page models.PageRequest) (*models.Page[models.SpotifyTrack], error) {
+	log.Printf("Service: Attempting to search tracks for query '%s' in field '%s' with limit %d and offset %d", query, searchField, page.Limit, page.Offset)
+	tracks, err := s.Repo.Search(ctx, query, searchField, page)
 	if err != nil {
 		log.Printf("Service: Error searching tracks: %v", err)
 		return nil, err
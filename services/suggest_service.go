@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+	"github.com/kiasoh/basic-spotify-backend/repository"
+)
+
+const (
+	// MaxSuggestions is the most completions a single lookup returns.
+	MaxSuggestions = 20
+	// suggestCachedPrefixLength is the longest prefix whose completions are precomputed.
+	// Shorter prefixes match too much of the catalog to be ranked per request.
+	suggestCachedPrefixLength = 2
+	// suggestFuzzyTimeout bounds the database fallback for prefixes without completions.
+	suggestFuzzyTimeout = 75 * time.Millisecond
+)
+
+// suggestItem is a suggestion with its text normalized for matching.
+type suggestItem struct {
+	models.Suggestion
+	normalized string
+}
+
+// suggestEntry is a key under which a suggestion can be found: the normalized text
+// starting at one of its words, so "crazy in love" is found by "crazy", "in" and "love".
+type suggestEntry struct {
+	key  string
+	item *suggestItem
+}
+
+// suggestIndex is an immutable prefix index over the catalog's track and artist names.
+type suggestIndex struct {
+	entries []suggestEntry // sorted by key
+	top     map[string][]*suggestItem
+}
+
+func normalizeSuggestText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+func buildSuggestIndex(suggestions []models.Suggestion) *suggestIndex {
+	idx := &suggestIndex{top: make(map[string][]*suggestItem)}
+	for _, suggestion := range suggestions {
+		item := &suggestItem{Suggestion: suggestion, normalized: normalizeSuggestText(suggestion.Text)}
+		text := item.normalized
+		for start := 0; start < len(text); {
+			idx.entries = append(idx.entries, suggestEntry{key: text[start:], item: item})
+			next := strings.IndexByte(text[start:], ' ')
+			if next < 0 {
+				break
+			}
+			start += next + 1
+		}
+	}
+	sort.Slice(idx.entries, func(i, j int) bool { return idx.entries[i].key < idx.entries[j].key })
+
+	// Precompute the completions of every short prefix that occurs.
+	for _, entry := range idx.entries {
+		runes := []rune(entry.key)
+		for n := 1; n <= suggestCachedPrefixLength && n <= len(runes); n++ {
+			prefix := string(runes[:n])
+			if _, ok := idx.top[prefix]; !ok {
+				idx.top[prefix] = idx.scan(prefix, MaxSuggestions)
+			}
+		}
+	}
+	return idx
+}
+
+// scan returns the limit best suggestions with a word starting with prefix: those whose
+// whole text starts with it first, then by popularity, then shorter texts. Prefix ranges
+// can be large, so only the current best limit are kept sorted while scanning.
+func (idx *suggestIndex) scan(prefix string, limit int) []*suggestItem {
+	better := func(a, b *suggestItem) bool {
+		aStarts, bStarts := strings.HasPrefix(a.normalized, prefix), strings.HasPrefix(b.normalized, prefix)
+		if aStarts != bStarts {
+			return aStarts
+		}
+		if a.Popularity != b.Popularity {
+			return a.Popularity > b.Popularity
+		}
+		return len(a.Text) < len(b.Text)
+	}
+
+	lo := sort.Search(len(idx.entries), func(i int) bool { return idx.entries[i].key >= prefix })
+	best := make([]*suggestItem, 0, limit+1)
+	for i := lo; i < len(idx.entries) && strings.HasPrefix(idx.entries[i].key, prefix); i++ {
+		item := idx.entries[i].item
+		if len(best) == limit && !better(item, best[limit-1]) {
+			continue
+		}
+		if slices.Contains(best, item) {
+			continue // matched through another of its words
+		}
+		pos := sort.Search(len(best), func(j int) bool { return better(item, best[j]) })
+		best = slices.Insert(best, pos, item)
+		if len(best) > limit {
+			best = best[:limit]
+		}
+	}
+	return best
+}
+
+func (idx *suggestIndex) lookup(prefix string, limit int) []models.Suggestion {
+	matches, ok := idx.top[prefix]
+	if !ok && len([]rune(prefix)) > suggestCachedPrefixLength {
+		matches = idx.scan(prefix, limit)
+	}
+	suggestions := make([]models.Suggestion, 0, limit)
+	for _, match := range matches {
+		if len(suggestions) == limit {
+			break
+		}
+		suggestions = append(suggestions, match.Suggestion)
+	}
+	return suggestions
+}
+
+// SuggestService serves search box completions from an in-memory prefix index of the
+// catalog. The index is rebuilt in the background after the catalog changes; until the
+// first build finishes, and for prefixes without completions, it falls back to a fuzzy
+// database lookup with a tight timeout.
+type SuggestService struct {
+	catalogRefresher
+	Repo  repository.SearchRepository
+	index atomic.Pointer[suggestIndex]
+}
+
+func NewSuggestService(repo repository.SearchRepository) *SuggestService {
+	return &SuggestService{Repo: repo}
+}
+
+// Refresh rebuilds the index from the catalog.
+func (s *SuggestService) Refresh(ctx context.Context) error {
+	start := time.Now()
+	suggestions, err := s.Repo.ListSuggestions(ctx)
+	if err != nil {
+		log.Printf("Service: Error loading suggestions: %v", err)
+		return err
+	}
+	idx := buildSuggestIndex(suggestions)
+	s.index.Store(idx)
+	log.Printf("Service: Suggestion index rebuilt with %d suggestions (%d keys) in %s", len(suggestions), len(idx.entries), time.Since(start))
+	return nil
+}
+
+// Run builds the index and then rebuilds it whenever the catalog changed, until ctx is done.
+func (s *SuggestService) Run(ctx context.Context) {
+	s.run(ctx, "suggestion index", s.Refresh)
+}
+
+// Suggest returns up to limit completions for what the user typed so far.
+func (s *SuggestService) Suggest(ctx context.Context, query string, limit int) ([]models.Suggestion, error) {
+	prefix := normalizeSuggestText(query)
+	if prefix == "" {
+		return nil, errors.New("query is required")
+	}
+	if len([]rune(prefix)) > maxSearchQueryLength {
+		return nil, errors.New("query is too long")
+	}
+
+	if idx := s.index.Load(); idx != nil {
+		if suggestions := idx.lookup(prefix, limit); len(suggestions) > 0 {
+			return suggestions, nil
+		}
+	}
+
+	fuzzyCtx, cancel := context.WithTimeout(ctx, suggestFuzzyTimeout)
+	defer cancel()
+	suggestions, err := s.Repo.SuggestFuzzy(fuzzyCtx, prefix, limit)
+	if err != nil {
+		// Running out of time is expected under load; no completions beat a slow box.
+		log.Printf("Service: Fuzzy suggestions for '%s' failed: %v", prefix, err)
+		return []models.Suggestion{}, nil
+	}
+	if suggestions == nil {
+		suggestions = []models.Suggestion{}
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"errors"
+	"maps"
+
+	"github.com/kiasoh/basic-spotify-backend/models"
+)
+
+// defaultTasteModel is the taste model the service updates avg_interest with.
+var defaultTasteModel = models.TasteModel{
+	Alpha: 0.45,
+	Weights: map[string]float64{
+		"like":                 3.0,
+		"unlike":               -2.5,
+		"dislike":              -4.0,
+		"undislike":            4.5,
+		"skip":                 -1.0,
+		"play":                 1.0,
+		"add_to_playlist":      5.0,
+		"remove_from_playlist": -3.0,
+	},
+}
+
+// DefaultTasteModel returns a copy of the taste model the service uses.
+func DefaultTasteModel() models.TasteModel {
+	model := defaultTasteModel
+	model.Weights = maps.Clone(defaultTasteModel.Weights)
+	return model
+}
+
+// tasteWeight returns the weight of an interaction type in a taste model. Types the model
+// does not weigh keep their default weight.
+func tasteWeight(model models.TasteModel, interactionType string) (float64, error) {
+	if weight, ok := model.Weights[interactionType]; ok {
+		return weight, nil
+	}
+	weight, ok := defaultTasteModel.Weights[interactionType]
+	if !ok {
+		return 0, errors.New("invalid interaction type")
+	}
+	return weight, nil
+}
+
+// updateTaste blends a track, multiplied by weight, into avgInterest in place.
+func updateTaste(model models.TasteModel, avgInterest []float64, track *models.SpotifyTrack, weight float64) {
+	features := trackFeatures(track)
+	for i := 0; i < len(avgInterest) && i < featureCount; i++ {
+		avgInterest[i] = model.Alpha*avgInterest[i] + (1-model.Alpha)*weight*features[i]
+	}
+}
@@ -101,3 +101,73 @@ func (s *UserService) RegisterUser(ctx context.Context, username string, plainte
 	log.Printf("Successfully registered user %s with ID: %d and default playlist ID: %d", username, userID, playlistID)
 	return user, nil
 }
+
+func (s *UserService) ListUsers(ctx context.Context) ([]models.User, error) {
+	log.Println("Service: Listing users")
+	return s.UserRepo.ListUsers(ctx)
+}
+
+func (s *UserService) GetUser(ctx context.Context, userID int) (*models.User, error) {
+	user, err := s.UserRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("Service: Error getting user %d: %v", userID, err)
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// CurrentRole returns the role the user has now, which may differ from the one in their
+// token. It makes the service a middleware.RoleSource.
+func (s *UserService) CurrentRole(ctx context.Context, userID int) (models.Role, error) {
+	user, err := s.UserRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errors.New("user not found")
+		}
+		log.Printf("Service: Error getting role of user %d: %v", userID, err)
+		return "", err
+	}
+	return user.Role, nil
+}
+
+// UpdateUserRole changes a user's role. It applies to role-protected routes at once; the
+// role claim of the user's existing tokens is only updated when they log in again.
+func (s *UserService) UpdateUserRole(ctx context.Context, userID int, role models.Role) (*models.User, error) {
+	log.Printf("Service: Setting role of user %d to '%s'", userID, role)
+	if !role.IsValid() {
+		return nil, errors.New("invalid role")
+	}
+
+	err := s.UserRepo.UpdateUserRole(ctx, userID, role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("user not found")
+		}
+		log.Printf("Service: Error updating role of user %d: %v", userID, err)
+		return nil, err
+	}
+	return s.GetUser(ctx, userID)
+}
+
+// EnsureAdmin makes sure username exists and is an administrator, so that a fresh
+// deployment has someone to manage roles with. A missing user is registered with
+// plaintextPassword; an existing user is promoted and keeps their password.
+func (s *UserService) EnsureAdmin(ctx context.Context, username string, plaintextPassword string) (*models.User, error) {
+	user, err := s.UserRepo.GetUserByUsername(ctx, username)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		if plaintextPassword == "" {
+			return nil, errors.New("a password is required to create the admin user")
+		}
+		if user, err = s.RegisterUser(ctx, username, plaintextPassword); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		log.Printf("Service: Error getting admin user %s: %v", username, err)
+		return nil, err
+	case user.Role == models.RoleAdmin:
+		return user, nil
+	}
+	log.Printf("Service: Promoting user %s to admin", username)
+	return s.UpdateUserRole(ctx, user.ID, models.RoleAdmin)
+}